@@ -0,0 +1,250 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	mod "golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// A rule is a single line in a -deny policy file.
+type rule struct {
+	line    int
+	pattern string // module path, path/... for a tree, "retracted", or "pseudo"
+	op      string // "", "=", or "<"
+	version string
+}
+
+// readPolicy reads the -deny policy file.
+func readPolicy(file string) ([]rule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []rule
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		r := rule{line: n, pattern: fields[0]}
+		switch {
+		case len(fields) > 2:
+			return nil, fmt.Errorf("%s:%d: too many fields", file, n)
+		case len(fields) == 2:
+			r.op, r.version = "=", fields[1]
+			if strings.HasPrefix(r.version, "<") {
+				r.op, r.version = "<", r.version[1:]
+			}
+			if !semver.IsValid(r.version) {
+				return nil, fmt.Errorf("%s:%d: invalid version %s", file, n, r.version)
+			}
+			if r.pattern == "retracted" || r.pattern == "pseudo" {
+				return nil, fmt.Errorf("%s:%d: %s takes no version", file, n, r.pattern)
+			}
+		}
+		if r.pattern != "retracted" && r.pattern != "pseudo" {
+			if err := mod.CheckImportPath(strings.TrimSuffix(r.pattern, "/...")); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// matchPath reports whether the rule's pattern matches the module path.
+func (r *rule) matchPath(path string) bool {
+	if tree, ok := strings.CutSuffix(r.pattern, "/..."); ok {
+		return path == tree || strings.HasPrefix(path, tree+"/")
+	}
+	return path == r.pattern
+}
+
+// matchVersion reports whether the rule's version constraint matches version.
+func (r *rule) matchVersion(version string) bool {
+	switch r.op {
+	case "=":
+		return version == r.version
+	case "<":
+		return semver.Compare(version, r.version) < 0
+	}
+	return true
+}
+
+// check reports problems with the dependencies listed in info
+// for the binary named file, as directed by -check and -deny.
+// It returns false if any dependency is denied by the policy.
+func check(file string, info *debug.BuildInfo, policy []rule) bool {
+	denyRetract := false
+	for _, r := range policy {
+		if r.pattern == "retracted" {
+			denyRetract = true
+		}
+	}
+
+	ok := true
+	for _, dep := range info.Deps {
+		m := dep
+		if dep.Replace != nil {
+			m = dep.Replace
+			if *checkFlag {
+				switch {
+				case m.Version == "":
+					fmt.Fprintf(os.Stderr, "%s: %s@%s replaced by directory %s\n", file, dep.Path, dep.Version, m.Path)
+				case m.Path != dep.Path && mod.IsPseudoVersion(m.Version):
+					fmt.Fprintf(os.Stderr, "%s: %s@%s replaced by fork %s@%s (pseudo-version)\n", file, dep.Path, dep.Version, m.Path, m.Version)
+				default:
+					fmt.Fprintf(os.Stderr, "%s: %s@%s replaced by %s@%s\n", file, dep.Path, dep.Version, m.Path, m.Version)
+				}
+			}
+		} else if *checkFlag && mod.IsPseudoVersion(m.Version) {
+			fmt.Fprintf(os.Stderr, "%s: %s@%s is a pseudo-version\n", file, m.Path, m.Version)
+		}
+
+		retracted := ""
+		if (*checkFlag || denyRetract) && m.Version != "" {
+			var err error
+			retracted, err = isRetracted(m.Path, m.Version)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s@%s: checking retractions: %v\n", file, m.Path, m.Version, err)
+				if denyRetract {
+					// Without the retractions, the policy cannot be checked.
+					ok = false
+				}
+			} else if retracted != "" && *checkFlag {
+				fmt.Fprintf(os.Stderr, "%s: %s@%s is retracted: %s\n", file, m.Path, m.Version, retracted)
+			}
+		}
+
+		for _, r := range policy {
+			var denied bool
+			switch r.pattern {
+			case "retracted":
+				denied = retracted != ""
+			case "pseudo":
+				denied = mod.IsPseudoVersion(m.Version)
+			default:
+				// Deny a module by either its original or replacement path.
+				denied = r.matchPath(dep.Path) && r.matchVersion(dep.Version) ||
+					m != dep && m.Version != "" && r.matchPath(m.Path) && r.matchVersion(m.Version)
+			}
+			if denied {
+				fmt.Fprintf(os.Stderr, "%s: %s@%s denied by %s:%d\n", file, m.Path, m.Version, *denyFlag, r.line)
+				ok = false
+				break
+			}
+		}
+	}
+	return ok
+}
+
+// retractions caches the retractions for each module path.
+var retractions = make(map[string][]*modfile.Retract)
+
+// isRetracted reports whether path@version is retracted by the latest
+// version of the module, according to the module proxy.
+// If so, it returns the rationale, or "retracted" if there is none.
+func isRetracted(path, version string) (string, error) {
+	rs, ok := retractions[path]
+	if !ok {
+		var err error
+		rs, err = loadRetractions(path)
+		if err != nil {
+			return "", err
+		}
+		retractions[path] = rs
+	}
+	for _, r := range rs {
+		if semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0 {
+			if r.Rationale != "" {
+				return r.Rationale, nil
+			}
+			return "retracted", nil
+		}
+	}
+	return "", nil
+}
+
+// loadRetractions returns the retractions in the go.mod file
+// of the latest version of the module path.
+func loadRetractions(path string) ([]*modfile.Retract, error) {
+	proxy := proxyURL()
+	if proxy == "" {
+		return nil, fmt.Errorf("no module proxy (GOPROXY=%s)", os.Getenv("GOPROXY"))
+	}
+	epath, err := mod.EscapePath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := get(proxy + "/" + epath + "/@latest")
+	if err != nil {
+		return nil, err
+	}
+	var latest struct{ Version string }
+	if err := json.Unmarshal(data, &latest); err != nil {
+		return nil, err
+	}
+	evers, err := mod.EscapeVersion(latest.Version)
+	if err != nil {
+		return nil, err
+	}
+	data, err = get(proxy + "/" + epath + "/@v/" + evers + ".mod")
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.Retract, nil
+}
+
+// proxyURL returns the first module proxy URL listed in $GOPROXY,
+// or the empty string if there is none.
+func proxyURL() string {
+	list := os.Getenv("GOPROXY")
+	if list == "" {
+		list = "https://proxy.golang.org"
+	}
+	for _, p := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '|' }) {
+		if strings.HasPrefix(p, "https://") || strings.HasPrefix(p, "http://") {
+			return strings.TrimSuffix(p, "/")
+		}
+	}
+	return ""
+}
+
+func get(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return data, nil
+}
@@ -1,3 +1,5 @@
 module rsc.io/tmp/buildinfo
 
 go 1.20
+
+require golang.org/x/mod v0.17.0
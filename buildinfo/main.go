@@ -2,25 +2,153 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+// Buildinfo prints the build information embedded in Go binaries.
+//
+// Usage:
+//
+//	buildinfo [-sbom format] [-check] [-deny file] [-vuln] [binary...]
+//
+// With no arguments, buildinfo prints its own build information.
+// Otherwise it prints the build information for each named binary.
+//
+// By default, buildinfo prints the information as JSON.
+// The -sbom flag instead prints a software bill of materials
+// in the given format: cyclonedx (CycloneDX 1.5 JSON) or spdx
+// (SPDX 2.3 JSON). The SBOM lists the main module and each dependency,
+// with package URLs, and it records the build settings, such as the
+// VCS revision, with the main module. Each module's go.sum hash is
+// recorded as a property (CycloneDX) or in the package comment (SPDX),
+// not as a checksum: it is a hash of a list of file hashes, not of any
+// file that SBOM tools can check.
+//
+// The -check flag reports to standard error the dependencies that may
+// need attention: modules replaced by other modules or local directories,
+// pseudo-versions (noting those of forks, meaning replacements with a
+// different module path), and versions that have been retracted.
+// Buildinfo finds retractions by asking the module proxy listed in $GOPROXY
+// (default https://proxy.golang.org) for the go.mod file of the latest
+// version of each module.
+//
+// The -deny flag reads a policy file listing disallowed dependencies
+// and reports each dependency that the policy disallows,
+// exiting with a non-zero status if there are any.
+// Each line of the policy file, ignoring blank lines and # comments,
+// has one of these forms:
+//
+//	path          # disallow all versions of module path
+//	path/...      # disallow all modules in the tree rooted at path
+//	path v1.2.3   # disallow module path at version v1.2.3
+//	path <v1.2.3  # disallow module path at versions before v1.2.3
+//	retracted     # disallow retracted versions
+//	pseudo        # disallow pseudo-versions
+//
+// A path rule applies to a replaced dependency if it matches either
+// the original module or its replacement.
+// If the policy has a retracted rule and buildinfo cannot find
+// the retractions for a dependency, it counts the dependency as denied.
+//
+// The -vuln flag reports to standard error the known vulnerabilities
+// in the modules that make up each binary, including the standard library
+// of the Go release that built it, and exits with a non-zero status if
+// there are any. For each one, buildinfo prints the module version,
+// the vulnerability ID and its aliases (such as CVE IDs), the earliest
+// later version with a fix, and a summary. The vulnerabilities come from
+// the Go vulnerability database at $GOVULNDB (default https://vuln.go.dev),
+// which may also be a file:// URL naming a local copy. Unlike govulncheck,
+// which considers only the vulnerable functions a binary actually uses,
+// -vuln reports every vulnerability in the module versions the binary
+// contains, so some reports may not apply to the binary.
 package main
 
 import (
+	"debug/buildinfo"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"runtime/debug"
 )
 
+var (
+	sbomFlag  = flag.String("sbom", "", "print SBOM in `format` cyclonedx or spdx")
+	checkFlag = flag.Bool("check", false, "report replaced, pseudo-version, and retracted dependencies")
+	denyFlag  = flag.String("deny", "", "report dependencies disallowed by policy `file`")
+	vulnFlag  = flag.Bool("vuln", false, "report known vulnerabilities in modules")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: buildinfo [-sbom format] [-check] [-deny file] [-vuln] [binary...]\n")
+	os.Exit(2)
+}
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("buildinfo: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	switch *sbomFlag {
+	case "", "cyclonedx", "spdx":
+	default:
+		log.Printf("unknown -sbom format %q", *sbomFlag)
+		usage()
+	}
 
-	info, ok := debug.ReadBuildInfo()
-	if !ok {
-		log.Fatal("no info")
+	var policy []rule
+	if *denyFlag != "" {
+		var err error
+		policy, err = readPolicy(*denyFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	js, err := json.MarshalIndent(info, "", "\t")
+	if flag.NArg() == 0 {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			log.Fatal("no info")
+		}
+		show(info)
+		ok = check(info.Path, info, policy)
+		if *vulnFlag && !vulncheck(info.Path, info) {
+			ok = false
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	exit := 0
+	for _, file := range flag.Args() {
+		info, err := buildinfo.ReadFile(file)
+		if err != nil {
+			log.Print(err)
+			exit = 1
+			continue
+		}
+		show(info)
+		if !check(file, info, policy) {
+			exit = 1
+		}
+		if *vulnFlag && !vulncheck(file, info) {
+			exit = 1
+		}
+	}
+	os.Exit(exit)
+}
+
+// show prints info in the format selected by the flags.
+func show(info *debug.BuildInfo) {
+	var v any = info
+	switch *sbomFlag {
+	case "cyclonedx":
+		v = cycloneDX(info)
+	case "spdx":
+		v = spdx(info)
+	}
+	js, err := json.MarshalIndent(v, "", "\t")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,238 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"path"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// A module is a module listed in a binary's build information,
+// after applying any replacement.
+type module struct {
+	Path    string
+	Version string
+	Sum     string // go.sum hash ("h1:...") or empty
+}
+
+// modules returns the main module and the dependencies listed in info.
+func modules(info *debug.BuildInfo) (main module, deps []module) {
+	main = module{Path: info.Main.Path, Version: info.Main.Version, Sum: info.Main.Sum}
+	if main.Path == "" {
+		main.Path = info.Path
+	}
+	for _, m := range info.Deps {
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		deps = append(deps, module{Path: m.Path, Version: m.Version, Sum: m.Sum})
+	}
+	return main, deps
+}
+
+// purl returns the package URL for m.
+func (m module) purl() string {
+	var elems []string
+	for _, e := range strings.Split(m.Path, "/") {
+		elems = append(elems, url.PathEscape(e))
+	}
+	p := "pkg:golang/" + strings.Join(elems, "/")
+	if m.Version != "" && m.Version != "(devel)" {
+		p += "@" + url.PathEscape(m.Version)
+	}
+	return p
+}
+
+// settings returns the build settings in info as name, value pairs,
+// starting with the Go version.
+func settings(info *debug.BuildInfo) [][2]string {
+	list := [][2]string{{"go.version", info.GoVersion}}
+	for _, s := range info.Settings {
+		list = append(list, [2]string{"go." + strings.TrimPrefix(s.Key, "-"), s.Value})
+	}
+	return list
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// CycloneDX 1.5 JSON; see https://cyclonedx.org/docs/1.5/json/.
+
+type cdxBOM struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Version     int            `json:"version"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components"`
+	Deps        []cdxDep       `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Name string `json:"name"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDep struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+func cdxComp(typ string, m module) cdxComponent {
+	c := cdxComponent{
+		Type:    typ,
+		BOMRef:  m.purl(),
+		Name:    m.Path,
+		Version: m.Version,
+		PURL:    m.purl(),
+	}
+	// The go.sum hash is not a hash of any file that SBOM tools
+	// know how to check, so record it as a property, not in Hashes.
+	if m.Sum != "" {
+		c.Properties = []cdxProperty{{"go.sum", m.Sum}}
+	}
+	return c
+}
+
+// cycloneDX returns a CycloneDX SBOM for info.
+func cycloneDX(info *debug.BuildInfo) *cdxBOM {
+	main, deps := modules(info)
+	bom := &cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Timestamp: now(),
+			Tools:     []cdxTool{{"rsc.io/tmp/buildinfo"}},
+			Component: cdxComp("application", main),
+		},
+		Components: []cdxComponent{},
+	}
+	for _, kv := range settings(info) {
+		bom.Metadata.Component.Properties = append(bom.Metadata.Component.Properties, cdxProperty{kv[0], kv[1]})
+	}
+	mainDep := cdxDep{Ref: main.purl(), DependsOn: []string{}}
+	for _, m := range deps {
+		bom.Components = append(bom.Components, cdxComp("library", m))
+		mainDep.DependsOn = append(mainDep.DependsOn, m.purl())
+	}
+	bom.Deps = []cdxDep{mainDep}
+	return bom
+}
+
+// SPDX 2.3 JSON; see https://spdx.github.io/spdx-spec/v2.3/.
+
+type spdxDoc struct {
+	SPDXVersion   string             `json:"spdxVersion"`
+	DataLicense   string             `json:"dataLicense"`
+	SPDXID        string             `json:"SPDXID"`
+	Name          string             `json:"name"`
+	Namespace     string             `json:"documentNamespace"`
+	CreationInfo  spdxCreationInfo   `json:"creationInfo"`
+	Packages      []spdxPackage      `json:"packages"`
+	Relationships []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string    `json:"name"`
+	SPDXID           string    `json:"SPDXID"`
+	VersionInfo      string    `json:"versionInfo,omitempty"`
+	DownloadLocation string    `json:"downloadLocation"`
+	FilesAnalyzed    bool      `json:"filesAnalyzed"`
+	ExternalRefs     []spdxRef `json:"externalRefs"`
+	Comment          string    `json:"comment,omitempty"`
+}
+
+type spdxRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+func spdxPkg(id string, m module) spdxPackage {
+	p := spdxPackage{
+		Name:             m.Path,
+		SPDXID:           id,
+		VersionInfo:      m.Version,
+		DownloadLocation: "NOASSERTION",
+		ExternalRefs:     []spdxRef{{"PACKAGE-MANAGER", "purl", m.purl()}},
+	}
+	// The go.sum hash is not a checksum of the package's files,
+	// so record it in the comment instead of Checksums.
+	if m.Sum != "" {
+		p.Comment = "go.sum=" + m.Sum + "\n"
+	}
+	return p
+}
+
+// spdx returns an SPDX SBOM for info.
+// SPDX packages have no general property list,
+// so the build settings are recorded in the main package's comment.
+func spdx(info *debug.BuildInfo) *spdxDoc {
+	main, deps := modules(info)
+	name := path.Base(info.Path)
+	doc := &spdxDoc{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        name,
+		Namespace:   fmt.Sprintf("https://rsc.io/tmp/buildinfo/spdx/%s-%x", name, sha256.Sum256([]byte(info.String()))),
+		CreationInfo: spdxCreationInfo{
+			Created:  now(),
+			Creators: []string{"Tool: rsc.io/tmp/buildinfo"},
+		},
+	}
+
+	mainPkg := spdxPkg("SPDXRef-Package-main", main)
+	var comment strings.Builder
+	for _, kv := range settings(info) {
+		fmt.Fprintf(&comment, "%s=%s\n", kv[0], kv[1])
+	}
+	mainPkg.Comment += comment.String()
+	doc.Packages = append(doc.Packages, mainPkg)
+	doc.Relationships = append(doc.Relationships, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", mainPkg.SPDXID})
+
+	for i, m := range deps {
+		p := spdxPkg(fmt.Sprintf("SPDXRef-Package-%d", i+1), m)
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{mainPkg.SPDXID, "DEPENDS_ON", p.SPDXID})
+	}
+	return doc
+}
@@ -0,0 +1,248 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// An osvEntry is the part of an OSV vulnerability report used by -vuln.
+// See https://ossf.github.io/osv-schema/.
+type osvEntry struct {
+	ID       string
+	Summary  string
+	Aliases  []string
+	Affected []struct {
+		Package struct {
+			Name string
+		}
+		Ranges []osvRange
+	}
+}
+
+// An osvRange is a list of events giving the versions
+// at which a vulnerability was introduced and fixed.
+type osvRange struct {
+	Type   string
+	Events []struct {
+		Introduced string
+		Fixed      string
+	}
+}
+
+// A vulnModule is an entry in the vulnerability database's module index.
+type vulnModule struct {
+	Path  string
+	Vulns []struct {
+		ID    string
+		Fixed string // latest fixed version, if any
+	}
+}
+
+var (
+	vulnIndex   map[string]*vulnModule // by module path; nil until loaded
+	vulnEntries = make(map[string]*osvEntry)
+)
+
+// vulncheck reports to standard error the known vulnerabilities
+// affecting the main module, the dependencies listed in info,
+// and the standard library, for the binary named file.
+// It returns false if any are found.
+func vulncheck(file string, info *debug.BuildInfo) bool {
+	if vulnIndex == nil {
+		if err := loadVulnIndex(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: loading vulnerability database: %v\n", file, err)
+			return false
+		}
+	}
+
+	mods := []*debug.Module{&info.Main}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		mods = append(mods, dep)
+	}
+	goVersion, _, _ := strings.Cut(info.GoVersion, " ")
+	mods = append(mods, &debug.Module{Path: "stdlib", Version: goSemver(goVersion)})
+
+	ok := true
+	for _, m := range mods {
+		if !semver.IsValid(m.Version) {
+			continue // local directory, (devel), and so on
+		}
+		vm := vulnIndex[m.Path]
+		if vm == nil {
+			continue
+		}
+		for _, v := range vm.Vulns {
+			if v.Fixed != "" && semver.Compare(m.Version, "v"+strings.TrimPrefix(v.Fixed, "v")) >= 0 {
+				continue
+			}
+			e, err := loadVulnEntry(v.ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s@%s: %v\n", file, m.Path, m.Version, err)
+				ok = false
+				continue
+			}
+			vulnerable, fixed := e.affects(m.Path, m.Version)
+			if !vulnerable {
+				continue
+			}
+			ok = false
+			id := e.ID
+			if len(e.Aliases) > 0 {
+				id += " (" + strings.Join(e.Aliases, ", ") + ")"
+			}
+			fix := "no fixed version"
+			if fixed != "" {
+				fix = "fixed in " + fixed
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s@%s: %s, %s: %s\n", file, m.Path, m.Version, id, fix, e.Summary)
+		}
+	}
+	return ok
+}
+
+// affects reports whether e affects version of the module path.
+// If so, it also returns the earliest version fixing the vulnerability
+// that is later than version, or "" if there is none.
+func (e *osvEntry) affects(path, version string) (bool, string) {
+	for _, a := range e.Affected {
+		if a.Package.Name != path {
+			continue
+		}
+		if len(a.Ranges) == 0 {
+			return true, "" // all versions
+		}
+		for _, r := range a.Ranges {
+			if r.Type != "SEMVER" {
+				continue
+			}
+			// Apply the events in version order; the last one
+			// at or before version says whether it is affected.
+			type event struct {
+				v     string
+				fixed bool
+			}
+			var events []event
+			for _, ev := range r.Events {
+				if ev.Introduced != "" {
+					v := ""
+					if ev.Introduced != "0" {
+						v = "v" + ev.Introduced
+					}
+					events = append(events, event{v, false})
+				}
+				if ev.Fixed != "" {
+					events = append(events, event{"v" + ev.Fixed, true})
+				}
+			}
+			sort.SliceStable(events, func(i, j int) bool { return semver.Compare(events[i].v, events[j].v) < 0 })
+			affected := false
+			i := 0
+			for ; i < len(events) && semver.Compare(events[i].v, version) <= 0; i++ {
+				affected = !events[i].fixed
+			}
+			if affected {
+				for ; i < len(events); i++ {
+					if events[i].fixed {
+						return true, events[i].v
+					}
+				}
+				return true, ""
+			}
+		}
+	}
+	return false, ""
+}
+
+// goSemver returns the semantic version for the Go release goVersion,
+// such as v1.21.3 for go1.21.3 and v1.22.0-rc.1 for go1.22rc1,
+// or "" if goVersion is not a release.
+func goSemver(goVersion string) string {
+	v, ok := strings.CutPrefix(goVersion, "go")
+	if !ok {
+		return ""
+	}
+	pre := ""
+	for _, p := range []string{"rc", "beta"} {
+		if i := strings.Index(v, p); i >= 0 {
+			v, pre = v[:i], "-"+p+"."+v[i+len(p):]
+			break
+		}
+	}
+	if strings.Count(v, ".") == 1 {
+		v += ".0"
+	}
+	sv := "v" + v + pre
+	if !semver.IsValid(sv) {
+		return ""
+	}
+	return sv
+}
+
+// vulnDB returns the URL of the Go vulnerability database:
+// $GOVULNDB, or else https://vuln.go.dev.
+func vulnDB() string {
+	if db := os.Getenv("GOVULNDB"); db != "" {
+		return strings.TrimSuffix(db, "/")
+	}
+	return "https://vuln.go.dev"
+}
+
+// vulnGet returns the file named by the slash-separated path
+// in the vulnerability database.
+func vulnGet(path string) ([]byte, error) {
+	db := vulnDB()
+	if u, err := url.Parse(db); err == nil && u.Scheme == "file" {
+		return os.ReadFile(filepath.Join(filepath.FromSlash(u.Path), filepath.FromSlash(path)))
+	}
+	return get(db + "/" + path)
+}
+
+// loadVulnIndex loads the vulnerability database's index of
+// modules with known vulnerabilities.
+func loadVulnIndex() error {
+	data, err := vulnGet("index/modules.json")
+	if err != nil {
+		return err
+	}
+	var list []*vulnModule
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("index/modules.json: %v", err)
+	}
+	vulnIndex = make(map[string]*vulnModule)
+	for _, m := range list {
+		vulnIndex[m.Path] = m
+	}
+	return nil
+}
+
+// loadVulnEntry returns the OSV entry for the vulnerability id.
+func loadVulnEntry(id string) (*osvEntry, error) {
+	if e := vulnEntries[id]; e != nil {
+		return e, nil
+	}
+	data, err := vulnGet("ID/" + id + ".json")
+	if err != nil {
+		return nil, err
+	}
+	e := new(osvEntry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("%s: %v", id, err)
+	}
+	vulnEntries[id] = e
+	return e, nil
+}
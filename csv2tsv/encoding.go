@@ -0,0 +1,140 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// encodings lists the input encodings accepted by the -enc flag.
+var encodings = []string{"utf8", "utf8bom", "latin1", "utf16"}
+
+// decoder returns a reader that converts r from the encoding
+// named by the -enc flag to UTF-8, as the input is read.
+func decoder(r io.Reader) io.Reader {
+	switch *enc {
+	case "utf8bom":
+		b := bufio.NewReader(r)
+		if bom, _ := b.Peek(3); string(bom) == "\xEF\xBB\xBF" {
+			b.Discard(3)
+		}
+		return b
+	case "latin1":
+		return &transcoder{next: latin1(bufio.NewReader(r))}
+	case "utf16":
+		return &transcoder{next: utf16le(bufio.NewReader(r))}
+	}
+	return r
+}
+
+// A transcoder is a reader returning the UTF-8 encoding
+// of the runes returned by successive calls to next.
+type transcoder struct {
+	next func() (rune, error)
+	buf  []byte // encoded runes not yet returned
+	err  error
+}
+
+func (t *transcoder) Read(p []byte) (int, error) {
+	for len(t.buf) < len(p) && t.err == nil {
+		r, err := t.next()
+		if err != nil {
+			t.err = err
+			break
+		}
+		t.buf = utf8.AppendRune(t.buf, r)
+	}
+	n := copy(p, t.buf)
+	t.buf = t.buf[:copy(t.buf, t.buf[n:])]
+	if n == 0 {
+		return 0, t.err
+	}
+	return n, nil
+}
+
+// cp1252 maps the bytes 0x80 through 0x9F to the characters they encode
+// in Windows-1252, the superset of Latin-1 used by most Windows programs.
+// The five bytes that Windows-1252 leaves undefined map to the
+// corresponding Latin-1 control characters.
+var cp1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+// latin1 returns a function returning the successive runes
+// of the Windows-1252 input b.
+func latin1(b *bufio.Reader) func() (rune, error) {
+	return func() (rune, error) {
+		c, err := b.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if 0x80 <= c && c < 0xA0 {
+			return cp1252[c-0x80], nil
+		}
+		return rune(c), nil
+	}
+}
+
+var errOddUTF16 = errors.New("UTF-16 input has odd length")
+
+// utf16le returns a function returning the successive runes
+// of the UTF-16 input b. The input is little-endian, as Windows writes it,
+// unless it begins with a big-endian byte order mark.
+// A leading byte order mark is discarded.
+func utf16le(b *bufio.Reader) func() (rune, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bom, _ := b.Peek(2); string(bom) == "\xFE\xFF" {
+		order = binary.BigEndian
+		b.Discard(2)
+	} else if string(bom) == "\xFF\xFE" {
+		b.Discard(2)
+	}
+
+	var buf [2]byte
+	unit := func() (rune, error) {
+		if _, err := io.ReadFull(b, buf[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errOddUTF16
+			}
+			return 0, err
+		}
+		return rune(order.Uint16(buf[:])), nil
+	}
+
+	pending := rune(-1) // unit read after an unpaired surrogate
+	return func() (rune, error) {
+		r := pending
+		pending = -1
+		if r < 0 {
+			var err error
+			if r, err = unit(); err != nil {
+				return 0, err
+			}
+		}
+		if !utf16.IsSurrogate(r) {
+			return r, nil
+		}
+		r2, err := unit()
+		if err != nil {
+			if err == io.EOF {
+				return utf8.RuneError, nil
+			}
+			return 0, err
+		}
+		if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+			return dec, nil
+		}
+		pending = r2
+		return utf8.RuneError, nil
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var decoderTests = []struct {
+	enc string
+	in  string
+	out string
+}{
+	{"utf8", "\xEF\xBB\xBFa,b\n", "\xEF\xBB\xBFa,b\n"},
+	{"utf8bom", "\xEF\xBB\xBFa,é\n", "a,é\n"},
+	{"utf8bom", "a,é\n", "a,é\n"},
+	{"latin1", "caf\xE9,\x80 5,\x93q\x94\n", "café,€ 5,“q”\n"},
+	{"utf16", "a\x00,\x00\xE9\x00\n\x00", "a,é\n"},
+	{"utf16", "\xFF\xFEa\x00\n\x00", "a\n"},
+	{"utf16", "\xFE\xFF\x00a\x00\n", "a\n"},
+	{"utf16", "\x3D\xD8\x00\xDE\n\x00", "😀\n"},
+	{"utf16", "\x3D\xD8a\x00", "�a"},
+	{"utf16", "\x00\xDC", "�"},
+}
+
+func TestDecoder(t *testing.T) {
+	defer func(old string) { *enc = old }(*enc)
+	for _, tt := range decoderTests {
+		*enc = tt.enc
+		// Read one byte at a time to check that
+		// the decoder handles short reads.
+		data, err := io.ReadAll(iotest.OneByteReader(decoder(strings.NewReader(tt.in))))
+		if err != nil {
+			t.Errorf("-enc %s: decode(%q): %v", tt.enc, tt.in, err)
+			continue
+		}
+		if string(data) != tt.out {
+			t.Errorf("-enc %s: decode(%q) = %q, want %q", tt.enc, tt.in, data, tt.out)
+		}
+	}
+}
+
+func TestDecoderOddUTF16(t *testing.T) {
+	defer func(old string) { *enc = old }(*enc)
+	*enc = "utf16"
+	_, err := io.ReadAll(decoder(strings.NewReader("a\x00b")))
+	if err != errOddUTF16 {
+		t.Errorf("decode odd-length UTF-16: err = %v, want %v", err, errOddUTF16)
+	}
+}
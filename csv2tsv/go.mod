@@ -1 +1,3 @@
 module rsc.io/tmp/csv2tsv
+
+go 1.21
@@ -6,14 +6,25 @@
 //
 // Usage:
 //
-//	csv2tsv [-c comment] [-o output] [-t tab] [file...]
+//	csv2tsv [-c comment] [-enc encoding] [-o output] [-stats] [-escape | -t tab] [file...]
+//	csv2tsv -r [-c comment] [-enc encoding] [-o output] [-escape | -t tab] [-d delim] [-q always|minimal] [-crlf] [file...]
 //
 // Csv2tsv reads the named files, or else standard input, as comma-separated value data
 // and prints that data in tab-separated form to standard output.
+// It converts the data as it reads it, one record at a time,
+// so that even very large inputs use little memory.
 //
 // The -c flag specifies a comment character. Input lines beginning with this
 // character will be elided.
 //
+// The -enc flag specifies the encoding of the input, which csv2tsv converts
+// to UTF-8 before parsing it: "utf8", the default, reads the input unchanged;
+// "utf8bom" discards a leading UTF-8 byte order mark, as written by Excel;
+// "latin1" decodes Windows-1252, the superset of ISO 8859-1 used by
+// most Windows programs; and "utf16" decodes UTF-16, little-endian unless
+// the input begins with a big-endian byte order mark.
+// The output is always UTF-8.
+//
 // The -o flag specifies the name of a file to write instead of using standard output.
 //
 // The -t flag specifies a string to use in place of the tab character.
@@ -21,6 +32,31 @@
 // Before printing the data, csv2tsv replaces every newline or occurrence of the tab string
 // with a single space.
 //
+// The -escape flag makes the conversion lossless: instead of replacing
+// them with spaces, csv2tsv prints each tab, newline, carriage return,
+// and backslash in the data as the escape \t, \n, \r, or \\.
+// The -escape flag cannot be combined with -t.
+//
+// The -stats flag prints statistics about each input's columns instead of the data,
+// as a quick way to profile a file. It reads each input once, treating its first
+// line as a header naming the columns, and prints one line per column giving
+// the column number and name; the number of cells, empty cells, and numeric cells;
+// the minimum, maximum, and mean of the numeric cells; and an estimate
+// of the number of distinct values, accurate to within about 1%.
+// The statistics are printed in the same tab-separated form as the data.
+//
+// The -r flag reverses the conversion: csv2tsv reads the input as
+// tab-separated data, splitting each line at the tab string (see -t),
+// and prints it as comma-separated values, so that data can be taken
+// back to spreadsheet programs. The -c flag still elides comment lines,
+// and the -escape flag decodes the escapes that csv2tsv -escape prints.
+// The remaining flags apply only with -r.
+// The -d flag specifies the output field delimiter (default comma).
+// The -q flag specifies the quoting style: "minimal", the default, quotes
+// only fields that need quoting, while "always" quotes every field.
+// The -crlf flag ends output lines with CRLF instead of LF,
+// as RFC 4180 specifies and some spreadsheet programs expect.
+//
 // Example
 //
 // To print the second and fourth fields of a CSV file using awk:
@@ -37,6 +73,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"strings"
 )
 
@@ -44,14 +81,23 @@ var (
 	cflag = flag.String("c", "", "treat lines beginning with `char` as comments")
 	oflag = flag.String("o", "", "write output to `file` (default standard output)")
 	tab   = flag.String("t", "", "use `string` in place of tab in output")
+	stats = flag.Bool("stats", false, "print column statistics instead of data")
+	rflag = flag.Bool("r", false, "convert TSV input to CSV output")
+	esc   = flag.Bool("escape", false, "escape tabs, newlines, and backslashes in output (or decode them with -r)")
+	dflag = flag.String("d", ",", "with -r, separate output fields with `delim`")
+	qflag = flag.String("q", "minimal", "with -r, quote `always` or only when needed (minimal)")
+	crlf  = flag.Bool("crlf", false, "with -r, end output lines with CRLF")
+	enc   = flag.String("enc", "utf8", "decode input from `encoding` (utf8, utf8bom, latin1, or utf16)")
 
 	output  *bufio.Writer
 	comment rune
+	delim   rune
 	exit    = 0
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: csv2tsv [-o output] [-t tab] [file...]\n")
+	fmt.Fprintf(os.Stderr, "usage: csv2tsv [-c comment] [-enc encoding] [-o output] [-stats] [-escape | -t tab] [file...]\n")
+	fmt.Fprintf(os.Stderr, "       csv2tsv -r [-c comment] [-enc encoding] [-o output] [-escape | -t tab] [-d delim] [-q always|minimal] [-crlf] [file...]\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -62,6 +108,28 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if *rflag && *stats || *esc && *tab != "" {
+		usage()
+	}
+	if !*rflag {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "d" || f.Name == "q" || f.Name == "crlf" {
+				usage()
+			}
+		})
+	}
+	if *qflag != "minimal" && *qflag != "always" {
+		log.Fatalf("unknown quoting style %q; want always or minimal", *qflag)
+	}
+	if !slices.Contains(encodings, *enc) {
+		log.Fatalf("unknown input encoding %q; want %s", *enc, strings.Join(encodings, ", "))
+	}
+	if r := []rune(*dflag); len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+		log.Fatalf("output delimiter %q must be a single rune other than quote or newline", *dflag)
+	} else {
+		delim = r[0]
+	}
+
 	if *tab == "" {
 		*tab = "\t"
 	}
@@ -69,7 +137,7 @@ func main() {
 	if *cflag != "" {
 		r := []rune(*cflag)
 		if len(r) != 1 {
-			log.Fatal("comment char %q must be a single rune", *cflag)
+			log.Fatalf("comment char %q must be a single rune", *cflag)
 		}
 		comment = r[0]
 	}
@@ -103,26 +171,56 @@ func main() {
 }
 
 func convert(f *os.File) {
-	r := csv.NewReader(bufio.NewReader(f))
+	in := decoder(f)
+	if *rflag {
+		readTSV(f.Name(), in, printCSV)
+		return
+	}
+	if *stats {
+		var s statser
+		read(f.Name(), in, s.add)
+		s.print()
+		return
+	}
+	read(f.Name(), in, printRecord)
+}
+
+// read calls do for each record in the input in, read from the named file.
+// The record slice is reused for each call.
+func read(name string, in io.Reader, do func([]string)) {
+	r := csv.NewReader(bufio.NewReader(in))
 	r.FieldsPerRecord = -1
 	r.Comment = comment
+	r.ReuseRecord = true
 	for {
 		rec, err := r.Read()
 		if err != nil {
 			if err != io.EOF {
-				log.Print("reading %s: %v", f.Name(), err)
+				log.Printf("reading %s: %v", name, err)
 				exit = 1
 			}
 			break
 		}
-		for i, r := range rec {
-			if i > 0 {
-				output.WriteString(*tab)
-			}
+		do(rec)
+	}
+}
+
+// escaper implements the -escape flag.
+var escaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// printRecord prints rec as a line of tab-separated output.
+func printRecord(rec []string) {
+	for i, r := range rec {
+		if i > 0 {
+			output.WriteString(*tab)
+		}
+		if *esc {
+			r = escaper.Replace(r)
+		} else {
 			r = strings.Replace(r, "\n", " ", -1)
 			r = strings.Replace(r, *tab, " ", -1)
-			output.WriteString(r)
 		}
-		output.WriteString("\n")
+		output.WriteString(r)
 	}
+	output.WriteString("\n")
 }
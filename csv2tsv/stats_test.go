@@ -0,0 +1,40 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHLL(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		var h hll
+		for i := 0; i < n; i++ {
+			// Add each value twice: duplicates must not count.
+			h.add(fmt.Sprint("v", i))
+			h.add(fmt.Sprint("v", i))
+		}
+		got := h.estimate()
+		if math.Abs(float64(got)-float64(n)) > 0.03*float64(n)+1 {
+			t.Errorf("after %d distinct values, estimate() = %d", n, got)
+		}
+	}
+}
+
+func TestColStats(t *testing.T) {
+	var c colStats
+	for _, v := range []string{"3", " ", "-1.5", "x", "10", "NaN", "3"} {
+		c.add(v)
+	}
+	if c.count != 7 || c.empty != 1 || c.numeric != 4 || c.min != -1.5 || c.max != 10 || c.sum != 14.5 {
+		t.Errorf("colStats = count %d, empty %d, numeric %d, min %v, max %v, sum %v; want 7, 1, 4, -1.5, 10, 14.5",
+			c.count, c.empty, c.numeric, c.min, c.max, c.sum)
+	}
+	if d := c.distinct.estimate(); d != 6 {
+		t.Errorf("distinct estimate = %d, want 6", d)
+	}
+}
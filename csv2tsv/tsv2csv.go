@@ -0,0 +1,108 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// readTSV calls do for each line of tab-separated data in the input in,
+// read from the named file, split into fields at the tab string.
+func readTSV(name string, in io.Reader, do func([]string)) {
+	s := bufio.NewScanner(in)
+	s.Buffer(nil, 1<<30)
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if comment != 0 && strings.HasPrefix(line, string(comment)) {
+			continue
+		}
+		fields := strings.Split(line, *tab)
+		if *esc {
+			for i, f := range fields {
+				fields[i] = unescape(f)
+			}
+		}
+		do(fields)
+	}
+	if err := s.Err(); err != nil {
+		log.Printf("reading %s: %v", name, err)
+		exit = 1
+	}
+}
+
+// unescape decodes the escapes \t, \n, \r, and \\ in s,
+// as printed by csv2tsv -escape.
+// It leaves other backslashes unchanged.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 't':
+				c = '\t'
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case '\\':
+				c = '\\'
+			default:
+				b.WriteByte(c)
+				continue
+			}
+			i++
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// printCSV prints rec as a line of comma-separated output,
+// using the delimiter, quoting style, and line ending set by the flags.
+func printCSV(rec []string) {
+	for i, field := range rec {
+		if i > 0 {
+			output.WriteRune(delim)
+		}
+		// A lone empty field would print as a blank line,
+		// which CSV readers skip, so quote it.
+		if *qflag == "always" || needsQuotes(field) || len(rec) == 1 && field == "" {
+			output.WriteString(`"`)
+			output.WriteString(strings.Replace(field, `"`, `""`, -1))
+			output.WriteString(`"`)
+		} else {
+			output.WriteString(field)
+		}
+	}
+	if *crlf {
+		output.WriteString("\r\n")
+	} else {
+		output.WriteString("\n")
+	}
+}
+
+// needsQuotes reports whether field must be quoted in CSV output.
+// Like encoding/csv, it quotes fields containing the delimiter,
+// a quote, or a line break, fields beginning with a space,
+// and the field \. (which some programs treat as end of data).
+func needsQuotes(field string) bool {
+	if field == `\.` {
+		return true
+	}
+	if strings.ContainsRune(field, delim) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
+}
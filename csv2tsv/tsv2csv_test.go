@@ -0,0 +1,64 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var printCSVTests = []struct {
+	rec   []string
+	delim rune
+	quote string
+	crlf  bool
+	out   string
+}{
+	{[]string{"a", "b"}, ',', "minimal", false, "a,b\n"},
+	{[]string{"a,b", `say "hi"`, " x", `\.`, ""}, ',', "minimal", false, `"a,b","say ""hi"""," x","\.",` + "\n"},
+	{[]string{""}, ',', "minimal", false, `""` + "\n"},
+	{[]string{"a,b", "c;d"}, ';', "minimal", false, `a,b;"c;d"` + "\n"},
+	{[]string{"a", ""}, ',', "always", true, `"a",""` + "\r\n"},
+}
+
+func TestPrintCSV(t *testing.T) {
+	defer func(q string, c bool, d rune) { *qflag, *crlf, delim = q, c, d }(*qflag, *crlf, delim)
+	for _, tt := range printCSVTests {
+		var buf bytes.Buffer
+		output = bufio.NewWriter(&buf)
+		*qflag, *crlf, delim = tt.quote, tt.crlf, tt.delim
+		printCSV(tt.rec)
+		output.Flush()
+		if buf.String() != tt.out {
+			t.Errorf("printCSV(%q) with -d %q -q %s -crlf=%v = %q, want %q", tt.rec, tt.delim, tt.quote, tt.crlf, buf.String(), tt.out)
+			continue
+		}
+		r := csv.NewReader(&buf)
+		r.Comma = tt.delim
+		rec, err := r.Read()
+		if err != nil || !reflect.DeepEqual(rec, tt.rec) {
+			t.Errorf("reading back printCSV(%q) = %q, %v", tt.rec, rec, err)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, s := range []string{"", "plain", "a\tb", "line1\nline2\r\n", `back\slash`, `\t literal`, "\\\n", `trailing\`} {
+		e := escaper.Replace(s)
+		if strings.ContainsAny(e, "\t\n\r") {
+			t.Errorf("escaper.Replace(%q) = %q, contains tab or newline", s, e)
+		}
+		if u := unescape(e); u != s {
+			t.Errorf("unescape(%q) = %q, want %q", e, u, s)
+		}
+	}
+	if u := unescape(`a\qb\`); u != `a\qb\` {
+		t.Errorf("unescape(%q) = %q, want unchanged", `a\qb\`, u)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// isArchive reports whether name is an archive that dirhash reads
+// in place of a directory.
+func isArchive(name string) bool {
+	for _, suffix := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// An archiveFile is a file or symbolic link in an archive.
+type archiveFile struct {
+	hash string // file hash and metadata, as printed in the list
+	link string // target of symbolic link, if non-empty
+}
+
+// archivehash prints the hash of the tree that the archive file
+// would produce if extracted, computed the same way as dirhash.
+func archivehash(file string) {
+	files := make(map[string]*archiveFile)
+	var err error
+	if strings.HasSuffix(file, ".zip") {
+		err = readZip(file, files)
+	} else {
+		err = readTar(file, files)
+	}
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	// Resolve symbolic links to the files they name,
+	// as filepath.Walk does for files in a directory.
+	// Each link in a chain is relative to the directory
+	// containing that link, not the first one.
+	resolved := make(map[string]*archiveFile)
+	var names []string
+	for name, f := range files {
+		cur := name
+		for n := 0; f != nil && f.link != ""; n++ {
+			if n >= 255 || path.IsAbs(f.link) {
+				f = nil
+				break
+			}
+			cur = path.Join(path.Dir(cur), f.link)
+			f = files[cur]
+		}
+		if f == nil {
+			log.Printf("%s: skipping unresolved symlink %s", file, name)
+			continue
+		}
+		resolved[name] = f
+		names = append(names, name)
+	}
+	files = resolved
+	sort.Slice(names, func(i, j int) bool { return walkLess(names[i], names[j]) })
+
+	var list []fileHash
+	for _, name := range names {
+		list = append(list, fileHash{name, files[name].hash})
+	}
+	printHash(file, list)
+}
+
+// walkLess reports whether the slash-separated path a comes before b
+// in the order that filepath.Walk visits files.
+func walkLess(a, b string) bool {
+	for {
+		ea, ra, _ := strings.Cut(a, "/")
+		eb, rb, _ := strings.Cut(b, "/")
+		if ea != eb || ra == "" || rb == "" {
+			if ea == eb {
+				return ra < rb
+			}
+			return ea < eb
+		}
+		a, b = ra, rb
+	}
+}
+
+// cleanName returns the cleaned form of the archive entry name,
+// or "" if the name refers to the top directory or outside it.
+func cleanName(name string) string {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return ""
+	}
+	return name
+}
+
+// readTar adds the files in the (possibly gzipped) tar file to files.
+func readTar(file string, files map[string]*archiveFile) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(file, ".tar") {
+		z, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		r = z
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		name := cleanName(hdr.Name)
+		if name == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			h := newHash()
+			if _, err := io.Copy(h, tr); err != nil {
+				return fmt.Errorf("%s: %s: %v", file, hdr.Name, err)
+			}
+			attrs := make(map[string][]byte)
+			for k, v := range hdr.PAXRecords {
+				if name, ok := strings.CutPrefix(k, "SCHILY.xattr."); ok {
+					attrs[name] = []byte(v)
+				}
+			}
+			files[name] = &archiveFile{hash: fmt.Sprintf("%x", h.Sum(nil)) + metadata(hdr.FileInfo(), attrs)}
+		case tar.TypeLink:
+			target := files[cleanName(hdr.Linkname)]
+			if target == nil {
+				return fmt.Errorf("%s: %s: hard link to missing file %s", file, hdr.Name, hdr.Linkname)
+			}
+			files[name] = target
+		case tar.TypeSymlink:
+			files[name] = &archiveFile{link: hdr.Linkname}
+		}
+	}
+}
+
+// readZip adds the files in the zip file to files.
+// Zip files have no extended attributes.
+func readZip(file string, files map[string]*archiveFile) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		name := cleanName(zf.Name)
+		mode := zf.Mode()
+		if name == "" || mode.IsDir() || strings.HasSuffix(zf.Name, "/") {
+			continue
+		}
+		if mode&fs.ModeType != 0 && mode&fs.ModeSymlink == 0 {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", file, zf.Name, err)
+		}
+		if mode&fs.ModeSymlink != 0 {
+			link, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %s: %v", file, zf.Name, err)
+			}
+			files[name] = &archiveFile{link: string(link)}
+			continue
+		}
+		h := newHash()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", file, zf.Name, err)
+		}
+		files[name] = &archiveFile{hash: fmt.Sprintf("%x", h.Sum(nil)) + metadata(zf.FileInfo(), nil)}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheHeader is the first line of a cache file.
+const cacheHeader = "dirhash cache v2"
+
+// cacheVerify is the fraction of cache hits that
+// dirhash re-reads to check that the cache is still accurate.
+const cacheVerify = 0.01
+
+// A cacheKey identifies a cached hash: the hash algorithm
+// and the absolute path of the file.
+type cacheKey struct {
+	algo string
+	path string
+}
+
+// A cacheEntry records the content hash of a file
+// along with the metadata used to decide whether it is still valid.
+type cacheEntry struct {
+	hash     string
+	size     int64
+	mtime    int64 // modification time, in Unix nanoseconds
+	dev, ino uint64
+}
+
+var (
+	cache     map[cacheKey]cacheEntry // nil if not using -cache
+	cacheSeen = make(map[string]bool) // absolute paths of files hashed
+	cacheDirs []string                // absolute paths of directories hashed
+)
+
+// loadCache reads the cache from file.
+// A missing file is treated as an empty cache.
+func loadCache(file string) {
+	cache = make(map[cacheKey]cacheEntry)
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	if !s.Scan() || s.Text() != cacheHeader {
+		log.Printf("%s: not a dirhash cache; ignoring", file)
+		return
+	}
+	for lineno := 2; s.Scan(); lineno++ {
+		var k cacheKey
+		var e cacheEntry
+		_, err := fmt.Sscanf(s.Text(), "%s %s %d %d %d %d %q", &k.algo, &e.hash, &e.size, &e.mtime, &e.dev, &e.ino, &k.path)
+		if err != nil {
+			log.Printf("%s:%d: malformed cache entry; ignoring cache", file, lineno)
+			cache = make(map[cacheKey]cacheEntry)
+			return
+		}
+		cache[k] = e
+	}
+	if err := s.Err(); err != nil {
+		log.Fatalf("reading %s: %v", file, err)
+	}
+}
+
+// saveCache writes the cache to file, dropping the entries
+// for files in the hashed directories that no longer exist.
+func saveCache(file string) {
+	var keys []cacheKey
+Entries:
+	for k := range cache {
+		if !cacheSeen[k.path] {
+			for _, dir := range cacheDirs {
+				if strings.HasPrefix(k.path, dir+string(filepath.Separator)) {
+					continue Entries
+				}
+			}
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].algo < keys[j].algo
+	})
+
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "%s\n", cacheHeader)
+	for _, k := range keys {
+		e := cache[k]
+		fmt.Fprintf(w, "%s %s %d %d %d %d %q\n", k.algo, e.hash, e.size, e.mtime, e.dev, e.ino, k.path)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// cacheDir records that dirhash is hashing the tree rooted at dir,
+// so that saveCache can drop entries for files deleted from it.
+func cacheDir(dir string) {
+	if cache == nil {
+		return
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cacheDirs = append(cacheDirs, abs)
+}
+
+// cachedFilehash is like filehash but uses the cache, if any,
+// for a file whose path, size, modification time, and inode
+// match the ones recorded with its hash using the -algo algorithm.
+func cachedFilehash(file string, info os.FileInfo) (string, error) {
+	if cache == nil {
+		return filehash(file)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	cacheSeen[abs] = true
+	k := cacheKey{*algoFlag, abs}
+	dev, ino := fileID(info)
+	cur := cacheEntry{size: info.Size(), mtime: info.ModTime().UnixNano(), dev: dev, ino: ino}
+	if e, ok := cache[k]; ok && e.size == cur.size && e.mtime == cur.mtime && e.dev == cur.dev && e.ino == cur.ino {
+		if rand.Float64() >= cacheVerify {
+			return e.hash, nil
+		}
+		h, err := filehash(file)
+		if err != nil {
+			return h, err
+		}
+		if h != e.hash {
+			log.Printf("%s: content changed without changing size, time, or inode; cache may be unreliable", file)
+			cache[k] = cacheEntry{hash: h, size: e.size, mtime: e.mtime, dev: e.dev, ino: e.ino}
+		}
+		return h, nil
+	}
+	h, err := filehash(file)
+	if err != nil {
+		delete(cache, k)
+		return h, err
+	}
+	cur.hash = h
+	cache[k] = cur
+	return h, nil
+}
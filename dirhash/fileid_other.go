@@ -0,0 +1,15 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileID returns the device and inode numbers of the file with the given info.
+// They are not available on this system, so the cache relies on size and time alone.
+func fileID(info os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
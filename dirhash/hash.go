@@ -0,0 +1,118 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// An algo is a hash algorithm that can be selected with -algo.
+type algo struct {
+	new func() hash.Hash
+	cmd string // command printing the same hash, for -d
+}
+
+var algos = map[string]algo{
+	"sha256":  {sha256.New, "sha256sum"},
+	"sha512":  {sha512.New, "sha512sum"},
+	"blake2b": {newBlake2b, "b2sum"},
+}
+
+// newBlake2b returns a BLAKE2b-512 hash, the variant that b2sum computes.
+func newBlake2b() hash.Hash {
+	h, _ := blake2b.New512(nil) // cannot fail without a key
+	return h
+}
+
+// newHash returns a new hash using the algorithm selected by -algo.
+func newHash() hash.Hash {
+	return algos[*algoFlag].new()
+}
+
+// A fileHash is the entry for a single file in a tree's list of hashes.
+type fileHash struct {
+	name string // slash-separated path relative to the top of the tree
+	hash string // file hash and metadata, as printed in the list
+}
+
+// printHash prints the hash of the tree named arg,
+// which holds files, listed in the order that filepath.Walk visits them.
+// With -write, it also writes files to the manifest;
+// with -check, it checks files against the manifest instead.
+func printHash(arg string, files []fileHash) {
+	if *checkFlag != "" {
+		if !checkManifest(arg, files) {
+			exit = 1
+		}
+		return
+	}
+	if *writeFlag != "" {
+		writeManifest(files)
+	}
+	if *h1Flag {
+		printH1(arg, files)
+		return
+	}
+	h := newHash()
+	if *debug {
+		fmt.Fprintf(os.Stderr, "%s << 'EOF'\n", algos[*algoFlag].cmd)
+	}
+	for _, f := range files {
+		line := fmt.Sprintf("%s  ./%s\n", f.hash, f.name)
+		if *debug {
+			fmt.Fprint(os.Stderr, line)
+		}
+		io.WriteString(h, line)
+	}
+	if *debug {
+		fmt.Fprintf(os.Stderr, "EOF\n")
+	}
+	fmt.Printf("%x %s\n", h.Sum(nil), arg)
+}
+
+// printH1 prints the hash of the tree named arg in the h1: format
+// used in go.sum files, as computed by golang.org/x/mod/sumdb/dirhash.Hash1:
+// the base64-encoded sha256 of the list of file hashes and names,
+// sorted by name, with each name prefixed by -prefix.
+func printH1(arg string, files []fileHash) {
+	var list []fileHash
+	for _, f := range files {
+		name := path.Join(*prefixFlag, f.name)
+		if strings.Contains(name, "\n") {
+			log.Printf("%s: file name %q contains newline", arg, name)
+			return
+		}
+		list = append(list, fileHash{name, f.hash})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	h := sha256.New()
+	if *debug {
+		fmt.Fprintf(os.Stderr, "sha256sum << 'EOF' | cut -c1-64 | xxd -r -p | base64\n")
+	}
+	for _, f := range list {
+		line := fmt.Sprintf("%s  %s\n", f.hash, f.name)
+		if *debug {
+			fmt.Fprint(os.Stderr, line)
+		}
+		io.WriteString(h, line)
+	}
+	if *debug {
+		fmt.Fprintf(os.Stderr, "EOF\n")
+	}
+	fmt.Printf("h1:%s %s\n", base64.StdEncoding.EncodeToString(h.Sum(nil)), arg)
+}
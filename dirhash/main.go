@@ -6,69 +6,161 @@
 //
 // Usage:
 //
-//	dirhash [-d] [dir ...]
+//	dirhash [-d] [-algo name] [-mode] [-xattr] [-cache file] [dir | archive ...]
+//	dirhash -h1 [-d] [-prefix module@version] [-cache file] [dir | archive ...]
+//	dirhash [-algo name] [-mode] [-xattr] [-cache file] -write manifest dir | archive
+//	dirhash [-algo name] [-mode] [-xattr] [-cache file] -check manifest dir | archive
 //
 // For each directory named on the command line, dirhash prints
 // the hash of the file system tree rooted at that directory.
 //
+// An argument ending in .zip, .tar, .tar.gz, or .tgz names an archive
+// instead of a directory. For an archive, dirhash prints the hash of the
+// tree that extracting the archive into an empty directory would create,
+// without extracting it. File names in the archive are cleaned,
+// so that a/./b and ./a/b both mean a/b, and symbolic links to
+// files in the archive are followed. For zip files, -xattr adds nothing;
+// for tar files, it adds the attributes recorded in SCHILY.xattr PAX records.
+//
 // The hash is computed by considering all files in the tree,
 // in the lexical order used by Go's filepath.Walk, computing
 // the sha256 hash of each, and then computing a sha256 of
 // the list of hashes and file names. If the -d flag is given,
 // dirhash prints to standard error a shell script computing
-// the overall sha256.
+// the overall hash.
 //
 // Except for occasional differences in sort order, "dirhash mydir"
 // is equivalent to
 //
 //	(cd mydir; sha256sum $(find . -type f | sort) | sha256sum)
 //
+// The -algo flag selects a different hash algorithm, used both
+// for the individual files and for the list: sha256 (the default),
+// sha512, or blake2b (BLAKE2b-512, as computed by b2sum).
+//
+// The -h1 flag prints the hash in the "h1:" format used in go.sum files,
+// as computed by golang.org/x/mod/sumdb/dirhash, so that a directory or
+// module zip file can be compared against a go.sum entry. That format
+// lists the files sorted by name, without a leading "./", and encodes
+// the final sha256 in base64. In go.sum, the file names begin with
+// the module path and version, as they do in a module zip file;
+// to hash a directory holding a module's files, use -prefix to add
+// that prefix, as in "dirhash -h1 -prefix golang.org/x/mod@v0.17.0 dir".
+// The -h1 flag cannot be combined with -algo, -mode, or -xattr.
+//
+// By default, the hash depends only on file names and contents,
+// not on file metadata. The -mode and -xattr flags add metadata
+// to each file's line in the list, between the hash and the name.
+// The -mode flag adds the file's permission bits, as "mode=0644".
+// The -xattr flag adds each of the file's extended attributes,
+// in sorted order by name, as xattr:"name"=value, where name is
+// quoted using Go syntax and value is in hexadecimal.
+// For example, with both flags, a file's line might be:
+//
+//	e3b0c442...b855 mode=0644 xattr:"user.origin"=6e6574  ./file
+//
+// Extended attributes are only supported on Linux.
+//
+// The -cache flag names a file recording the hash of each file's content,
+// keyed by the file's path, size, modification time, and inode number.
+// On later runs, dirhash reuses the recorded hash for each file whose
+// metadata is unchanged, reading only the files that have changed,
+// so that repeated runs over large, mostly unchanged trees are fast.
+// As a check against content changed without changing the metadata,
+// dirhash re-reads a random 1% of the unchanged files, reporting any
+// whose content no longer matches the cache.
+// The cache records hashes separately for each -algo setting.
+// It applies only to directories, not archives.
+//
+// The -write flag saves the list of file hashes for a single directory
+// or archive to a manifest file, in addition to printing the overall hash.
+// The -check flag verifies a directory or archive against a manifest
+// written by -write, which must be given the same -algo, -mode, and -xattr
+// flags. It prints "OK" if the tree matches the manifest, or else
+// a line for each file added, removed, or modified since the manifest
+// was written, and then exits with a non-zero status.
+// The -write and -check flags cannot be combined with -h1.
+//
 package main
 
 import (
-	"crypto/sha256"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: dirhash [-d] [dir...]\n")
+	fmt.Fprintf(os.Stderr, "usage: dirhash [-d] [-algo name] [-mode] [-xattr] [-cache file] [dir | archive...]\n")
+	fmt.Fprintf(os.Stderr, "       dirhash -h1 [-d] [-prefix module@version] [-cache file] [dir | archive...]\n")
+	fmt.Fprintf(os.Stderr, "       dirhash [-algo name] [-mode] [-xattr] [-cache file] -write manifest dir | archive\n")
+	fmt.Fprintf(os.Stderr, "       dirhash [-algo name] [-mode] [-xattr] [-cache file] -check manifest dir | archive\n")
 	os.Exit(2)
 }
 
-var debug = flag.Bool("d", false, "print input for overall sha256sum")
+var (
+	debug      = flag.Bool("d", false, "print input for overall hash")
+	modeFlag   = flag.Bool("mode", false, "include permission bits in hash")
+	xattrFlag  = flag.Bool("xattr", false, "include extended attributes in hash")
+	cacheFlag  = flag.String("cache", "", "reuse file hashes recorded in `file`")
+	algoFlag   = flag.String("algo", "sha256", "use hash `algorithm` (sha256, sha512, or blake2b)")
+	h1Flag     = flag.Bool("h1", false, "print Go module h1: hash, as in go.sum")
+	prefixFlag = flag.String("prefix", "", "with -h1, prefix file names with `module@version`")
+	writeFlag  = flag.String("write", "", "write list of file hashes to `manifest`")
+	checkFlag  = flag.String("check", "", "verify files against `manifest`")
+
+	exit = 0
+)
 
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("dirhash: ")
 	flag.Usage = usage
 	flag.Parse()
+	if _, ok := algos[*algoFlag]; !ok {
+		log.Fatalf("unknown hash algorithm %q", *algoFlag)
+	}
+	if *h1Flag && (*algoFlag != "sha256" || *modeFlag || *xattrFlag) || !*h1Flag && *prefixFlag != "" {
+		usage()
+	}
+	if (*writeFlag != "" || *checkFlag != "") && (flag.NArg() != 1 || *h1Flag) || *writeFlag != "" && *checkFlag != "" {
+		usage()
+	}
 
 	args := flag.Args()
 	if len(args) == 0 {
 		args = []string{"."}
 	}
 
-	for _, dir := range args {
-		dirhash(dir)
+	if *cacheFlag != "" {
+		loadCache(*cacheFlag)
+	}
+	for _, arg := range args {
+		if isArchive(arg) {
+			archivehash(arg)
+		} else {
+			dirhash(arg)
+		}
 	}
+	if *cacheFlag != "" {
+		saveCache(*cacheFlag)
+	}
+	os.Exit(exit)
 }
 
 func dirhash(dir string) {
 	dir = filepath.Clean(dir)
-	h := sha256.New()
 	info, err := os.Lstat(dir)
 	if err == nil && info.Mode()&os.ModeSymlink != 0 {
 		log.Printf("%s is a symlink\n", dir)
 		return
 	}
-	if *debug {
-		fmt.Fprintf(os.Stderr, "sha256sum << 'EOF'\n")
-	}
+	cacheDir(dir)
+	var files []fileHash
 	filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
 		if info.Mode()&os.ModeSymlink != 0 {
 			i, err := os.Stat(file)
@@ -85,29 +177,64 @@ func dirhash(dir string) {
 			rel = file[len(dir)+1:]
 		}
 		rel = filepath.ToSlash(rel)
-		fh := filehash(file)
-		if *debug {
-			fmt.Fprintf(os.Stderr, "%s  ./%s\n", fh, rel)
+		var attrs map[string][]byte
+		if *xattrFlag {
+			attrs, err = xattrs(file)
+			if err != nil {
+				log.Fatalf("%s: %v", file, err)
+			}
 		}
-		fmt.Fprintf(h, "%s  ./%s\n", fh, rel)
+		fh, err := cachedFilehash(file, info)
+		if err != nil {
+			log.Print(err)
+		}
+		files = append(files, fileHash{rel, fh + metadata(info, attrs)})
 		return nil
 	})
-	if *debug {
-		fmt.Fprintf(os.Stderr, "EOF\n")
+	printHash(dir, files)
+}
+
+// metadata returns the metadata selected by -mode and -xattr
+// for the file with the given info and extended attributes,
+// formatted as space-prefixed fields.
+func metadata(info os.FileInfo, attrs map[string][]byte) string {
+	var b strings.Builder
+	if *modeFlag {
+		m := uint32(info.Mode().Perm())
+		if info.Mode()&os.ModeSetuid != 0 {
+			m |= 0o4000
+		}
+		if info.Mode()&os.ModeSetgid != 0 {
+			m |= 0o2000
+		}
+		if info.Mode()&os.ModeSticky != 0 {
+			m |= 0o1000
+		}
+		fmt.Fprintf(&b, " mode=%04o", m)
 	}
-	fmt.Printf("%x %s\n", h.Sum(nil), dir)
+	if *xattrFlag {
+		var names []string
+		for name := range attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, " xattr:%q=%x", name, attrs[name])
+		}
+	}
+	return b.String()
 }
 
-func filehash(file string) string {
-	h := sha256.New()
+// filehash returns the hash of the content of file, in hexadecimal.
+// If there is an error reading file, filehash returns the hash
+// of the content read before the error, along with the error.
+func filehash(file string) (string, error) {
+	h := newHash()
 	f, err := os.Open(file)
 	if err != nil {
-		log.Print(err)
+		return fmt.Sprintf("%x", h.Sum(nil)), err
 	}
+	defer f.Close()
 	_, err = io.Copy(h, f)
-	if err != nil {
-		log.Print(err)
-	}
-	f.Close()
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%x", h.Sum(nil)), err
 }
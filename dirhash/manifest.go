@@ -0,0 +1,116 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// manifestHeader returns the first line of a manifest written with
+// the current flags. Checking a manifest requires the same flags,
+// since they determine the form of the hash lines.
+func manifestHeader() string {
+	h := "# dirhash manifest -algo " + *algoFlag
+	if *modeFlag {
+		h += " -mode"
+	}
+	if *xattrFlag {
+		h += " -xattr"
+	}
+	return h
+}
+
+// writeManifest writes the list of files to the -write manifest file.
+func writeManifest(files []fileHash) {
+	f, err := os.Create(*writeFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "%s\n", manifestHeader())
+	for _, fh := range files {
+		if strings.Contains(fh.name, "\n") {
+			log.Fatalf("cannot write manifest: file name %q contains newline", fh.name)
+		}
+		fmt.Fprintf(w, "%s  ./%s\n", fh.hash, fh.name)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readManifest reads the -check manifest file,
+// returning the hash line for each file name.
+func readManifest() map[string]string {
+	f, err := os.Open(*checkFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	hashes := make(map[string]string)
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	if !s.Scan() || !strings.HasPrefix(s.Text(), "# dirhash manifest ") {
+		log.Fatalf("%s: not a dirhash manifest", *checkFlag)
+	}
+	if h := s.Text(); h != manifestHeader() {
+		log.Fatalf("%s: manifest written with flags %s; check with the same flags", *checkFlag, strings.TrimPrefix(h, "# dirhash manifest "))
+	}
+	for lineno := 2; s.Scan(); lineno++ {
+		hash, name, ok := strings.Cut(s.Text(), "  ./")
+		if !ok {
+			log.Fatalf("%s:%d: malformed manifest line", *checkFlag, lineno)
+		}
+		hashes[name] = hash
+	}
+	if err := s.Err(); err != nil {
+		log.Fatalf("reading %s: %v", *checkFlag, err)
+	}
+	return hashes
+}
+
+// checkManifest compares the list of files in the tree named arg
+// with the -check manifest file, printing the files that have been
+// added, removed, or modified. It reports whether the two match.
+func checkManifest(arg string, files []fileHash) bool {
+	want := readManifest()
+	var diffs []string
+	for _, fh := range files {
+		old, ok := want[fh.name]
+		switch {
+		case !ok:
+			diffs = append(diffs, "added "+fh.name)
+		case old != fh.hash:
+			diffs = append(diffs, "modified "+fh.name)
+		}
+		delete(want, fh.name)
+	}
+	for name := range want {
+		diffs = append(diffs, "removed "+name)
+	}
+	if len(diffs) == 0 {
+		fmt.Printf("%s: OK\n", arg)
+		return true
+	}
+	// Sort by file name, not by kind of change.
+	sort.Slice(diffs, func(i, j int) bool {
+		_, a, _ := strings.Cut(diffs[i], " ")
+		_, b, _ := strings.Cut(diffs[j], " ")
+		return a < b
+	})
+	for _, d := range diffs {
+		fmt.Printf("%s: %s\n", arg, d)
+	}
+	return false
+}
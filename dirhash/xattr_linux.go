@@ -0,0 +1,52 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// xattrs returns the extended attributes of file, as a map from name to value.
+func xattrs(file string) (map[string][]byte, error) {
+	names, err := xattrGet(func(buf []byte) (int, error) { return syscall.Listxattr(file, buf) })
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string][]byte)
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		val, err := xattrGet(func(buf []byte) (int, error) { return syscall.Getxattr(file, string(name), buf) })
+		if err != nil {
+			return nil, err
+		}
+		m[string(name)] = val
+	}
+	return m, nil
+}
+
+// xattrGet calls f, which is Listxattr or Getxattr, with a large enough buffer.
+func xattrGet(f func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := f(nil)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n)
+		n, err = f(buf)
+		if err == syscall.ERANGE {
+			continue // grew between calls
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// xattrs returns the extended attributes of file, as a map from name to value.
+func xattrs(file string) (map[string][]byte, error) {
+	return nil, fmt.Errorf("extended attributes not supported on %s", runtime.GOOS)
+}
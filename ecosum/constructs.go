@@ -0,0 +1,289 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// constructs lists the language constructs detected by findConstructs,
+// in the order they are reported.
+var constructs = []string{
+	"generic declaration",
+	"generic instantiation",
+	"range over int",
+	"range over func",
+	"min/max builtin",
+	"clear builtin",
+}
+
+// iterFuncs lists standard library functions returning iterators,
+// used to recognize range-over-func loops syntactically.
+var iterFuncs = map[string]bool{
+	"ast.Preorder":          true,
+	"bytes.FieldsFuncSeq":   true,
+	"bytes.FieldsSeq":       true,
+	"bytes.Lines":           true,
+	"bytes.SplitAfterSeq":   true,
+	"bytes.SplitSeq":        true,
+	"maps.All":              true,
+	"maps.Keys":             true,
+	"maps.Values":           true,
+	"slices.All":            true,
+	"slices.Backward":       true,
+	"slices.Chunk":          true,
+	"slices.Values":         true,
+	"strings.FieldsFuncSeq": true,
+	"strings.FieldsSeq":     true,
+	"strings.Lines":         true,
+	"strings.SplitAfterSeq": true,
+	"strings.SplitSeq":      true,
+}
+
+// findConstructs parses the source snippet src and returns
+// the newer language constructs it uses, in the order of constructs.
+//
+// A snippet is usually a few lines excerpted from a larger file,
+// so findConstructs tries parsing it both as top-level declarations
+// and as statements, and it inspects whatever partial syntax tree
+// the parser produces. Detection is purely syntactic: for example,
+// a range loop counts as range over func only when ranging over a
+// function literal or a call to a known iterator function such as slices.All,
+// and it counts as range over int only when ranging over an integer constant,
+// an arithmetic expression, a call to len or cap, an integer conversion,
+// or a variable declared in the snippet with one of those values or an integer type.
+// Similarly, an instantiation with a single type argument, as in F[int],
+// is only recognized when the argument is clearly a type.
+func findConstructs(src string) []string {
+	found := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, wrapped := range []string{
+		"package p\n" + src + "\n",
+		"package p\nfunc _() {\n" + src + "\n}\n",
+	} {
+		f, _ := parser.ParseFile(fset, "src.go", wrapped, parser.AllErrors|parser.SkipObjectResolution)
+		if f == nil {
+			continue
+		}
+		ints := intVars(f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			inspectConstruct(n, ints, found)
+			return true
+		})
+	}
+	var list []string
+	for _, c := range constructs {
+		if found[c] {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// intTypes lists the predeclared integer types.
+var intTypes = map[string]bool{
+	"byte":    true,
+	"int":     true,
+	"int8":    true,
+	"int16":   true,
+	"int32":   true,
+	"int64":   true,
+	"rune":    true,
+	"uint":    true,
+	"uint8":   true,
+	"uint16":  true,
+	"uint32":  true,
+	"uint64":  true,
+	"uintptr": true,
+}
+
+// predeclaredTypes lists the predeclared non-integer types.
+var predeclaredTypes = map[string]bool{
+	"any":        true,
+	"bool":       true,
+	"comparable": true,
+	"complex64":  true,
+	"complex128": true,
+	"error":      true,
+	"float32":    true,
+	"float64":    true,
+	"string":     true,
+}
+
+// intVars returns the names of the variables declared in f
+// that are clearly integers: ones declared with an integer type
+// or initialized from an integer expression.
+// It ignores scoping, which is good enough for short snippets.
+func intVars(f *ast.File) map[string]bool {
+	ints := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE && len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && isIntExpr(n.Rhs[i], ints) {
+						ints[id.Name] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for i, id := range n.Names {
+				if isIntType(n.Type) || n.Type == nil && i < len(n.Values) && isIntExpr(n.Values[i], ints) {
+					ints[id.Name] = true
+				}
+			}
+		case *ast.Field:
+			if isIntType(n.Type) {
+				for _, id := range n.Names {
+					ints[id.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	return ints
+}
+
+// isIntType reports whether x is a predeclared integer type.
+func isIntType(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && intTypes[id.Name]
+}
+
+// isIntExpr reports whether x is clearly integer-valued,
+// given the integer variables ints.
+func isIntExpr(x ast.Expr, ints map[string]bool) bool {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return x.Kind == token.INT
+	case *ast.Ident:
+		return ints[x.Name]
+	case *ast.ParenExpr:
+		return isIntExpr(x.X, ints)
+	case *ast.UnaryExpr:
+		return (x.Op == token.SUB || x.Op == token.XOR) && isIntExpr(x.X, ints)
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case token.ADD:
+			// Strings can be added too, so require an integer operand.
+			return isIntExpr(x.X, ints) || isIntExpr(x.Y, ints)
+		case token.SUB, token.MUL, token.QUO, token.REM,
+			token.AND, token.OR, token.XOR, token.AND_NOT:
+			// Could be floating-point, but range over float is invalid.
+			return true
+		case token.SHL, token.SHR:
+			return true
+		}
+	case *ast.CallExpr:
+		if id, ok := x.Fun.(*ast.Ident); ok {
+			return id.Name == "len" || id.Name == "cap" || intTypes[id.Name]
+		}
+	}
+	return false
+}
+
+// isTypeExpr reports whether x is clearly a type,
+// as opposed to a value that might be used as an index.
+func isTypeExpr(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return intTypes[x.Name] || predeclaredTypes[x.Name]
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType,
+		*ast.InterfaceType, *ast.StructType:
+		return true
+	}
+	return false
+}
+
+func inspectConstruct(n ast.Node, ints map[string]bool, found map[string]bool) {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		if n.Type.TypeParams != nil && len(n.Type.TypeParams.List) > 0 {
+			found["generic declaration"] = true
+		}
+	case *ast.TypeSpec:
+		if n.TypeParams != nil && len(n.TypeParams.List) > 0 {
+			found["generic declaration"] = true
+		}
+	case *ast.IndexListExpr:
+		found["generic instantiation"] = true
+	case *ast.IndexExpr:
+		if isTypeExpr(n.Index) {
+			found["generic instantiation"] = true
+		}
+	case *ast.RangeStmt:
+		if isIntExpr(n.X, ints) {
+			found["range over int"] = true
+			break
+		}
+		switch x := n.X.(type) {
+		case *ast.FuncLit:
+			found["range over func"] = true
+		case *ast.CallExpr:
+			if sel, ok := x.Fun.(*ast.SelectorExpr); ok {
+				if pkg, ok := sel.X.(*ast.Ident); ok && iterFuncs[pkg.Name+"."+sel.Sel.Name] {
+					found["range over func"] = true
+				}
+			}
+		}
+	case *ast.CallExpr:
+		if id, ok := n.Fun.(*ast.Ident); ok {
+			switch id.Name {
+			case "min", "max":
+				found["min/max builtin"] = true
+			case "clear":
+				found["clear builtin"] = true
+			}
+		}
+	}
+}
+
+// A ConstructCount is the number of diagnostics whose source uses a construct.
+type ConstructCount struct {
+	Name  string
+	Count int
+}
+
+// countConstructs returns the number of diagnostics in diags using each construct,
+// in decreasing order of count, followed by the number using none.
+func countConstructs(diags []*Diagnostic) []ConstructCount {
+	var c constructCounter
+	for _, d := range diags {
+		c.add(d)
+	}
+	return c.list()
+}
+
+// A constructCounter counts the diagnostics using each construct.
+type constructCounter struct {
+	counts map[string]int
+	none   int
+}
+
+// add counts the constructs used by d.
+func (cc *constructCounter) add(d *Diagnostic) {
+	if cc.counts == nil {
+		cc.counts = make(map[string]int)
+	}
+	for _, c := range d.Constructs {
+		cc.counts[c]++
+	}
+	if len(d.Constructs) == 0 {
+		cc.none++
+	}
+}
+
+// list returns the counts, as described in countConstructs.
+func (cc *constructCounter) list() []ConstructCount {
+	var list []ConstructCount
+	for _, c := range constructs {
+		if cc.counts[c] > 0 {
+			list = append(list, ConstructCount{c, cc.counts[c]})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Count > list[j].Count
+	})
+	return append(list, ConstructCount{"none detected", cc.none})
+}
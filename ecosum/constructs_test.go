@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var findConstructsTests = []struct {
+	src  string
+	want []string
+}{
+	{"x := 1\nfmt.Println(x)", nil},
+	{"func F[T any](x T) T { return x }", []string{"generic declaration"}},
+	{"type List[T any] struct { next *List[T] }", []string{"generic declaration"}}, // List[T] could be an index
+	{"m := Map[string, int]{}", []string{"generic instantiation"}},
+	{"s := Sum[int](xs)", []string{"generic instantiation"}},
+	{"f := Apply[[]byte]", []string{"generic instantiation"}},
+	{"v := m[k]", nil},
+	{"v := xs[i]", nil},
+	{"for i := range 10 {\n}", []string{"range over int"}},
+	{"for i := range len(xs) {\n}", []string{"range over int"}},
+	{"for range len(xs) {\n}", []string{"range over int"}},
+	{"for i := range n - 1 {\n}", []string{"range over int"}},
+	{"n := len(xs)\nfor i := range n {\n}", []string{"range over int"}},
+	{"func f(n int) {\n\tfor i := range n {\n\t}\n}", []string{"range over int"}},
+	{"var n uint8\nfor range n {\n}", []string{"range over int"}},
+	{"for i := range int64(k) {\n}", []string{"range over int"}},
+	{"for i := range xs {\n}", nil},
+	{"for i := range a + b {\n}", nil},
+	{"for x := range slices.Values(xs) {\n}", []string{"range over func"}},
+	{"for x := range func(yield func(int) bool) {} {\n}", []string{"range over func"}},
+	{"for x := range it.All() {\n}", nil},
+	{"x := min(a, b)\ny := max(a, b)", []string{"min/max builtin"}},
+	{"clear(m)", []string{"clear builtin"}},
+	{
+		// Incomplete snippet, as excerpted from the middle of a function.
+		"\tfor i := range len(xs) {\n\t\tclear(xs[i]",
+		[]string{"range over int", "clear builtin"},
+	},
+}
+
+func TestFindConstructs(t *testing.T) {
+	for _, tt := range findConstructsTests {
+		got := findConstructs(tt.src)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findConstructs(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// A DiffSummary is the summary printed by ecosum -diff.
+type DiffSummary struct {
+	Grep      string
+	Old, New  *Summary
+	Added     DiffGroup
+	Removed   DiffGroup
+	Unchanged DiffGroup
+}
+
+// A DiffGroup is one category of diagnostics in a diff:
+// the number of diagnostics and a sample of them.
+type DiffGroup struct {
+	Count   int
+	Samples []*Diagnostic
+}
+
+// diff prints a comparison of the diagnostics in the old and new report files.
+func diff(oldFile, newFile string, re *regexp.Regexp) {
+	oldSum, oldDiags := load(oldFile, re)
+	newSum, newDiags := load(newFile, re)
+	added, removed, unchanged := diffDiags(oldDiags, newDiags)
+	sum := &DiffSummary{
+		Grep:      *grep,
+		Old:       oldSum,
+		New:       newSum,
+		Added:     DiffGroup{len(added), sample(added, *samples)},
+		Removed:   DiffGroup{len(removed), sample(removed, *samples)},
+		Unchanged: DiffGroup{len(unchanged), sample(unchanged, *samples)},
+	}
+	var buf bytes.Buffer
+	if err := diffTmpl.Execute(&buf, sum); err != nil {
+		log.Fatalf("internal template error: %v", err)
+	}
+	os.Stdout.Write(buf.Bytes())
+}
+
+// diffDiags matches the diagnostics in oldList against those in newList,
+// returning the ones only in newList (added), only in oldList (removed),
+// and the new forms of ones in both (unchanged).
+//
+// Matching ignores module versions, since the two reports may have
+// analyzed different versions of a module. A diagnostic in oldList matches
+// a diagnostic in newList at the same file and line with the same message.
+// Failing that, it matches the diagnostic in the same file with the
+// nearest line and a similar message, meaning the same message
+// after replacing all numbers, which absorbs line shifts from edits
+// elsewhere in the file as well as line numbers quoted in messages.
+func diffDiags(oldList, newList []*Diagnostic) (added, removed, unchanged []*Diagnostic) {
+	type exactKey struct {
+		file string
+		line int
+		msg  string
+	}
+	type fuzzyKey struct {
+		file string
+		msg  string
+	}
+
+	matched := make(map[*Diagnostic]bool)
+	exact := make(map[exactKey][]*Diagnostic)
+	fuzzy := make(map[fuzzyKey][]*Diagnostic)
+	for _, d := range newList {
+		f := fileKey(d)
+		exact[exactKey{f, d.Line, d.Message}] = append(exact[exactKey{f, d.Line, d.Message}], d)
+		fuzzy[fuzzyKey{f, similarMessage(d.Message)}] = append(fuzzy[fuzzyKey{f, similarMessage(d.Message)}], d)
+	}
+
+	var rest []*Diagnostic
+	for _, d := range oldList {
+		k := exactKey{fileKey(d), d.Line, d.Message}
+		if list := exact[k]; len(list) > 0 {
+			matched[list[0]] = true
+			unchanged = append(unchanged, list[0])
+			exact[k] = list[1:]
+			continue
+		}
+		rest = append(rest, d)
+	}
+
+	for _, d := range rest {
+		var best *Diagnostic
+		for _, n := range fuzzy[fuzzyKey{fileKey(d), similarMessage(d.Message)}] {
+			if !matched[n] && (best == nil || abs(n.Line-d.Line) < abs(best.Line-d.Line)) {
+				best = n
+			}
+		}
+		if best == nil {
+			removed = append(removed, d)
+			continue
+		}
+		matched[best] = true
+		unchanged = append(unchanged, best)
+	}
+
+	for _, d := range newList {
+		if !matched[d] {
+			added = append(added, d)
+		}
+	}
+	return added, removed, unchanged
+}
+
+// fileKey returns d's file name with the module version removed:
+// "example.com/mod@v1.2.3/x/y.go" becomes "example.com/mod/x/y.go".
+func fileKey(d *Diagnostic) string {
+	i := strings.Index(d.File, "@")
+	if i < 0 {
+		return d.File
+	}
+	j := strings.Index(d.File[i:], "/")
+	if j < 0 {
+		return d.File[:i]
+	}
+	return d.File[:i] + d.File[i+j:]
+}
+
+var numRE = regexp.MustCompile(`[0-9]+`)
+
+// similarMessage returns msg with all numbers replaced by N,
+// so that messages differing only in numbers compare equal.
+func similarMessage(msg string) string {
+	return numRE.ReplaceAllString(msg, "N")
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+var diffTmpl = template.Must(template.New("").Funcs(
+	template.FuncMap{
+		"inc":  func(x int) int { return x + 1 },
+		"code": func(s string) string { return "```" + s + "```" },
+		"join": strings.Join,
+		"args": func(x ...any) []any { return x },
+	},
+).Parse(`
+Old: {{.Old.TotalSamples}} diagnostics{{if .Grep}} matching {{code .Grep}}{{end}} in {{.Old.BadModules}} of {{.Old.Modules}} modules.
+New: {{.New.TotalSamples}} diagnostics{{if .Grep}} matching {{code .Grep}}{{end}} in {{.New.BadModules}} of {{.New.Modules}} modules.
+
+- added: {{.Added.Count}}
+- removed: {{.Removed.Count}}
+- unchanged: {{.Unchanged.Count}}
+{{template "group" (args "added" .Added)}}
+{{- template "group" (args "removed" .Removed)}}
+{{- template "group" (args "unchanged" .Unchanged)}}
+
+{{- define "group"}}
+{{- $name := index . 0}}{{$g := index . 1}}
+{{- if $g.Samples}}
+{{- if eq (len $g.Samples) $g.Count}}<details><summary>All {{$name}} diagnostics.</summary>
+{{- else}}<details><summary>{{len $g.Samples}} randomly sampled {{$name}} diagnostics.</summary>
+{{- end}}
+
+{{range $i, $d := $g.Samples}}({{inc $i}}) [{{$d.Position}}]({{$d.URL}}):
+{{$d.Message}}
+{{- if $d.Constructs}} (uses {{join $d.Constructs ", "}}){{end}}
+{{$d.SourceQuote}}
+{{end}}
+
+</details>
+
+{{end}}
+{{- end}}
+`))
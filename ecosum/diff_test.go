@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffDiags(t *testing.T) {
+	diag := func(file string, line int, msg string) *Diagnostic {
+		return &Diagnostic{File: file, Line: line, Message: msg}
+	}
+	oldList := []*Diagnostic{
+		diag("m@v1.0.0/a.go", 10, "same"),
+		diag("m@v1.0.0/a.go", 20, "moved"),
+		diag("m@v1.0.0/a.go", 30, "x declared on line 29"),
+		diag("m@v1.0.0/b.go", 5, "gone"),
+		diag("m@v1.0.0/b.go", 6, "dup"),
+		diag("m@v1.0.0/b.go", 6, "dup"),
+	}
+	newList := []*Diagnostic{
+		diag("m@v1.1.0/a.go", 10, "same"),
+		diag("m@v1.1.0/a.go", 12, "moved"),
+		diag("m@v1.1.0/a.go", 25, "moved"),
+		diag("m@v1.1.0/a.go", 32, "x declared on line 31"),
+		diag("m@v1.1.0/b.go", 6, "dup"),
+		diag("m@v1.1.0/c.go", 1, "new"),
+	}
+	added, removed, unchanged := diffDiags(oldList, newList)
+	if want := []*Diagnostic{newList[1], newList[5]}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := []*Diagnostic{oldList[3], oldList[5]}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if want := []*Diagnostic{newList[0], newList[4], newList[2], newList[3]}; !reflect.DeepEqual(unchanged, want) {
+		t.Errorf("unchanged = %v, want %v", unchanged, want)
+	}
+}
+
+func TestFileKey(t *testing.T) {
+	for _, tt := range []struct{ file, want string }{
+		{"example.com/mod@v1.2.3/x/y.go", "example.com/mod/x/y.go"},
+		{"example.com/mod/v2@v2.0.0-20230101000000-abcdef123456/y.go", "example.com/mod/v2/y.go"},
+		{"noversion/y.go", "noversion/y.go"},
+	} {
+		if got := fileKey(&Diagnostic{File: tt.file}); got != tt.want {
+			t.Errorf("fileKey(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// groupings lists the valid -by arguments.
+var groupings = []string{"module", "message", "analyzer", "package"}
+
+// maxGroups is the number of most frequent values listed in a grouping table.
+const maxGroups = 25
+
+// A Grouping is a frequency table of diagnostics grouped by one field.
+type Grouping struct {
+	By       string       // field grouped by: module, message, analyzer, or package
+	Distinct int          // number of distinct values
+	Top      []GroupCount // most frequent values, in decreasing order of count
+	Other    int          // number of diagnostics with values not in Top
+}
+
+// A GroupCount is the number of diagnostics with a given value.
+type GroupCount struct {
+	Value   string
+	Count   int
+	Percent float64
+}
+
+// validGrouping reports whether by is one of groupings.
+func validGrouping(by string) bool {
+	for _, g := range groupings {
+		if by == g {
+			return true
+		}
+	}
+	return false
+}
+
+// groupKey returns the value of the field named by by in d.
+func groupKey(d *Diagnostic, by string) string {
+	switch by {
+	case "module":
+		return d.Module
+	case "message":
+		return d.Message
+	case "analyzer":
+		return d.AnalyzerName
+	case "package":
+		// The package ID can include a variant, as in "p [p.test]".
+		id, _, _ := strings.Cut(d.PackageID, " ")
+		return id
+	}
+	panic("unknown grouping " + by)
+}
+
+// groupBy returns the frequency table of diags grouped by the field named by by.
+func groupBy(diags []*Diagnostic, by string) *Grouping {
+	g := newGrouper(by)
+	for _, d := range diags {
+		g.add(d)
+	}
+	return g.grouping()
+}
+
+// A grouper counts diagnostics by the value of one field.
+type grouper struct {
+	by     string
+	counts map[string]int
+	total  int
+}
+
+func newGrouper(by string) *grouper {
+	return &grouper{by: by, counts: make(map[string]int)}
+}
+
+// add counts d.
+func (g *grouper) add(d *Diagnostic) {
+	g.counts[groupKey(d, g.by)]++
+	g.total++
+}
+
+// grouping returns the frequency table of the diagnostics counted so far.
+func (g *grouper) grouping() *Grouping {
+	gr := &Grouping{By: g.by, Distinct: len(g.counts)}
+	var list []GroupCount
+	for v, n := range g.counts {
+		list = append(list, GroupCount{Value: v, Count: n, Percent: 100 * float64(n) / float64(g.total)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > maxGroups {
+		for _, c := range list[maxGroups:] {
+			gr.Other += c.Count
+		}
+		list = list[:maxGroups]
+	}
+	gr.Top = list
+	return gr
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+	var diags []*Diagnostic
+	for _, pkg := range []string{"a", "b [b.test]", "b", "c", "b", "a"} {
+		diags = append(diags, &Diagnostic{PackageID: pkg})
+	}
+	g := groupBy(diags, "package")
+	want := &Grouping{
+		By:       "package",
+		Distinct: 3,
+		Top: []GroupCount{
+			{"b", 3, 50},
+			{"a", 2, 100 * 2 / 6.0},
+			{"c", 1, 100 * 1 / 6.0},
+		},
+	}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("groupBy:\nhave %+v\nwant %+v", g, want)
+	}
+}
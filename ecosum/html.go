@@ -0,0 +1,156 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A ModuleSamples is the list of sampled diagnostics in a single module,
+// shown as one collapsible section of the HTML report.
+type ModuleSamples struct {
+	Module  string
+	Samples []*Diagnostic
+}
+
+// groupByModule returns the samples grouped by module, sorted by module path.
+func groupByModule(samples []*Diagnostic) []ModuleSamples {
+	byMod := make(map[string][]*Diagnostic)
+	for _, d := range samples {
+		byMod[d.Module] = append(byMod[d.Module], d)
+	}
+	var list []ModuleSamples
+	for m, diags := range byMod {
+		list = append(list, ModuleSamples{m, diags})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Module < list[j].Module })
+	return list
+}
+
+// writeHTML writes the summary to w as a standalone HTML page.
+func writeHTML(w io.Writer, sum *Summary) error {
+	return htmlTmpl.Execute(w, sum)
+}
+
+// highlight returns the Go source snippet src as HTML,
+// with keywords, literals, and comments marked for syntax highlighting.
+// Snippets are often incomplete, so highlight uses only the scanner:
+// it marks tokens without needing to parse the surrounding code.
+func highlight(src string) template.HTML {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("src.go", -1, len(src))
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+
+	var b strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var class, text string
+		switch {
+		case tok.IsKeyword():
+			class, text = "kw", tok.String()
+		case tok == token.COMMENT:
+			class, text = "com", lit
+		case tok == token.STRING || tok == token.CHAR:
+			class, text = "str", lit
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class, text = "num", lit
+		default:
+			continue
+		}
+		off := file.Offset(pos)
+		end := off + len(text)
+		if off < last || end > len(src) || src[off:end] != text {
+			// Scanner normalized the text (for example, removing \r);
+			// leave this token unmarked.
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(src[last:off]))
+		b.WriteString(`<span class="` + class + `">`)
+		b.WriteString(template.HTMLEscapeString(text))
+		b.WriteString(`</span>`)
+		last = end
+	}
+	b.WriteString(template.HTMLEscapeString(src[last:]))
+	return template.HTML(b.String())
+}
+
+var htmlTmpl = template.Must(template.New("").Funcs(
+	template.FuncMap{
+		"join":          strings.Join,
+		"highlight":     highlight,
+		"groupByModule": groupByModule,
+	},
+).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Go ecosystem analysis summary</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+summary { cursor: pointer; margin: 0.5em 0; }
+details details { margin-left: 1.5em; }
+.diag { margin: 1em 0; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+.kw { color: #0000c0; font-weight: bold; }
+.str { color: #a31515; }
+.num { color: #098658; }
+.com { color: #008000; font-style: italic; }
+td.count { text-align: right; }
+th, td { padding: 0 0.5em; }
+</style>
+</head>
+<body>
+<p>{{.Modules}} modules analyzed.</p>
+<p>{{.TotalSamples}} diagnostics generated{{if .Grep}} matching <code>{{.Grep}}</code>{{end}} in {{.BadModules}} modules.</p>
+{{- if .Constructs}}
+<p>Language constructs in diagnostic source (approximate):</p>
+<ul>
+{{- range .Constructs}}
+<li>{{.Name}}: {{.Count}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Grouping}}
+<p>Diagnostics by {{.By}} ({{.Distinct}} distinct):</p>
+<table>
+<tr><th>Count</th><th>%</th><th>{{.By}}</th></tr>
+{{- range .Top}}
+<tr><td class="count">{{.Count}}</td><td class="count">{{printf "%.1f" .Percent}}</td><td>{{.Value}}</td></tr>
+{{- end}}
+{{- if .Other}}
+<tr><td class="count">{{.Other}}</td><td></td><td>(others)</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Samples}}
+<details open>
+{{- if eq (len .Samples) .TotalSamples}}<summary>All diagnostics.</summary>
+{{- else}}<summary>{{len .Samples}} randomly sampled diagnostics.</summary>
+{{- end}}
+{{- range groupByModule .Samples}}
+<details>
+<summary>{{.Module}} ({{len .Samples}})</summary>
+{{- range .Samples}}
+<div class="diag">
+<a href="{{.URL}}">{{.Position}}</a>: {{.Message}}
+{{- if .Constructs}} (uses {{join .Constructs ", "}}){{end}}
+{{- if .Snippet}}
+<pre>{{highlight .Snippet}}</pre>
+{{- end}}
+</div>
+{{- end}}
+</details>
+{{- end}}
+</details>
+{{- end}}
+</body>
+</html>
+`))
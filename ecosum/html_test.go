@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+var highlightTests = []struct {
+	src  string
+	want string
+}{
+	{"x := y", "x := y"},
+	{
+		"if x < 10 {",
+		`<span class="kw">if</span> x &lt; <span class="num">10</span> {`,
+	},
+	{
+		"return \"a<b\" // done",
+		`<span class="kw">return</span> <span class="str">&#34;a&lt;b&#34;</span> <span class="com">// done</span>`,
+	},
+	{
+		// Unterminated string in an excerpt: still escaped.
+		"s := `<x",
+		"s := <span class=\"str\">`&lt;x</span>",
+	},
+}
+
+func TestHighlight(t *testing.T) {
+	for _, tt := range highlightTests {
+		got := string(highlight(tt.src))
+		if got != tt.want {
+			t.Errorf("highlight(%q):\nhave %s\nwant %s", tt.src, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,9 @@
 //
 // Usage:
 //
-//	ecosum [-g regexp] [-n max] [-s seed] [-q] report.json
+//	ecosum [-by field] [-c] [-g regexp] [-html] [-n max] [-per-module max] [-s seed] [-stratify] [-q] report.json
+//	ecosum -stream [-by field] [-c] [-g regexp] [-html] [-n max] [-s seed] [-q] report.json
+//	ecosum -diff [-c] [-g regexp] [-n max] [-per-module max] [-s seed] [-stratify] [-q] old.json new.json
 //
 // The Go ecosystem pipeline runs analysis programs, such as new vet analyzers,
 // on the latest versions of public Go packages. (For security reasons, it is currently
@@ -12,11 +14,59 @@
 // Ecosum prints a report with statistics and then a random sample of 100 diagnostics.
 // The number of diagnostics can be changed with the -n flag. A negative maximum sets no limit.
 //
+// The sampling picks a module at random and then one of its diagnostics,
+// so that modules with many diagnostics do not dominate the sample.
+// The -per-module flag also limits the sample to at most max diagnostics
+// from any one module. The -stratify flag clusters the diagnostics by
+// message pattern, treating messages that differ only in identifiers,
+// numbers, or quoted text as the same, and picks a cluster at random
+// before picking a module, so that the sample covers the different kinds
+// of diagnostics more evenly instead of mostly showing the most common one.
+//
+// By default ecosum holds all the matching diagnostics in memory,
+// which can be too much for a full ecosystem report of many gigabytes.
+// The -stream flag reads the report in a single pass, keeping only
+// a uniform random sample of max diagnostics (reservoir sampling),
+// along with the counts for -c and -by, so that memory use is bounded
+// by the sample size (and, for -by, the number of distinct values).
+// Streaming samples diagnostics directly rather than module first,
+// so it cannot be combined with -per-module or -stratify,
+// and it requires a non-negative -n.
+//
 // By default ecosum considers all diagnostic errors in the report. The -g (grep) flag
 // only considers diagnostics with messages matching regexp.
 //
+// The -c flag categorizes the diagnostics by the newer language constructs,
+// such as generics or range-over-func loops, used in each diagnostic's
+// source snippet. The report then includes the number of diagnostics
+// using each construct, and each sample lists the constructs it uses.
+// This is useful for analyzers tied to new language features.
+// Detection parses the snippets, which are often incomplete,
+// so the counts are approximate.
+//
+// The -by flag adds a frequency table grouping the diagnostics by field,
+// which is one of module, message, analyzer, or package. The table lists
+// the most frequent values, with the number and percentage of diagnostics
+// for each, showing the distribution of the diagnostics before sampling.
+// For example, -by message shows the most common messages,
+// and -by module shows the modules with the most diagnostics.
+//
 // The output is formatted as Markdown that can be pasted into a GitHub issue
 // but is also mostly human-readable for direct use.
+//
+// The -html flag formats the output instead as a standalone HTML page,
+// for sharing outside GitHub. The page groups the sampled diagnostics
+// into a collapsible section per module, links each diagnostic to its
+// source on go-mod-viewer.appspot.com, and highlights the Go syntax
+// in the source snippets.
+//
+// The -diff flag compares two reports, such as the results of two versions
+// of an analyzer, and prints the number of diagnostics added in new.json,
+// removed from old.json, and unchanged between them, along with a random sample
+// of up to max diagnostics of each kind. Diagnostics are matched by file,
+// ignoring the module version, and then by line and message; a diagnostic
+// that moved to a nearby line, or whose message differs only in numbers,
+// still counts as unchanged.
 package main
 
 import (
@@ -35,16 +85,25 @@ import (
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: ecosum [-g regexp] [-n max] [-s seed] [-q] report.json\n")
+	fmt.Fprintf(os.Stderr, "usage: ecosum [-by field] [-c] [-g regexp] [-html] [-n max] [-per-module max] [-s seed] [-stratify] [-q] report.json\n")
+	fmt.Fprintf(os.Stderr, "       ecosum -stream [-by field] [-c] [-g regexp] [-html] [-n max] [-s seed] [-q] report.json\n")
+	fmt.Fprintf(os.Stderr, "       ecosum -diff [-c] [-g regexp] [-n max] [-per-module max] [-s seed] [-stratify] [-q] old.json new.json\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
 
 var (
-	grep    = flag.String("g", "", "only consider diagnostics matching `regexp`")
-	seed    = flag.Int64("s", 0, "seed random number generator with `seed`")
-	samples = flag.Int("n", 100, "print at most `max` sample diagnostics (-1 for unlimited)")
-	quiet   = flag.Bool("q", false, "quiet mode: do not print source listings")
+	grep      = flag.String("g", "", "only consider diagnostics matching `regexp`")
+	seed      = flag.Int64("s", 0, "seed random number generator with `seed`")
+	samples   = flag.Int("n", 100, "print at most `max` sample diagnostics (-1 for unlimited)")
+	quiet     = flag.Bool("q", false, "quiet mode: do not print source listings")
+	classify  = flag.Bool("c", false, "categorize diagnostics by language constructs in source")
+	htmlOut   = flag.Bool("html", false, "print report as standalone HTML")
+	diffMode  = flag.Bool("diff", false, "compare diagnostics in two reports")
+	by        = flag.String("by", "", "group diagnostics by `field` (module, message, analyzer, or package)")
+	perModule = flag.Int("per-module", 0, "sample at most `max` diagnostics from each module (0 for unlimited)")
+	stratify  = flag.Bool("stratify", false, "sample evenly across message patterns")
+	stream    = flag.Bool("stream", false, "read report in a single pass with memory bounded by sample size")
 )
 
 var posRE = regexp.MustCompile(`^/tmp/modules/([^:]*):([0-9]+)(:[0-9]+)?$`)
@@ -55,9 +114,18 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
-	if len(args) != 1 {
+	if *diffMode && (len(args) != 2 || *htmlOut || *by != "") || !*diffMode && len(args) != 1 {
 		usage()
 	}
+	if *stream && (*diffMode || *perModule != 0 || *stratify) {
+		log.Fatalf("-stream cannot be used with -diff, -per-module, or -stratify")
+	}
+	if *stream && *samples < 0 {
+		log.Fatalf("-stream requires -n max with max >= 0")
+	}
+	if *by != "" && !validGrouping(*by) {
+		log.Fatalf("invalid -by %s: must be one of %s", *by, strings.Join(groupings, ", "))
+	}
 
 	var re *regexp.Regexp
 	if *grep != "" {
@@ -71,15 +139,66 @@ func main() {
 		rand.Seed(*seed)
 	}
 
-	f, err := os.Open(args[0])
+	if *diffMode {
+		diff(args[0], args[1], re)
+		return
+	}
+
+	if *stream {
+		sum := streamSummary(args[0], re)
+		write(sum)
+		return
+	}
+
+	sum, all := load(args[0], re)
+	if *classify {
+		sum.Constructs = countConstructs(all)
+	}
+	if *by != "" {
+		sum.Grouping = groupBy(all, *by)
+	}
+	sum.Samples = sample(all, *samples)
+	write(sum)
+}
+
+// write prints the summary to standard output.
+func write(sum *Summary) {
+	var buf bytes.Buffer
+	var err error
+	if *htmlOut {
+		err = writeHTML(&buf, sum)
+	} else {
+		err = tmpl.Execute(&buf, sum)
+	}
+	if err != nil {
+		log.Fatalf("internal template error: %v", err)
+	}
+	os.Stdout.Write(buf.Bytes())
+}
+
+// load reads the report file and returns a summary of it,
+// along with the diagnostics it contains matching re (or all, if re is nil).
+// The summary's Samples and Constructs are left for the caller to fill in.
+func load(file string, re *regexp.Regexp) (*Summary, []*Diagnostic) {
+	var all []*Diagnostic
+	sum := scan(file, re, func(d *Diagnostic) {
+		all = append(all, d)
+	})
+	return sum, all
+}
+
+// scan reads the report file, calling f for each diagnostic
+// matching re (or each diagnostic, if re is nil), and returns
+// a summary of the report. Scan holds only one module's report
+// in memory at a time, so f controls how much memory is used.
+func scan(file string, re *regexp.Regexp, f func(*Diagnostic)) *Summary {
+	rf, err := os.Open(file)
 	if err != nil {
 		log.Fatal(err)
 	}
-	dec := json.NewDecoder(f)
-	var sum Summary
-	sum.Grep = *grep
-	byMod := make(map[string][]*Diagnostic)
-	var mods []string
+	defer rf.Close()
+	dec := json.NewDecoder(rf)
+	sum := &Summary{Grep: *grep}
 	for {
 		var r Report
 		err := dec.Decode(&r)
@@ -87,7 +206,7 @@ func main() {
 			if err == io.EOF {
 				break
 			}
-			log.Fatalf("reading %s: %v", args[0], err)
+			log.Fatalf("reading %s: %v", file, err)
 		}
 		if r.Error != "" {
 			continue
@@ -111,41 +230,20 @@ func main() {
 				d.Position = m[1] + ":" + m[2] + m[3]
 				d.File = m[1]
 				d.Line, _ = strconv.Atoi(m[2])
+				d.Module = r.ModulePath
 				if !*quiet && d.Source != "" {
-					d.SourceQuote = "``````\n" + trim(d.Source) + "\n``````\n"
+					d.Snippet = trim(d.Source)
+					d.SourceQuote = "``````\n" + d.Snippet + "\n``````\n"
 				}
-				if byMod[r.ModulePath] == nil {
-					mods = append(mods, r.ModulePath)
+				if *classify {
+					d.Constructs = findConstructs(trim(d.Source))
 				}
-				byMod[r.ModulePath] = append(byMod[r.ModulePath], d)
+				f(d)
 				sum.TotalSamples++
 			}
 		}
 	}
-	if *samples < 0 {
-		*samples = sum.TotalSamples
-	}
-	for ; *samples > 0 && len(mods) > 0; *samples-- {
-		i := rand.Intn(len(mods))
-		m := mods[i]
-		diags := byMod[m]
-		j := rand.Intn(len(diags))
-		sum.Samples = append(sum.Samples, diags[j])
-		diags[j] = diags[len(diags)-1]
-		diags = diags[:len(diags)-1]
-		byMod[m] = diags
-		if len(diags) == 0 {
-			mods[i] = mods[len(mods)-1]
-			mods = mods[:len(mods)-1]
-		}
-	}
-
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, &sum)
-	if err != nil {
-		log.Fatalf("internal template error: %v", err)
-	}
-	os.Stdout.Write(buf.Bytes())
+	return sum
 }
 
 // A Report is the report for a single module.
@@ -166,17 +264,20 @@ type Report struct {
 }
 
 type Diagnostic struct {
-	URL          string `json:"-"`
-	SourceQuote  string `json:"-"`
-	PackageID    string `json:"package_id"`
-	AnalyzerName string `json:"analyzer_name"`
-	Error        string `json:"error"`
-	Category     string `json:"category"`
-	Position     string `json:"position"`
-	Message      string `json:"message"`
-	Source       string `json:"source"`
-	File         string `json:"-"`
-	Line         int    `json:"-"`
+	URL          string   `json:"-"`
+	SourceQuote  string   `json:"-"`
+	Snippet      string   `json:"-"`
+	Module       string   `json:"-"`
+	PackageID    string   `json:"package_id"`
+	AnalyzerName string   `json:"analyzer_name"`
+	Error        string   `json:"error"`
+	Category     string   `json:"category"`
+	Position     string   `json:"position"`
+	Message      string   `json:"message"`
+	Source       string   `json:"source"`
+	File         string   `json:"-"`
+	Line         int      `json:"-"`
+	Constructs   []string `json:"-"`
 }
 
 type Summary struct {
@@ -185,23 +286,47 @@ type Summary struct {
 	BadModules   int
 	TotalSamples int
 	Samples      []*Diagnostic
+	Constructs   []ConstructCount
+	Grouping     *Grouping
 }
 
 var tmpl = template.Must(template.New("").Funcs(
 	template.FuncMap{
 		"inc":  func(x int) int { return x + 1 },
 		"code": func(s string) string { return "```" + s + "```" },
+		"join": strings.Join,
+		"cell": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
 	},
 ).Parse(`
 {{.Modules}} modules analyzed.
 {{.TotalSamples}} diagnostics generated{{if .Grep}} matching {{code .Grep}}{{end}} in {{.BadModules}} modules.
-{{if .Samples}}
+{{if .Constructs}}
+Language constructs in diagnostic source (approximate):
+{{range .Constructs}}
+- {{.Name}}: {{.Count}}
+{{- end}}
+{{end}}
+{{- with .Grouping}}
+Diagnostics by {{.By}} ({{.Distinct}} distinct):
+
+| Count | % | {{.By}} |
+|---:|---:|---|
+{{- range .Top}}
+| {{.Count}} | {{printf "%.1f" .Percent}} | {{cell .Value}} |
+{{- end}}
+{{- if .Other}}
+| {{.Other}} | | (others) |
+{{- end}}
+
+{{end}}
+{{- if .Samples}}
 {{- if eq (len .Samples) .TotalSamples}}<details><summary>All diagnostics.</summary>
 {{- else}}<details><summary>{{len .Samples}} randomly sampled diagnostics.</summary>
 {{- end}}
 
 {{range $i, $d := .Samples}}({{inc $i}}) [{{$d.Position}}]({{$d.URL}}):
 {{$d.Message}}
+{{- if $d.Constructs}} (uses {{join $d.Constructs ", "}}){{end}}
 {{$d.SourceQuote}}
 {{end}}
 
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A stratum is a set of diagnostics sampled together, grouped by module.
+type stratum struct {
+	mods  []string
+	byMod map[string][]*Diagnostic
+}
+
+// remove removes the module at index i from s.
+func (s *stratum) remove(i int) {
+	delete(s.byMod, s.mods[i])
+	s.mods[i] = s.mods[len(s.mods)-1]
+	s.mods = s.mods[:len(s.mods)-1]
+}
+
+// sample returns a random sample of at most n of the diagnostics,
+// or all of them in random order if n is negative.
+// To keep a few modules with many diagnostics from dominating the sample,
+// each pick first chooses a module at random and then one of its diagnostics,
+// and once *perModule diagnostics (if positive) have been picked from a module,
+// its remaining diagnostics are dropped.
+// If *stratify is set, each pick starts by choosing a message pattern
+// (see messagePattern) at random, so that rare messages are sampled
+// about as often as common ones.
+func sample(all []*Diagnostic, n int) []*Diagnostic {
+	var strata []*stratum
+	byKey := make(map[string]*stratum)
+	for _, d := range all {
+		key := ""
+		if *stratify {
+			key = messagePattern(d.Message)
+		}
+		s := byKey[key]
+		if s == nil {
+			s = &stratum{byMod: make(map[string][]*Diagnostic)}
+			byKey[key] = s
+			strata = append(strata, s)
+		}
+		if s.byMod[d.Module] == nil {
+			s.mods = append(s.mods, d.Module)
+		}
+		s.byMod[d.Module] = append(s.byMod[d.Module], d)
+	}
+	if n < 0 {
+		n = len(all)
+	}
+	picked := make(map[string]int)
+	var list []*Diagnostic
+	for ; n > 0 && len(strata) > 0; n-- {
+		k := 0
+		if len(strata) > 1 {
+			k = rand.Intn(len(strata))
+		}
+		s := strata[k]
+		i := rand.Intn(len(s.mods))
+		m := s.mods[i]
+		diags := s.byMod[m]
+		j := rand.Intn(len(diags))
+		list = append(list, diags[j])
+		diags[j] = diags[len(diags)-1]
+		diags = diags[:len(diags)-1]
+		s.byMod[m] = diags
+		if len(diags) == 0 {
+			s.remove(i)
+		}
+
+		picked[m]++
+		if *perModule > 0 && picked[m] >= *perModule {
+			for _, s := range strata {
+				for i, mod := range s.mods {
+					if mod == m {
+						s.remove(i)
+						break
+					}
+				}
+			}
+		}
+		for k := 0; k < len(strata); {
+			if len(strata[k].mods) == 0 {
+				strata[k] = strata[len(strata)-1]
+				strata = strata[:len(strata)-1]
+				continue
+			}
+			k++
+		}
+	}
+	return list
+}
+
+// messagePattern returns the pattern of the diagnostic message msg,
+// used to cluster similar messages for stratified sampling.
+// The pattern replaces each word that is not plain lower-case text,
+// such as an identifier, number, or quoted string, with an underscore,
+// so that for example "call to (*T).Fatal from a non-test goroutine"
+// and "call to (*B).FailNow from a non-test goroutine" have the
+// same pattern, "call to _ from a non-test goroutine".
+func messagePattern(msg string) string {
+	words := strings.Fields(msg)
+	for i, w := range words {
+		text := strings.TrimRight(w, ".,:;")
+		plain := text != ""
+		for _, r := range text {
+			if !unicode.IsLower(r) && r != '-' {
+				plain = false
+				break
+			}
+		}
+		if !plain {
+			words[i] = "_" + w[len(text):]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// streamSummary reads the report file in a single pass,
+// returning a summary with a uniform random sample of *samples diagnostics.
+// Memory use is bounded by the sample size, plus the number of distinct
+// values for -by.
+func streamSummary(file string, re *regexp.Regexp) *Summary {
+	var cc constructCounter
+	var g *grouper
+	if *by != "" {
+		g = newGrouper(*by)
+	}
+	var reservoir []*Diagnostic
+	seen := 0
+	sum := scan(file, re, func(d *Diagnostic) {
+		if *classify {
+			cc.add(d)
+		}
+		if g != nil {
+			g.add(d)
+		}
+		// Reservoir sampling (Algorithm R): after seen diagnostics,
+		// each is in the reservoir with probability *samples/seen.
+		seen++
+		if len(reservoir) < *samples {
+			reservoir = append(reservoir, d)
+		} else if i := rand.Intn(seen); i < *samples {
+			reservoir[i] = d
+		}
+	})
+	if *classify {
+		sum.Constructs = cc.list()
+	}
+	if g != nil {
+		sum.Grouping = g.grouping()
+	}
+	rand.Shuffle(len(reservoir), func(i, j int) {
+		reservoir[i], reservoir[j] = reservoir[j], reservoir[i]
+	})
+	sum.Samples = reservoir
+	return sum
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var messagePatternTests = []struct {
+	msg  string
+	want string
+}{
+	{"call to (*T).Fatalf from a non-test goroutine", "call to _ from a non-test goroutine"},
+	{"ExampleGetConfig refers to unknown identifier: GetConfig", "_ refers to unknown identifier: _"},
+	{"TestsContains has malformed name: first letter after 'Test' must not be lowercase", "_ has malformed name: first letter after _ must not be lowercase"},
+	{"there can only be one output comment block per example", "there can only be one output comment block per example"},
+	{"x declared on line 12.", "x declared on line _."},
+}
+
+func TestMessagePattern(t *testing.T) {
+	for _, tt := range messagePatternTests {
+		if got := messagePattern(tt.msg); got != tt.want {
+			t.Errorf("messagePattern(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestSamplePerModule(t *testing.T) {
+	defer func(old int) { *perModule = old }(*perModule)
+	*perModule = 2
+
+	var all []*Diagnostic
+	for i := 0; i < 10; i++ {
+		all = append(all, &Diagnostic{Module: "big", Message: fmt.Sprint(i)})
+	}
+	all = append(all, &Diagnostic{Module: "small"})
+
+	list := sample(all, -1)
+	counts := make(map[string]int)
+	for _, d := range list {
+		counts[d.Module]++
+	}
+	if counts["big"] != 2 || counts["small"] != 1 || len(list) != 3 {
+		t.Errorf("sample with -per-module 2 = %d from big, %d from small, %d total; want 2, 1, 3", counts["big"], counts["small"], len(list))
+	}
+}
+
+func TestSampleStratify(t *testing.T) {
+	defer func(old bool) { *stratify = old }(*stratify)
+	*stratify = true
+
+	var all []*Diagnostic
+	for i := 0; i < 100; i++ {
+		all = append(all, &Diagnostic{Module: fmt.Sprint("m", i), Message: "common"})
+	}
+	all = append(all, &Diagnostic{Module: "rare", Message: "rare"})
+
+	// With two patterns, the rare one is picked first half the time;
+	// after 40 picks, it has been picked with near certainty.
+	found := false
+	for _, d := range sample(all, 40) {
+		if d.Message == "rare" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("stratified sample of 40 did not include rare message")
+	}
+}
+
+func TestStreamSummary(t *testing.T) {
+	defer func(old int) { *samples = old }(*samples)
+	*samples = 5
+
+	file := filepath.Join(t.TempDir(), "report.json")
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&buf, `{"module_path": "m%d", "diagnostic": [`, i)
+		for j := 0; j < 3; j++ {
+			if j > 0 {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(&buf, `{"message": "msg %d", "position": "/tmp/modules/m%d@v1.0.0/x.go:%d:1"}`, j, i, j+1)
+		}
+		buf.WriteString("]}\n")
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := streamSummary(file, regexp.MustCompile(`msg [01]`))
+	if sum.Modules != 20 || sum.BadModules != 20 || sum.TotalSamples != 40 {
+		t.Errorf("streamSummary: %d modules, %d bad, %d diagnostics; want 20, 20, 40", sum.Modules, sum.BadModules, sum.TotalSamples)
+	}
+	if len(sum.Samples) != 5 {
+		t.Errorf("streamSummary: %d samples, want 5", len(sum.Samples))
+	}
+	for _, d := range sum.Samples {
+		if d.Message == "msg 2" {
+			t.Errorf("streamSummary: sampled non-matching diagnostic %q", d.Message)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const apiURL = "https://generativelanguage.googleapis.com/v1beta/models/"
+
+// A ModelInfo describes a model's token limits.
+type ModelInfo struct {
+	InputTokenLimit  int
+	OutputTokenLimit int
+}
+
+// UsageMetadata reports the token counts for a request and its response.
+type UsageMetadata struct {
+	PromptTokenCount     int
+	CandidatesTokenCount int
+	TotalTokenCount      int
+}
+
+var (
+	info       *ModelInfo // limits for *model, or nil if unknown
+	infoLoaded bool
+)
+
+// contextLimit returns the size of the model's context window in tokens,
+// or 0 if the size is unknown.
+func contextLimit() int {
+	if !infoLoaded {
+		infoLoaded = true
+		var mi ModelInfo
+		if err := api("GET", apiURL+*model, nil, &mi); err != nil {
+			if cancelled() {
+				infoLoaded = false // try again next time
+				return 0
+			}
+			log.Printf("cannot determine context window size: %v", err)
+		} else if mi.InputTokenLimit > 0 {
+			info = &mi
+		}
+	}
+	if info == nil {
+		return 0
+	}
+	return info.InputTokenLimit
+}
+
+// countTokens returns the number of tokens in contents.
+func countTokens(contents []Content) (int, error) {
+	var r struct{ TotalTokens int }
+	err := api("POST", apiURL+*model+":countTokens", map[string][]Content{"contents": contents}, &r)
+	return r.TotalTokens, err
+}
+
+// countContent prints the token count for prompt
+// and how much of the context window it would use,
+// without sending the prompt to the model.
+func countContent(prompt string) {
+	if *model == "" {
+		*model = "gemini-pro"
+		if *embed {
+			*model = "text-embedding-004"
+		}
+	}
+	contents := []Content{{Role: "user", Parts: []Part{{Text: prompt}}}}
+	n, err := countTokens(contents)
+	if cancelled() {
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("counting tokens: %v", err)
+		if !*lineMode {
+			log.Fatal(msg)
+		}
+		log.Print(msg)
+		return
+	}
+	fmt.Printf("prompt: %d bytes, %d tokens\n", len(prompt), n)
+	limit := contextLimit()
+	if limit == 0 {
+		return
+	}
+	if n > limit {
+		fmt.Printf("context window: %d tokens; prompt does not fit (%d tokens over)\n", limit, n-limit)
+		return
+	}
+	fmt.Printf("context window: %d tokens; prompt uses %.1f%%, leaving %d tokens\n", limit, 100*float64(n)/float64(limit), limit-n)
+	if info.OutputTokenLimit > 0 {
+		fmt.Printf("response: at most %d tokens\n", info.OutputTokenLimit)
+	}
+}
+
+// fits reports whether contents, which is estimated to use tokens tokens,
+// fits in the model's context window. If tokens is 0, meaning unknown,
+// fits estimates from the size of contents and asks the API
+// to count the tokens only when they might not fit.
+func fits(contents []Content, tokens int) (ok bool, n, limit int) {
+	limit = contextLimit()
+	if limit == 0 {
+		return true, tokens, 0
+	}
+	if tokens == 0 {
+		// A token is almost always at least one byte,
+		// so only count tokens for large inputs.
+		size := 0
+		for _, c := range contents {
+			for _, p := range c.Parts {
+				size += len(p.Text)
+			}
+		}
+		if size <= limit {
+			return true, 0, limit
+		}
+		var err error
+		tokens, err = countTokens(contents)
+		if err != nil {
+			if !cancelled() {
+				log.Printf("counting tokens: %v", err)
+			}
+			return true, 0, limit
+		}
+	}
+	return tokens <= limit, tokens, limit
+}
+
+// warnAt is the sorted list of context usage percentages at which to warn,
+// parsed from the -warn flag.
+var warnAt []int
+
+func parseWarn(s string) error {
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(f, "%"))
+		if err != nil || n <= 0 || n > 100 {
+			return fmt.Errorf("invalid -warn percentage %q", f)
+		}
+		warnAt = append(warnAt, n)
+	}
+	sort.Ints(warnAt)
+	return nil
+}
+
+// showUsage prints the context window usage reported in u to standard error,
+// along with a warning if the usage has reached a -warn threshold.
+func showUsage(u *UsageMetadata) {
+	limit := contextLimit()
+	if u == nil || u.TotalTokenCount == 0 || limit == 0 {
+		return
+	}
+	pct := 100 * float64(u.TotalTokenCount) / float64(limit)
+	fmt.Fprintf(os.Stderr, "[context: %d of %d tokens used (%.1f%%)]\n", u.TotalTokenCount, limit, pct)
+	for i := len(warnAt) - 1; i >= 0; i-- {
+		if pct >= float64(warnAt[i]) {
+			log.Printf("warning: context window is over %d%% full", warnAt[i])
+			break
+		}
+	}
+}
+
+// api sends a request with the given method and JSON body (if non-nil)
+// to url and decodes the JSON response into resp.
+func api(method, url string, body, resp any) error {
+	var rd io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(js)
+	}
+	req, err := http.NewRequestWithContext(reqCtx, method, url+"?key="+key, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if r.StatusCode != 200 {
+		return fmt.Errorf("%s:\n%s", r.Status, data)
+	}
+	if err != nil {
+		return fmt.Errorf("reading body: %v", err)
+	}
+	return json.Unmarshal(data, resp)
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// An extractor pulls matches of a regexp out of each response.
+type extractor struct {
+	name string
+	re   *regexp.Regexp
+	n    int // number of matches saved so far, for naming files
+}
+
+// extractors is the list of extractors registered with -x.
+var extractors []*extractor
+
+// builtinExtractors are the regexps that -x accepts by name alone.
+var builtinExtractors = map[string]string{
+	"code": "(?ms)^```(?P<lang>[\\w+#-]*)[ \\t]*\\n(?P<text>.*?)^```",
+}
+
+// langExt maps fenced code block languages to file extensions,
+// for those where the two differ.
+var langExt = map[string]string{
+	"bash":       "sh",
+	"golang":     "go",
+	"javascript": "js",
+	"markdown":   "md",
+	"python":     "py",
+	"ruby":       "rb",
+	"rust":       "rs",
+	"shell":      "sh",
+	"typescript": "ts",
+	"yaml":       "yml",
+}
+
+// extractFlag implements the -x flag.
+type extractFlag struct{}
+
+func (extractFlag) String() string { return "" }
+
+func (extractFlag) Set(s string) error {
+	name, expr, ok := strings.Cut(s, "=")
+	if !ok {
+		expr, ok = builtinExtractors[name]
+		if !ok {
+			return fmt.Errorf("want name=regexp or one of: code")
+		}
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid extractor name %q", name)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	extractors = append(extractors, &extractor{name: name, re: re})
+	return nil
+}
+
+// extract applies the extractors to the text of each candidate in r,
+// printing a trailer listing the matches after the response.
+// If -xdir is set, it writes each match to a numbered file in that
+// directory and lists the file names instead of the matches.
+func extract(r *Response) {
+	if len(extractors) == 0 {
+		return
+	}
+	var trailer []string
+	for _, c := range r.Candidates {
+		if len(c.Content.Parts) == 0 {
+			continue
+		}
+		text := c.Content.Parts[0].Text
+		for _, x := range extractors {
+			for _, m := range x.re.FindAllStringSubmatch(text, -1) {
+				trailer = append(trailer, x.save(m))
+			}
+		}
+	}
+	if len(trailer) == 0 {
+		return
+	}
+	fmt.Printf("\n[extracted]\n")
+	for _, line := range trailer {
+		fmt.Printf("%s\n", line)
+	}
+}
+
+// save records the match m, writing it to a file if -xdir is set,
+// and returns the trailer line describing it.
+func (x *extractor) save(m []string) string {
+	text := x.text(m)
+	x.n++
+	if *xdir == "" {
+		return fmt.Sprintf("%s %d:\n\t%s", x.name, x.n, strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", "\n\t"))
+	}
+
+	ext := "txt"
+	if i := x.re.SubexpIndex("lang"); i >= 0 && m[i] != "" {
+		ext = strings.ToLower(m[i])
+		if e, ok := langExt[ext]; ok {
+			ext = e
+		}
+	}
+	if err := os.MkdirAll(*xdir, 0777); err != nil {
+		log.Printf("extract: %v", err)
+		return fmt.Sprintf("%s %d: not saved", x.name, x.n)
+	}
+	// Skip over numbers used by files from an earlier session.
+	for {
+		file := filepath.Join(*xdir, fmt.Sprintf("%s-%d.%s", x.name, x.n, ext))
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, os.ErrExist) {
+			x.n++
+			continue
+		}
+		if err == nil {
+			_, err = f.WriteString(text)
+			if err1 := f.Close(); err == nil {
+				err = err1
+			}
+		}
+		if err != nil {
+			log.Printf("extract: %v", err)
+			return fmt.Sprintf("%s %d: not saved", x.name, x.n)
+		}
+		return fmt.Sprintf("%s %d: %s (%d bytes)", x.name, x.n, file, len(text))
+	}
+}
+
+// text returns the extracted text for the match m:
+// the subexpression named text, if any, or else the first
+// subexpression, if any, or else the entire match.
+func (x *extractor) text(m []string) string {
+	if i := x.re.SubexpIndex("text"); i >= 0 {
+		return m[i]
+	}
+	if len(m) > 1 {
+		return m[1]
+	}
+	return m[0]
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// reqCtx is the context for API requests.
+// In line mode, Ctrl-C cancels it to abandon the request in progress.
+var reqCtx = context.Background()
+
+var (
+	cancelMu  sync.Mutex
+	cancelReq context.CancelFunc // cancels the request in progress; nil at the prompt
+)
+
+// handleInterrupts arranges for Ctrl-C to cancel the request in progress
+// or, when there is none, to exit.
+func handleInterrupts() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		for range c {
+			cancelMu.Lock()
+			cancel := cancelReq
+			cancelMu.Unlock()
+			if cancel == nil {
+				fmt.Fprintf(os.Stderr, "\n")
+				os.Exit(130)
+			}
+			cancel()
+		}
+	}()
+}
+
+// startRequest sets reqCtx to a new cancellable context
+// for the requests made for a single line of input.
+// The caller must call the returned function when the requests are done.
+func startRequest() (done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reqCtx = ctx
+	cancelMu.Lock()
+	cancelReq = cancel
+	cancelMu.Unlock()
+	return func() {
+		cancelMu.Lock()
+		cancelReq = nil
+		cancelMu.Unlock()
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "request cancelled\n")
+		}
+		cancel()
+		reqCtx = context.Background()
+	}
+}
+
+// cancelled reports whether the current request has been cancelled.
+func cancelled() bool {
+	return reqCtx.Err() != nil
+}
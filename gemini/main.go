@@ -6,7 +6,7 @@
 //
 // Usage:
 //
-//	gemini [-l] [-k keyfile] [prompt...]
+//	gemini [-l] [-count] [-k keyfile] [-warn pcts] [-x name=regexp]... [-xdir dir] [prompt...]
 //
 // Gemini concatenates its arguments, sends the result as a prompt
 // to the Gemini Pro model, and prints the response.
@@ -17,10 +17,50 @@
 // The -l flag runs gemini in an interactive line-based mode:
 // it reads a single line of input and prints the Gemini response,
 // and repeats. The -l flag cannot be used with arguments.
+// In line mode, typing Ctrl-C while gemini waits for a response
+// cancels the request and returns to the prompt, keeping the session;
+// typing Ctrl-C at the prompt exits.
+//
+// The -count flag counts the tokens in the prompt instead of sending it:
+// gemini prints the prompt's size in bytes and tokens, the model's
+// context window size, and how much of the window the prompt would use,
+// so that a large prompt can be checked before paying for a failed request.
+// In line mode, a line beginning with :count is counted in the same way
+// instead of being sent, even without -count.
 //
 // The -k flag specifies the name of a file containing the Gemini API key
 // (default $HOME/.geminikey).
 //
+// If a response stops because it reached the model's output token limit,
+// gemini asks the model to continue where it left off and prints the
+// pieces together as a single response. The -continue flag sets the
+// maximum number of such continuations (default 3; 0 disables them).
+//
+// After each response, gemini prints to standard error the number of
+// tokens used by the request and response and the percentage of the
+// model's context window that they fill. It also prints a warning when that
+// percentage reaches one of the thresholds in the comma-separated -warn list
+// (default "80,95"). Gemini refuses to send a prompt that does not fit in the
+// context window, and it stops continuing a truncated response when the
+// continuation would not fit.
+//
+// The -x flag, which can be repeated, registers an extractor named name
+// that finds the matches of regexp in each response. After the response,
+// gemini prints an [extracted] trailer listing each match, numbered
+// per extractor across the session. The extracted text is the regexp's
+// subexpression named text, if it has one, or else its first
+// subexpression, if it has one, or else the entire match.
+// The form -x code registers a built-in extractor for fenced code blocks.
+//
+// The -xdir flag writes each extracted match to a file in dir named
+// name-N.ext instead of printing it, skipping numbers used by existing
+// files, and lists the file names in the trailer. The extension comes from
+// the regexp's subexpression named lang, if any, such as the language
+// of a fenced code block, and is otherwise txt. For example, to save the
+// code blocks in each response to code-1.go, code-2.go, and so on:
+//
+//	gemini -l -x code -xdir .
+//
 // [Google's Gemini API]: https://developers.generativeai.google/
 package main
 
@@ -45,10 +85,18 @@ var (
 	keyFile  = flag.String("k", filepath.Join(home, ".geminikey"), "read gemini API key from `file`")
 	model    = flag.String("m", "", "use gemini `model`") // gemini-1.5-pro-latest is only in free mode
 	embed    = flag.Bool("e", false, "print embedding")
+	maxCont  = flag.Int("continue", 3, "continue truncated responses at most `n` times")
+	warnFlag = flag.String("warn", "80,95", "warn when context window use reaches `pcts` (comma-separated percentages)")
+	count    = flag.Bool("count", false, "count prompt tokens instead of sending the prompt")
+	xdir     = flag.String("xdir", "", "write extracted matches to files in `dir`")
 )
 
+func init() {
+	flag.Var(extractFlag{}, "x", "extract `name=regexp` matches from responses (or -x code for code blocks)")
+}
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: gemini [-e] [-l] [-k keyfile] [-m model]\n")
+	fmt.Fprintf(os.Stderr, "usage: gemini [-e] [-l] [-count] [-k keyfile] [-m model] [-continue n] [-warn pcts] [-x name=regexp]... [-xdir dir]\n")
 	os.Exit(2)
 }
 
@@ -57,6 +105,9 @@ func main() {
 	log.SetPrefix("gemini: ")
 	flag.Usage = usage
 	flag.Parse()
+	if err := parseWarn(*warnFlag); err != nil {
+		log.Fatal(err)
+	}
 
 	data, err := os.ReadFile(*keyFile)
 	if err != nil {
@@ -68,11 +119,15 @@ func main() {
 	if *embed {
 		do = embedContent
 	}
+	if *count {
+		do = countContent
+	}
 
 	if *lineMode {
 		if flag.NArg() != 0 {
 			log.Fatalf("-l cannot be used with arguments")
 		}
+		handleInterrupts()
 		scanner := bufio.NewScanner(os.Stdin)
 		for {
 			fmt.Fprintf(os.Stderr, "> ")
@@ -81,7 +136,13 @@ func main() {
 			}
 			line := scanner.Text()
 			fmt.Fprintf(os.Stderr, "\n")
-			do(line)
+			done := startRequest()
+			if rest, ok := strings.CutPrefix(line, ":count"); ok && (rest == "" || rest[0] == ' ') {
+				countContent(strings.TrimSpace(rest))
+			} else {
+				do(line)
+			}
+			done()
 			fmt.Fprintf(os.Stderr, "\n")
 		}
 		return
@@ -107,15 +168,18 @@ func embedContent(prompt string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	resp, err := http.Post("https://generativelanguage.googleapis.com/v1beta/models/"+*model+":embedContent?key="+key, "application/json", bytes.NewReader(js))
-	if err != nil {
-		log.Fatal(err)
-	}
+	resp, err := post("https://generativelanguage.googleapis.com/v1beta/models/"+*model+":embedContent?key="+key, js)
 	if err != nil {
+		if cancelled() {
+			return
+		}
 		log.Fatal(err)
 	}
 	data, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
+	if cancelled() {
+		return
+	}
 	if resp.StatusCode != 200 {
 		log.Fatalf("%s:\n%s", resp.Status, data)
 	}
@@ -136,28 +200,95 @@ type EmbedResponse struct {
 	}
 }
 
+// continuePrompt is the user turn sent to ask the model
+// to continue a response truncated by the output token limit.
+const continuePrompt = "Continue exactly where you left off, without repeating anything."
+
 func generateContent(prompt string) {
 	if *model == "" {
 		*model = "gemini-pro"
 	}
+
+	contents := []Content{{Role: "user", Parts: []Part{{Text: prompt}}}}
+	if ok, n, limit := fits(contents, 0); !ok {
+		msg := fmt.Sprintf("prompt is %d tokens; %s accepts at most %d", n, *model, limit)
+		if !*lineMode {
+			log.Fatal(msg)
+		}
+		log.Print(msg)
+		return
+	}
+	var prefix string // text of earlier truncated responses
+	for n := 0; ; n++ {
+		r, data := generate(contents)
+		if r == nil {
+			return
+		}
+		if len(r.Candidates) == 1 {
+			c := &r.Candidates[0]
+			if c.FinishReason == "MAX_TOKENS" && len(c.Content.Parts) > 0 && n < *maxCont && canContinue(contents, c, r.UsageMetadata) {
+				text := c.Content.Parts[0].Text
+				prefix += text
+				contents = append(contents,
+					Content{Role: "model", Parts: []Part{{Text: text}}},
+					Content{Role: "user", Parts: []Part{{Text: continuePrompt}}})
+				log.Printf("response truncated; continuing")
+				continue
+			}
+			if len(c.Content.Parts) > 0 {
+				c.Content.Parts[0].Text = prefix + c.Content.Parts[0].Text
+			}
+		}
+		printResponse(r, data)
+		extract(r)
+		showUsage(r.UsageMetadata)
+		return
+	}
+}
+
+// canContinue reports whether the continuation request for the truncated
+// candidate c, a response to contents, fits in the context window.
+// The usage u is the token usage for contents and c, if known.
+func canContinue(contents []Content, c *Candidate, u *UsageMetadata) bool {
+	contents = append(contents[:len(contents):len(contents)],
+		Content{Role: "model", Parts: []Part{{Text: c.Content.Parts[0].Text}}},
+		Content{Role: "user", Parts: []Part{{Text: continuePrompt}}})
+	tokens := 0
+	if u != nil && u.TotalTokenCount > 0 {
+		tokens = u.TotalTokenCount + len(continuePrompt) // a token is at least a byte
+	}
+	if ok, _, _ := fits(contents, tokens); !ok {
+		log.Printf("cannot continue truncated response: context window is full")
+		return false
+	}
+	return true
+}
+
+// generate sends contents to the model and returns the parsed response
+// along with the raw response data, for use in error messages.
+// If the request is cancelled, generate returns nil, nil.
+func generate(contents []Content) (*Response, []byte) {
 	// curl \
 	// -H 'Content-Type: application/json' \
 	// -d '{ "prompt": { "text": "Write a story about a magic backpack"} }' \
 	// "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-pro-latest:generateContent?key=YOUR_API_KEY"
 
-	js, err := json.Marshal(map[string][]map[string][]map[string]string{"contents": {{"parts": {{"text": prompt}}}}})
-	if err != nil {
-		log.Fatal(err)
-	}
-	resp, err := http.Post("https://generativelanguage.googleapis.com/v1beta/models/"+*model+":generateContent?key="+key, "application/json", bytes.NewReader(js))
+	js, err := json.Marshal(map[string][]Content{"contents": contents})
 	if err != nil {
 		log.Fatal(err)
 	}
+	resp, err := post("https://generativelanguage.googleapis.com/v1beta/models/"+*model+":generateContent?key="+key, js)
 	if err != nil {
+		if cancelled() {
+			return nil, nil
+		}
 		log.Fatal(err)
 	}
 	data, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
+	if cancelled() {
+		return nil, nil
+	}
 	if resp.StatusCode != 200 {
 		log.Fatalf("%s:\n%s", resp.Status, data)
 	}
@@ -169,6 +300,23 @@ func generateContent(prompt string) {
 	if err := json.Unmarshal(data, &r); err != nil {
 		log.Fatal(err)
 	}
+	return &r, data
+}
+
+// post sends a POST request with the JSON body js to url,
+// using reqCtx so that the request can be cancelled.
+func post(url string, js []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(reqCtx, "POST", url, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return http.DefaultClient.Do(req)
+}
+
+// printResponse prints the candidate answers in r.
+// Data is the raw response, for use in error messages.
+func printResponse(r *Response, data []byte) {
 	if len(r.Candidates) == 0 {
 		fmt.Fprintf(os.Stderr, "no candidate answers")
 	}
@@ -179,6 +327,9 @@ func generateContent(prompt string) {
 		}
 		seen++
 		fmt.Printf("%s\n", c.Content.Parts[0].Text)
+		if c.FinishReason == "MAX_TOKENS" {
+			log.Printf("response truncated at output token limit")
+		}
 		for _, rate := range c.SafetyRatings {
 			if rate.Probability != "NEGLIGIBLE" {
 				fmt.Printf("%s=%s\n", rate.Category, rate.Probability)
@@ -191,20 +342,22 @@ func generateContent(prompt string) {
 }
 
 type Response struct {
-	Candidates []Candidate
+	Candidates    []Candidate
+	UsageMetadata *UsageMetadata
 }
 
 type Candidate struct {
 	Content       Content
+	FinishReason  string
 	SafetyRatings []SafetyRating
 }
 type Content struct {
-	Parts []Part
-	Role  string
+	Parts []Part `json:"parts"`
+	Role  string `json:"role,omitempty"`
 }
 
 type Part struct {
-	Text string
+	Text string `json:"text"`
 }
 
 type SafetyRating struct {
@@ -6,12 +6,60 @@
 //
 // Usage:
 //
-//	go2asm [-s symregexp] [file]
+//	go2asm [-s symregexp] [-pkgprefix path | -local] [-o file | -split dir] [file | -objdump file]
 //
 // Go2asm reads the compiler's -S output from file (default standard input),
 // converting it to equivalent assembler input. If the -s option is present,
 // go2asm only converts symbols with names matching the regular expression.
 //
+// The -objdump option reads a compiled package archive or executable
+// from file instead, using "go tool objdump" to disassemble it, so that
+// code that has already been built need not be recompiled with -gcflags=-S.
+// The object code records less than the compiler's -S output.
+// Go2asm infers the frame size from the function prologue and the
+// argument size from the stack references and, for ABIInternal functions,
+// the argument registers, which may undercount the arguments. It treats functions without a stack check as NOSPLIT and
+// all functions except assembly ones (ABI0) as ABIInternal.
+// Arguments and locals are named arg and local.
+// References to data without symbols, such as type descriptors
+// in executables, cannot be converted and draw a warning.
+//
+// Go2asm accepts output from compilers using either the stack-based
+// calling convention or the register-based ABIInternal (Go 1.17 and later).
+// Only the runtime can write ABIInternal assembly, so go2asm drops
+// ABIInternal from TEXT lines, leaving functions in the stack-based ABI0
+// that other assembly uses, in which arguments and results are in memory
+// at offsets from FP. The converted code still expects its arguments in
+// registers, so it must be adjusted by hand to load them from FP, and to
+// store its results to FP, before it is used. Go2asm marks each such
+// function with a comment listing the registers holding its arguments:
+// those it spills around its stack growth call or, in a function without
+// one, those it reads before writing. The argument size in the TEXT line
+// covers the arguments in those registers, as laid out in memory,
+// but not the results.
+//
+// Go2asm names global symbols using the package path from the
+// compiler's "# path" comment, unchanged, since vendored packages
+// keep their vendor directories in their linker symbol names.
+// The -pkgprefix option overrides that path, and the -local option
+// emits package-local names (·name) instead, so that the output
+// can be dropped into a package other than the original.
+//
+// The -o option writes the output to file instead of standard output.
+//
+// The -split option writes each TEXT symbol to its own file in dir,
+// named for the symbol (for example, IsInf.s). Each file carries
+// the #include lines needed by its own code. When the input names
+// symbols with full package paths, as in the output of
+// "go build -gcflags=-S", the files are placed in subdirectories
+// of dir named for their packages (for example, math/IsInf.s).
+//
+// The input may contain the output for many packages.
+// Go2asm converts each symbol only once, even if it appears in
+// more than one package, and it omits the compiler-generated
+// ABI wrappers (those attributed to <autogenerated>),
+// leaving a comment in their place.
+//
 // Example
 //
 // Extract the assembly for a test program:
@@ -90,30 +138,41 @@ import (
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
 var (
-	startTextRE = regexp.MustCompile(`^(""\.[^ ]+) t=([^ ]+) size=([^ ]+) (?:value=[^ ]+ )?args=([^ ]+) locals=([^ ]+)$`)
+	startTextRE = regexp.MustCompile(`^([^ \t#][^ ]*) (?:t=[^ ]+|STEXT(?: [a-z]+)*) size=([^ ]+) (?:align=[^ ]+ )?(?:value=[^ ]+ )?args=([^ ]+) locals=([^ ]+)(?: .*)?$`)
 	startDataRE = regexp.MustCompile(`^([^ ]+) t=([^ ]+) size=([^ ]+)$`)
 	instRE      = regexp.MustCompile(`^\t(0x[0-9a-f]+) 0*(0|[1-9][0-9]*) \(([^\t]+:[0-9]+)\)\t([A-Z0-9].*)$`)
 )
 
 var (
 	input string
-	pkg   string
+	pkg   string // package path from most recent "# path" comment
 	sym   string
 
 	wordSize = 8
 
-	symRE   = regexp.MustCompile(``)
-	symFlag = flag.String("s", "", "print only symbols matching `symregexp`")
+	// intArgRegs and floatArgRegs list the amd64 ABIInternal
+	// integer and floating-point argument registers, in order.
+	intArgRegs   = []string{"AX", "BX", "CX", "DI", "SI", "R8", "R9", "R10", "R11"}
+	floatArgRegs = []string{"X0", "X1", "X2", "X3", "X4", "X5", "X6", "X7", "X8", "X9", "X10", "X11", "X12", "X13", "X14"}
+
+	symRE     = regexp.MustCompile(``)
+	symFlag   = flag.String("s", "", "print only symbols matching `symregexp`")
+	outFlag   = flag.String("o", "", "write output to `file`")
+	splitFlag = flag.String("split", "", "write one file per symbol to `dir`")
+	pkgFlag   = flag.String("pkgprefix", "", "name global symbols as if in package `path`")
+	localFlag = flag.Bool("local", false, "name global symbols as package-local ·name")
+	objFlag   = flag.Bool("objdump", false, "read compiled package archive or executable file using go tool objdump")
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: go2asm [-s symregexp] [file]\n")
+	fmt.Fprintf(os.Stderr, "usage: go2asm [-s symregexp] [-pkgprefix path | -local] [-o file | -split dir] [file | -objdump file]\n")
 	os.Exit(2)
 }
 
@@ -122,24 +181,28 @@ func main() {
 	log.SetFlags(0)
 	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() > 1 {
+	if flag.NArg() > 1 || *outFlag != "" && *splitFlag != "" || *pkgFlag != "" && *localFlag || *objFlag && flag.NArg() != 1 {
 		usage()
 	}
 
 	if *symFlag != "" {
 		re, err := regexp.Compile(*symFlag)
 		if err != nil {
-			log.Fatal("invalid -s regexp: %s", err)
+			log.Fatalf("invalid -s regexp: %s", err)
 		}
 		symRE = re
 	}
 
 	var data []byte
 	var err error
-	if flag.NArg() == 0 {
+	switch {
+	case *objFlag:
+		input = flag.Arg(0)
+		data, err = runObjdump(flag.Arg(0))
+	case flag.NArg() == 0:
 		data, err = ioutil.ReadAll(os.Stdin)
 		input = "<stdin>"
-	} else {
+	default:
 		input = flag.Arg(0)
 		data, err = ioutil.ReadFile(flag.Arg(0))
 	}
@@ -147,55 +210,200 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *splitFlag != "" {
+		if err := os.MkdirAll(*splitFlag, 0777); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	var (
+		out  bytes.Buffer
 		mode string
 		text []Inst
 	)
 
+	seen := map[string]bool{}
 	flush := func() {
-		if mode == "text" {
-			asmText(text)
+		if mode == "text" && len(text) > 0 {
+			path, name := splitSym(sym)
+			full := path + "." + name
+			switch {
+			case strings.HasPrefix(text[0].FileLine, "<autogenerated>"):
+				comment(&out, "%s: autogenerated wrapper omitted", full)
+			case seen[full]:
+				comment(&out, "%s: duplicate omitted", full)
+			default:
+				seen[full] = true
+				subdir := ""
+				if !strings.HasPrefix(sym, `"".`) {
+					subdir = path
+				}
+				emit(&out, subdir, name, asmText(path, text))
+			}
 		}
 		mode = ""
 		text = nil
 		sym = ""
 	}
 
-	for lineno, line := range strings.Split(string(data), "\n") {
-		lineno++
-		if !strings.HasPrefix(line, "\t") {
+	if *objFlag {
+		for _, fn := range objdumpFuncs(data) {
+			sym, mode, text = fn.Sym, "text", fn.Text
 			flush()
 		}
-		if strings.HasPrefix(line, "# ") && !strings.Contains(line[2:], " ") {
-			pkg = line[2:]
-		}
-		if m := startTextRE.FindStringSubmatch(line); m != nil {
-			sym = m[1]
-			if !symRE.MatchString(pkg + "." + sym[3:]) {
-				continue
+	} else {
+		for lineno, line := range strings.Split(string(data), "\n") {
+			lineno++
+			if !strings.HasPrefix(line, "\t") {
+				flush()
 			}
-			mode = "text"
-			continue
-		}
-		if m := startDataRE.FindStringSubmatch(line); m != nil {
-			sym = m[1]
-			if !symRE.MatchString(pkg + "." + sym[3:]) {
+			if strings.HasPrefix(line, "# ") && !strings.Contains(line[2:], " ") {
+				pkg = line[2:]
+			}
+			if m := startTextRE.FindStringSubmatch(line); m != nil {
+				sym = m[1]
+				if path, name := splitSym(sym); !symRE.MatchString(path + "." + name) {
+					continue
+				}
+				mode = "text"
 				continue
 			}
-			mode = "data"
-			continue
-		}
-		if mode == "text" {
-			if m := instRE.FindStringSubmatch(line); m != nil {
-				if len(text) == 0 && !strings.HasPrefix(m[4], "TEXT\t"+sym+"(SB),") {
-					warn(lineno, "did not find TEXT at start of %s: %s", sym, m[4])
+			if m := startDataRE.FindStringSubmatch(line); m != nil {
+				sym = m[1]
+				if path, name := splitSym(sym); !symRE.MatchString(path + "." + name) {
+					continue
 				}
-				text = append(text, Inst{Lineno: lineno, PC: m[2], FileLine: m[3], Asm: m[4]})
+				mode = "data"
 				continue
 			}
+			if mode == "text" {
+				if m := instRE.FindStringSubmatch(line); m != nil {
+					if len(text) == 0 && !strings.HasPrefix(m[4], "TEXT\t"+sym+"(SB),") {
+						warn(lineno, "did not find TEXT at start of %s: %s", sym, m[4])
+					}
+					text = append(text, Inst{Lineno: lineno, PC: m[2], FileLine: m[3], Asm: m[4]})
+					continue
+				}
+			}
 		}
 	}
 	flush()
+
+	if *splitFlag != "" {
+		return
+	}
+	if *outFlag != "" {
+		if err := ioutil.WriteFile(*outFlag, out.Bytes(), 0666); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	os.Stdout.Write(out.Bytes())
+}
+
+var (
+	haveFuncdataH = false
+	haveTextflagH = false
+	splitFiles    = map[string]bool{}
+)
+
+// splitSym splits a symbol name from the compiler output
+// into its package path and its name within that package,
+// dropping any ABI suffix like <ABIInternal>.
+// Symbols in the "". form belong to the package named
+// in the most recent "# path" comment.
+func splitSym(sym string) (path, name string) {
+	if i := strings.LastIndex(sym, "<"); i > 0 && strings.HasSuffix(sym, ">") {
+		sym = sym[:i]
+	}
+	if strings.HasPrefix(sym, `"".`) {
+		return pkg, sym[len(`"".`):]
+	}
+	base := sym
+	if i := strings.Index(base, "["); i >= 0 { // generic instantiation
+		base = base[:i]
+	}
+	slash := strings.LastIndex(base, "/")
+	dot := strings.Index(base[slash+1:], ".")
+	if dot < 0 {
+		return "", sym
+	}
+	return sym[:slash+1+dot], sym[slash+1+dot+1:]
+}
+
+// comment adds an assembler comment to out.
+// In -split mode there is no shared output, so comment does nothing.
+func comment(out *bytes.Buffer, format string, args ...interface{}) {
+	if *splitFlag == "" {
+		fmt.Fprintf(out, "// %s\n", fmt.Sprintf(format, args...))
+	}
+}
+
+// emit adds the converted assembly for the symbol name to out,
+// or, in -split mode, writes it to its own file in subdir.
+func emit(out *bytes.Buffer, subdir, name string, asm *Asm) {
+	if *splitFlag == "" {
+		if !haveFuncdataH && asm.NeedFuncdataH {
+			haveFuncdataH = true
+			out.WriteString("#include \"funcdata.h\"\n\n")
+		}
+		if !haveTextflagH && asm.NeedTextflagH {
+			haveTextflagH = true
+			out.WriteString("#include \"textflag.h\"\n\n")
+		}
+		out.Write(asm.Text)
+		return
+	}
+
+	var buf bytes.Buffer
+	if asm.NeedFuncdataH {
+		buf.WriteString("#include \"funcdata.h\"\n")
+	}
+	if asm.NeedTextflagH {
+		buf.WriteString("#include \"textflag.h\"\n")
+	}
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+	buf.Write(asm.Text)
+
+	dir := *splitFlag
+	if subdir != "" {
+		dir = filepath.Join(dir, filepath.FromSlash(subdir))
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			log.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, splitName(name))
+	for i := 2; splitFiles[file]; i++ {
+		file = filepath.Join(dir, fmt.Sprintf("%s_%d", splitName(name), i))
+	}
+	splitFiles[file] = true
+	if err := ioutil.WriteFile(file+".s", buf.Bytes(), 0666); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// splitName returns the base file name to use for the symbol name in -split mode.
+// It replaces characters that are awkward in file names,
+// so that (*T).M becomes T.M.
+func splitName(sym string) string {
+	var b strings.Builder
+	for _, c := range sym {
+		switch {
+		case c == '(' || c == ')' || c == '*':
+			// drop
+		case c == '.' || c == '_' || c == '-' ||
+			'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
 }
 
 func warn(lineno int, format string, args ...interface{}) {
@@ -209,17 +417,41 @@ type Inst struct {
 	Asm      string // assembly instruction
 }
 
-var haveFuncdataH = false
-
 var (
-	textRE        = regexp.MustCompile(`TEXT.*\(SB\), \$([0-9]+)-([0-9]+)$`)
-	flt64RE       = regexp.MustCompile(`\$f64\.[0-9a-f]{16}\(SB\)`)
+	textRE        = regexp.MustCompile(`TEXT.*\(SB\), (?:[A-Za-z|]+, )?\$([0-9]+)-([0-9]+)$`)
+	textFlagsRE   = regexp.MustCompile(`^(TEXT\t[^ ]+\(SB\), )([A-Za-z|]+), `)
+	floatRE       = regexp.MustCompile(`\$f(?:32\.[0-9a-f]{8}|64\.[0-9a-f]{16})\(SB\)`)
 	spRE          = regexp.MustCompile(`\+[0-9]+\((FP|SP)\)`)
 	stackPkgRE    = regexp.MustCompile(`""\.([^ ,\t]+)\+[0-9]+\((SP|FP)\)`)
+	sbRE          = regexp.MustCompile(`(?:^|[\t $])[^\t $]+\(SB\)`)
 	tildeResultRE = regexp.MustCompile(`[.~][a-z0-9_]+\+[0-9]+\((SP|FP)\)`)
+	spillRE       = regexp.MustCompile(`^MOV[A-Z]*\t([A-Z0-9]+), ([0-9]+)\(SP\)$`)
 )
 
-func asmText(text []Inst) {
+// An Asm is the assembler form of a single TEXT symbol.
+type Asm struct {
+	Text          []byte // assembly source
+	NeedFuncdataH bool   // Text uses macros from funcdata.h
+	NeedTextflagH bool   // Text uses flags from textflag.h
+}
+
+// isABIInternal reports whether the TEXT instruction text
+// declares an ABIInternal function.
+func isABIInternal(text string) bool {
+	m := textFlagsRE.FindStringSubmatch(text)
+	if m == nil {
+		return false
+	}
+	for _, f := range strings.Split(m[2], "|") {
+		if f == "ABIInternal" {
+			return true
+		}
+	}
+	return false
+}
+
+// asmText converts the instructions text for a symbol in package path.
+func asmText(path string, text []Inst) *Asm {
 	var buf bytes.Buffer
 
 	var (
@@ -227,10 +459,22 @@ func asmText(text []Inst) {
 		locals          int
 		args            int
 		inStackPrologue bool
+		inStackCheck    bool
 		cutBP           bool
+		abiInternal     bool
+		abiRegs         []string
+		abiSize         int
+		textFlags       bool
 	)
+	if len(text) > 0 && isABIInternal(text[0].Asm) {
+		abiRegs, abiSize = abiArgs(text)
+	}
 
-	pkgPrefix := strings.Replace(strings.Replace(pathtoprefix(pkg)+".", "/", "∕", -1), ".", "·", -1)
+	pkgPrefix := symPrefix(path)
+	var localPkgRE *regexp.Regexp
+	if path != "" {
+		localPkgRE = regexp.MustCompile(`(^|[\t ,$])` + regexp.QuoteMeta(path) + `\.([^ ,\t]+\+[0-9]+\((?:SP|FP)\))`)
+	}
 
 	for i := range text {
 		inst := &text[i]
@@ -240,6 +484,18 @@ func asmText(text []Inst) {
 			inStackPrologue = true
 			continue
 		}
+		if inst.Asm == "CMPQ\tSP, 16(R14)" { // ABIInternal: g is in R14
+			inst.Asm = "// " + inst.Asm + " (stack growth prologue)"
+			inStackCheck = true
+			continue
+		}
+		if inStackCheck {
+			if strings.HasPrefix(inst.Asm, "PCDATA\t$0,") || strings.HasPrefix(inst.Asm, "JLS\t") {
+				inst.Asm = "// " + inst.Asm
+				continue
+			}
+			inStackCheck = false
+		}
 		if strings.HasPrefix(inst.Asm, "SUBQ\t$") && strings.HasSuffix(inst.Asm, ", SP") {
 			inst.Asm = "// " + inst.Asm
 			inStackPrologue = false
@@ -251,16 +507,35 @@ func asmText(text []Inst) {
 			inst.Asm = "// " + inst.Asm
 			continue
 		}
-		if strings.HasPrefix(inst.Asm, "MOVQ\tBP, ") && strings.HasSuffix(inst.Asm, "(SP)") { // BP save at beginning of function
+		if strings.HasPrefix(inst.Asm, "MOVQ\tBP, ") && strings.HasSuffix(inst.Asm, "(SP)") || inst.Asm == "PUSHQ\tBP" { // BP save at beginning of function
 			inst.Asm = "// " + inst.Asm + " (BP save)"
 			cutBP = true
 		}
-		if strings.HasPrefix(inst.Asm, "LEAQ\t") && strings.HasSuffix(inst.Asm, "(SP), BP") {
+		if strings.HasPrefix(inst.Asm, "LEAQ\t") && strings.HasSuffix(inst.Asm, "(SP), BP") || inst.Asm == "MOVQ\tSP, BP" {
 			inst.Asm = "// " + inst.Asm + " (BP init)"
 		}
-		if strings.HasPrefix(inst.Asm, "MOVQ\t") && strings.HasSuffix(inst.Asm, "(SP), BP") { // BP fixup before RET
+		if strings.HasPrefix(inst.Asm, "MOVQ\t") && strings.HasSuffix(inst.Asm, "(SP), BP") || inst.Asm == "POPQ\tBP" { // BP fixup before RET
 			inst.Asm = "// " + inst.Asm + " (BP restore)"
 		}
+		if i == 0 {
+			if m := textFlagsRE.FindStringSubmatch(inst.Asm); m != nil {
+				var keep []string
+				for _, f := range strings.Split(m[2], "|") {
+					if f == "ABIInternal" {
+						abiInternal = true
+						continue
+					}
+					keep = append(keep, f)
+				}
+				rest := inst.Asm[len(m[0]):]
+				if len(keep) > 0 {
+					inst.Asm = m[1] + strings.Join(keep, "|") + ", " + rest
+					textFlags = true
+				} else {
+					inst.Asm = m[1] + rest
+				}
+			}
+		}
 		if m := textRE.FindStringSubmatch(inst.Asm); m != nil {
 			n, err := strconv.Atoi(m[1])
 			if err != nil {
@@ -272,6 +547,21 @@ func asmText(text []Inst) {
 				warn(inst.Lineno, "invalid args size: %s", inst.Asm)
 			}
 			args = n
+			if abiInternal && abiSize > args {
+				args = abiSize
+				inst.Asm = inst.Asm[:len(inst.Asm)-len(m[2])] + strconv.Itoa(args)
+			}
+		}
+
+		// Comment out register ABI argument metadata.
+		if strings.HasPrefix(inst.Asm, "FUNCDATA\t$5,") {
+			inst.Asm = "// " + inst.Asm + " (arginfo)"
+		}
+		if strings.HasPrefix(inst.Asm, "FUNCDATA\t$6,") {
+			inst.Asm = "// " + inst.Asm + " (argliveinfo)"
+		}
+		if strings.HasPrefix(inst.Asm, "PCDATA\t$3,") {
+			inst.Asm = "// " + inst.Asm + " (arg liveness)"
 		}
 
 		// Comment out no-op FUNCDATAs.
@@ -291,20 +581,23 @@ func asmText(text []Inst) {
 			continue
 		}
 
-		// Rewrite $f64.0xbits into floating-point constant.
-		// TODO: Also $f32.
-		inst.Asm = flt64RE.ReplaceAllStringFunc(inst.Asm, func(name string) string {
-			v, err := strconv.ParseUint(name[len("$f64."):len(name)-len("(SB)")], 16, 64)
+		// Rewrite $f64.bits and $f32.bits into floating-point constants.
+		inst.Asm = floatRE.ReplaceAllStringFunc(inst.Asm, func(name string) string {
+			size := 64
+			if strings.HasPrefix(name, "$f32.") {
+				size = 32
+			}
+			v, err := strconv.ParseUint(name[len("$f64."):len(name)-len("(SB)")], 16, size)
 			if err != nil {
-				warn(inst.Lineno, "invalid $f64 reference: %s", inst.Asm)
+				warn(inst.Lineno, "invalid $f%d reference: %s", size, inst.Asm)
 				return name
 			}
-			f := math.Float64frombits(v)
-			g := fmt.Sprintf("%g", f)
-			if !strings.Contains(g, "e") && !strings.Contains(g, ".") {
-				g += ".0" // $(1) is not float; need $(1.0).
+			c, ok := floatConst(v, size)
+			if !ok {
+				warn(inst.Lineno, "cannot write %s as floating-point constant: %s", name, inst.Asm)
+				return name
 			}
-			return "$(" + g + ")"
+			return c
 		})
 
 		// In local variable names, drop "". prefix (for early versions of Go).
@@ -312,6 +605,10 @@ func asmText(text []Inst) {
 			return name[len(`"".`):]
 		})
 
+		if localPkgRE != nil {
+			inst.Asm = localPkgRE.ReplaceAllString(inst.Asm, "$1$2")
+		}
+
 		// Replace ~r1 with _r1.
 		inst.Asm = tildeResultRE.ReplaceAllStringFunc(inst.Asm, func(name string) string {
 			return "_" + name[1:]
@@ -320,6 +617,30 @@ func asmText(text []Inst) {
 		// In global variable names, replace "". with assembler prefix (e.g., "math·").
 		inst.Asm = strings.Replace(inst.Asm, `"".`, pkgPrefix, -1)
 
+		// Rewrite fully-qualified global names (Go 1.20 and later, or go build)
+		// into assembler form, so that math/bits.Len(SB) becomes math∕bits·Len(SB).
+		inst.Asm = sbRE.ReplaceAllStringFunc(inst.Asm, func(ref string) string {
+			lead := ""
+			if strings.ContainsAny(ref[:1], "\t $") {
+				lead, ref = ref[:1], ref[1:]
+			}
+			if floatRE.MatchString(lead + ref) { // float constant left in place above
+				return lead + ref
+			}
+			x := strings.TrimSuffix(ref, "(SB)")
+			if strings.Contains(x, "·") || strings.Contains(x, ":") { // already converted, or linker-internal
+				return lead + ref
+			}
+			p, name := splitSym(x)
+			if p == "" {
+				return lead + ref
+			}
+			if p == path {
+				return lead + pkgPrefix + name + "(SB)"
+			}
+			return lead + asmPrefix(p) + name + "(SB)"
+		})
+
 		// Rewrite x+N(SP) and x+N(FP) to be in assembler form.
 		// By default the compiler prints N = the exact offset from the real SP.
 		// But the assembler expects the offset from the virtual SP or virtual FP.
@@ -362,13 +683,11 @@ func asmText(text []Inst) {
 	}
 
 	// Comment out stack growth call at end.
-	if len(text) >= 2 && text[len(text)-1].Asm == "JMP\t0" && strings.HasPrefix(text[len(text)-2].Asm, "CALL\truntime.morestack") {
-		i := len(text) - 1
-		for i >= 0 && (i == len(text)-1 || !strings.HasPrefix(text[i].Asm, "JMP")) && !strings.HasPrefix(text[i].Asm, "RET") {
-			text[i].Asm = "// " + text[i].Asm
-			i--
+	if i := morestackStart(text); i < len(text) {
+		for j := i; j < len(text); j++ {
+			text[j].Asm = "// " + text[j].Asm
 		}
-		text[i+1].Asm += " (stack growth)"
+		text[i].Asm += " (stack growth)"
 	}
 
 	// Figure out which instructions need labels for jumps.
@@ -410,14 +729,15 @@ func asmText(text []Inst) {
 	}
 
 	// print assembly
-	if !haveFuncdataH && noLocalPointers {
-		haveFuncdataH = true
-		fmt.Fprintf(&buf, "#include \"funcdata.h\"\n\n")
-	}
 	where := ""
 	for i, inst := range text {
 		if i == 0 {
 			fmt.Fprintf(&buf, "%s // %s\n", inst.Asm, inst.FileLine)
+			if abiInternal {
+				fmt.Fprintf(&buf, "\t// WARNING: compiled for ABIInternal; adjust by hand to read arguments from FP\n")
+				fmt.Fprintf(&buf, "\t// and write results to FP before use as ABI0.\n")
+				fmt.Fprintf(&buf, "\t// ABIInternal: %s\n", argRegs(abiRegs))
+			}
 			if noLocalPointers {
 				fmt.Fprintf(&buf, "\tNO_LOCAL_POINTERS\n")
 			}
@@ -465,7 +785,169 @@ func asmText(text []Inst) {
 		}
 	}
 
-	os.Stdout.Write(buf2.Bytes())
+	return &Asm{Text: buf2.Bytes(), NeedFuncdataH: noLocalPointers, NeedTextflagH: textFlags}
+}
+
+// morestackStart returns the index of the first instruction of
+// the stack growth call at the end of text, or len(text) if there is none.
+// With ABIInternal, the call is surrounded by argument register spills and reloads.
+func morestackStart(text []Inst) int {
+	n := len(text)
+	if n < 2 || text[n-1].Asm != "JMP\t0" {
+		return n
+	}
+	morestack := false
+	i := n - 1
+	for i >= 0 && (i == n-1 || !strings.HasPrefix(text[i].Asm, "JMP")) && !strings.HasPrefix(text[i].Asm, "RET") {
+		if strings.HasPrefix(text[i].Asm, "CALL\truntime.morestack") || strings.HasPrefix(text[i].Asm, "CALL\truntime·morestack") {
+			morestack = true
+		}
+		i--
+	}
+	if !morestack {
+		return n
+	}
+	return i + 1
+}
+
+// abiArgs returns the registers in which the ABIInternal function text
+// receives its arguments, along with the size of the memory those
+// arguments occupy when spilled, which is also their size in an ABI0 frame.
+// If the function has a stack growth call, the registers are exactly
+// those it spills around the call. Otherwise abiArgs guesses from the
+// registers the function reads before writing them, assuming that
+// each argument is a single word.
+func abiArgs(text []Inst) (regs []string, size int) {
+	if i := morestackStart(text); i < len(text) {
+		for _, inst := range text[i:] {
+			if strings.HasPrefix(inst.Asm, "CALL\t") {
+				break
+			}
+			if m := spillRE.FindStringSubmatch(inst.Asm); m != nil {
+				off, _ := strconv.Atoi(m[2])
+				regs = append(regs, m[1])
+				if end := off - wordSize + opSize(inst.Asm); end > size {
+					size = end
+				}
+			}
+		}
+		return regs, size
+	}
+
+	used := map[string]bool{}
+	for _, inst := range text[1:] {
+		if strings.HasPrefix(inst.Asm, "CALL\t") {
+			break // the call clobbers the argument registers
+		}
+		noteRegs(used, inst.Asm)
+	}
+	// Arguments are assigned to registers in order,
+	// so a register in use implies the ones before it.
+	for _, list := range [][]string{intArgRegs, floatArgRegs} {
+		n := 0
+		for i, r := range list {
+			if used[r] {
+				n = i + 1
+			}
+		}
+		regs = append(regs, list[:n]...)
+	}
+	return regs, len(regs) * wordSize
+}
+
+// noteRegs records in used the argument registers
+// that asm reads before writing them.
+// It is only a heuristic: it looks at the operands of a
+// single instruction and ignores control flow.
+func noteRegs(used map[string]bool, asm string) {
+	op, operands, ok := strings.Cut(asm, "\t")
+	if !ok {
+		return
+	}
+	args := strings.Split(operands, ", ")
+	// XORPS X1, X1 and the like clear a register without reading it.
+	zeroing := len(args) == 2 && args[0] == args[1] &&
+		(strings.HasPrefix(op, "XOR") || strings.HasPrefix(op, "PXOR") || strings.HasPrefix(op, "SUB"))
+	for j, arg := range args {
+		for _, list := range [][]string{intArgRegs, floatArgRegs} {
+			for _, r := range list {
+				if _, ok := used[r]; ok {
+					continue
+				}
+				if arg == r || strings.Contains(arg, "("+r+")") || strings.Contains(arg, "("+r+"*") {
+					// The last operand of a move, address load, or conversion
+					// is written, not read, as is the operand of a SET or POP.
+					last := j == len(args)-1 && arg == r
+					written := zeroing ||
+						last && len(args) > 1 && (strings.HasPrefix(op, "MOV") || strings.HasPrefix(op, "LEA") || strings.HasPrefix(op, "CVT")) ||
+						last && (strings.HasPrefix(op, "SET") || strings.HasPrefix(op, "POP"))
+					used[r] = !written
+				}
+			}
+		}
+	}
+}
+
+// argRegs returns a description of the argument registers regs.
+func argRegs(regs []string) string {
+	if len(regs) == 0 {
+		return "no arguments in registers"
+	}
+	return "arguments in " + strings.Join(regs, ", ")
+}
+
+// floatConst returns the assembler constant $(x) for the
+// floating-point value with the given bits and size (32 or 64).
+// The assembler parses the constant as a float64 and converts it
+// to float32 for single-precision instructions, so floatConst
+// checks that the constant it returns evaluates to exactly bits
+// along that path. The assembler has no syntax for infinities or NaNs,
+// so floatConst returns ok=false for those.
+func floatConst(bits uint64, size int) (c string, ok bool) {
+	var f float64
+	if size == 32 {
+		f = float64(math.Float32frombits(uint32(bits)))
+	} else {
+		f = math.Float64frombits(bits)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", false
+	}
+
+	// Use the shortest decimal that round-trips at the given size.
+	// For float32, parsing as float64 and then rounding to float32
+	// can in rare cases differ from rounding directly to float32;
+	// if so, fall back to the exact float64 value.
+	g := strconv.FormatFloat(f, 'g', -1, size)
+	if size == 32 {
+		x, err := strconv.ParseFloat(g, 64)
+		if err != nil || math.Float32bits(float32(x)) != uint32(bits) {
+			g = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	if !strings.ContainsAny(g, "e.") {
+		g += ".0" // $(1) is not float; need $(1.0).
+	}
+	return "$(" + g + ")", true
+}
+
+// symPrefix returns the assembler prefix for global symbols
+// in package pkg (for example, "math·"), taking into account
+// the -pkgprefix and -local flags.
+func symPrefix(pkg string) string {
+	if *localFlag {
+		return "·"
+	}
+	if *pkgFlag != "" {
+		pkg = *pkgFlag
+	}
+	return asmPrefix(pkg)
+}
+
+// asmPrefix returns the assembler prefix for global symbols
+// in package pkg, ignoring the -pkgprefix and -local flags.
+func asmPrefix(pkg string) string {
+	return strings.Replace(strings.Replace(pathtoprefix(pkg)+".", "/", "∕", -1), ".", "·", -1)
 }
 
 func shortFileLine(f string) string {
@@ -0,0 +1,378 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("GO2ASM_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// go2asm runs the go2asm command with the given arguments,
+// returning its standard output.
+func go2asm(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GO2ASM_TEST_MAIN=1")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go2asm %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out)
+}
+
+var floatConstTests = []struct {
+	bits uint64
+	size int
+	out  string // "" for no constant
+}{
+	{0x3ff0000000000000, 64, "$(1.0)"},
+	{0x3fb999999999999a, 64, "$(0.1)"},
+	{0x0000000000000000, 64, "$(0.0)"},
+	{0x8000000000000000, 64, "$(-0.0)"},
+	{0x0000000000000001, 64, "$(5e-324)"},                 // smallest denormal
+	{0x000fffffffffffff, 64, "$(2.225073858507201e-308)"}, // largest denormal
+	{0x7fefffffffffffff, 64, "$(1.7976931348623157e+308)"},
+	{0x7ff0000000000000, 64, ""},
+	{0xfff0000000000000, 64, ""},
+	{0x7ff8000000000001, 64, ""}, // NaN
+	{0x7ff0000000000123, 64, ""}, // NaN with payload
+	{0xfff8000000000000, 64, ""},
+
+	{0x3f800000, 32, "$(1.0)"},
+	{0x3dcccccd, 32, "$(0.1)"},
+	{0x00000000, 32, "$(0.0)"},
+	{0x80000000, 32, "$(-0.0)"},
+	{0x00000001, 32, "$(1e-45)"}, // smallest denormal
+	{0x007fffff, 32, "$(1.1754942e-38)"},
+	{0x7f7fffff, 32, "$(3.4028235e+38)"},
+	{0x7f800000, 32, ""},
+	{0x7fc00001, 32, ""},
+	{0xffc12345, 32, ""},
+}
+
+func TestFloatConst(t *testing.T) {
+	for _, tt := range floatConstTests {
+		out, ok := floatConst(tt.bits, tt.size)
+		if out != tt.out || ok != (tt.out != "") {
+			t.Errorf("floatConst(%#x, %d) = %q, %v, want %q", tt.bits, tt.size, out, ok, tt.out)
+		}
+	}
+}
+
+// TestFloatConstRoundTrip checks that the constants floatConst returns
+// evaluate to the original bits when read the way the assembler does.
+func TestFloatConstRoundTrip(t *testing.T) {
+	check := func(bits uint64, size int) {
+		t.Helper()
+		c, ok := floatConst(bits, size)
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(c, "$(") || !strings.HasSuffix(c, ")") {
+			t.Errorf("floatConst(%#x, %d) = %q, not $(...)", bits, size, c)
+			return
+		}
+		s := c[2 : len(c)-1]
+		if !strings.ContainsAny(s, "e.") {
+			t.Errorf("floatConst(%#x, %d) = %q, not a floating-point constant", bits, size, c)
+		}
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			t.Errorf("floatConst(%#x, %d) = %q: %v", bits, size, c, err)
+			return
+		}
+		var got uint64
+		if size == 32 {
+			got = uint64(math.Float32bits(float32(x)))
+		} else {
+			got = math.Float64bits(x)
+		}
+		if got != bits {
+			t.Errorf("floatConst(%#x, %d) = %q, evaluates to %#x", bits, size, c, got)
+		}
+	}
+
+	for _, tt := range floatConstTests {
+		check(tt.bits, tt.size)
+	}
+	for i := uint64(0); i < 1e5; i++ {
+		b := i * 0x9e3779b97f4a7c15 // spread across the space of bits
+		check(b, 64)
+		check(b>>32, 32)
+		check(i, 32) // small denormals
+	}
+}
+
+func TestFloatRE(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{
+		{"MOVSD\t$f64.3ff0000000000000(SB), X0", "MOVSD\t$(1.0), X0"},
+		{"MOVSS\t$f32.3f800000(SB), X0", "MOVSS\t$(1.0), X0"},
+		{"MOVSS\t$f32.80000000(SB), X1", "MOVSS\t$(-0.0), X1"},
+		{"MOVSD\t$f64.7ff8000000000001(SB), X0", "MOVSD\t$f64.7ff8000000000001(SB), X0"},
+	}
+	for _, tt := range tests {
+		text := []Inst{
+			{Lineno: 1, Asm: "TEXT\t\"\".f(SB), $0-0"},
+			{Lineno: 2, Asm: tt.in},
+		}
+		asm := string(asmText("p", text).Text)
+		if !strings.Contains(strings.Join(strings.Fields(asm), " "), strings.Join(strings.Fields(tt.out), " ")) {
+			t.Errorf("asmText(%q):\n%s\nwant %q", tt.in, asm, tt.out)
+		}
+	}
+}
+
+func TestIsABIInternal(t *testing.T) {
+	tests := []struct {
+		text string
+		abi  bool
+	}{
+		{"TEXT\t\"\".f(SB), ABIInternal, $0-16", true},
+		{"TEXT\tmath.IsInf(SB), NOSPLIT|ABIInternal, $0-24", true},
+		{"TEXT\t\"\".f(SB), NOSPLIT, $0-16", false},
+		{"TEXT\t\"\".f(SB), $0-16", false},
+	}
+	for _, tt := range tests {
+		if abi := isABIInternal(tt.text); abi != tt.abi {
+			t.Errorf("isABIInternal(%q) = %v, want %v", tt.text, abi, tt.abi)
+		}
+	}
+}
+
+const objdumpInput = `TEXT p.G(SB) /tmp/x.go
+  x.go:13		0x1691			493b6610		CMPQ SP, 0x10(R14)	
+  x.go:13		0x1695			7621			JBE 0x16b8		
+  x.go:13		0x1697			55			PUSHQ BP		
+  x.go:13		0x1698			4889e5			MOVQ SP, BP		
+  x.go:13		0x169b			4883ec18		SUBQ $0x18, SP		
+  x.go:15		0x169f			48895c2430		MOVQ BX, 0x30(SP)	
+  x.go:15		0x16a4			4889442410		MOVQ AX, 0x10(SP)	
+  x.go:16		0x16a9			e800000000		CALL 0x16ae		[1:5]R_CALL:p.H		
+  x.go:16		0x16ae			f20f100d00000000	MOVSD_XMM 0(IP), X1	[4:8]R_PCREL:$f64.3ff8000000000000	
+  x.go:16		0x16b6			480f4fc3		CMOVG BX, AX		
+  x.go:18		0x16b7			c3			RET			
+  x.go:13		0x16b8			4889442408		MOVQ AX, 0x8(SP)	
+  x.go:13		0x16bd			48895c2410		MOVQ BX, 0x10(SP)	
+  x.go:13		0x16c2			48894c2418		MOVQ CX, 0x18(SP)	
+  x.go:13		0x16c7			e800000000		CALL 0x16cc		[1:5]R_CALL:runtime.morestack_noctxt	
+  x.go:13		0x16cc			eb81			JMP p.G(SB)		
+
+TEXT p.cpuid.abi0(SB) /tmp/cpu_x86.s
+  cpu_x86.s:11		0x1700			8b442408		MOVL 0x8(SP), AX	
+  cpu_x86.s:12		0x1704			8b4c240c		MOVL 0xc(SP), CX	
+  cpu_x86.s:13		0x1708			0fa2			CPUID			
+  cpu_x86.s:17		0x170a			89542418		MOVL DX, 0x18(SP)	
+  cpu_x86.s:18		0x170e			c3			RET			
+`
+
+func TestObjdump(t *testing.T) {
+	funcs := objdumpFuncs([]byte(objdumpInput))
+	want := []struct {
+		sym  string
+		asm  []string
+		text []string
+	}{
+		{"p.G",
+			[]string{
+				"TEXT\tp.G(SB), ABIInternal, $32-24",
+				"CMPQ\tSP, 16(R14)",
+				"JLS\t39",
+				"PUSHQ\tBP",
+				"MOVQ\tSP, BP",
+				"SUBQ\t$0x18, SP",
+				"MOVQ\tBX, arg+48(SP)",
+				"MOVQ\tAX, local+16(SP)",
+				"CALL\tp.H(SB)",
+				"MOVSD\t$f64.3ff8000000000000(SB), X1",
+				"CMOVQGT\tBX, AX",
+				"RET",
+				"MOVQ\tAX, 8(SP)",
+				"MOVQ\tBX, 16(SP)",
+				"MOVQ\tCX, 24(SP)",
+				"CALL\truntime.morestack_noctxt(SB)",
+				"JMP\t0",
+			},
+			[]string{
+				"TEXT p·G(SB), $32-24 // /tmp/x.go:13",
+				"MOVQ BX, arg+8(FP) // x.go:15",
+				"MOVQ AX, local-8(SP)",
+				"MOVSD $(1.5), X1",
+			},
+		},
+		{"p.cpuid",
+			[]string{
+				"TEXT\tp.cpuid(SB), NOSPLIT, $0-20",
+				"MOVL\targ+8(SP), AX",
+				"MOVL\targ+12(SP), CX",
+				"CPUID",
+				"MOVL\tDX, arg+24(SP)",
+				"RET",
+			},
+			[]string{
+				"TEXT p·cpuid(SB), NOSPLIT, $0-20 // /tmp/cpu_x86.s:11",
+				"MOVL arg+0(FP), AX",
+				"MOVL DX, arg+16(FP) // cpu_x86.s:17",
+			},
+		},
+	}
+	if len(funcs) != len(want) {
+		t.Fatalf("objdumpFuncs returned %d functions, want %d", len(funcs), len(want))
+	}
+	for i, fn := range funcs {
+		w := want[i]
+		if fn.Sym != w.sym {
+			t.Errorf("func %d: sym = %q, want %q", i, fn.Sym, w.sym)
+		}
+		var asm []string
+		for _, inst := range fn.Text {
+			asm = append(asm, inst.Asm)
+		}
+		if strings.Join(asm, "\n") != strings.Join(w.asm, "\n") {
+			t.Errorf("%s: converted:\n%s\nwant:\n%s", w.sym, strings.Join(asm, "\n"), strings.Join(w.asm, "\n"))
+		}
+		text := strings.Join(strings.Fields(string(asmText("p", fn.Text).Text)), " ")
+		for _, line := range w.text {
+			if !strings.Contains(text, line) {
+				t.Errorf("%s: asmText missing %q:\n%s", w.sym, line, text)
+			}
+		}
+	}
+}
+
+func TestABIArgs(t *testing.T) {
+	tests := []struct {
+		text []string
+		regs string
+		size int
+	}{
+		// Spills around the stack growth call give the registers exactly.
+		{[]string{
+			"TEXT\tp.g(SB), ABIInternal, $16-32",
+			"CMPQ\tSP, 16(R14)",
+			"JLS\t10",
+			"MOVQ\tp.a+24(SP), CX",
+			"RET",
+			"NOP",
+			"MOVQ\tAX, 8(SP)",
+			"MOVSD\tX0, 16(SP)",
+			"CALL\truntime.morestack_noctxt(SB)",
+			"MOVQ\t8(SP), AX",
+			"MOVSD\t16(SP), X0",
+			"JMP\t0",
+		}, "AX X0", 16},
+		// Otherwise, the registers read before being written.
+		{[]string{
+			"TEXT\tp.fl(SB), NOSPLIT|ABIInternal, $0-16",
+			"XORPS\tX1, X1",
+			"CVTSQ2SD\tAX, X1",
+			"MULSD\tX1, X0",
+			"RET",
+		}, "AX X0", 16},
+		{[]string{
+			"TEXT\tp.f(SB), NOSPLIT|ABIInternal, $0-0",
+			"MOVQ\t$1, AX",
+			"LEAQ\t(BX)(AX*2), CX",
+			"CALL\tp.h(SB)",
+			"MOVQ\tDI, DX",
+			"RET",
+		}, "AX BX", 16},
+		{[]string{
+			"TEXT\tp.z(SB), NOSPLIT|ABIInternal, $0-0",
+			"MOVL\t$0, AX",
+			"SETEQ\tBX",
+			"RET",
+		}, "", 0},
+	}
+	for _, tt := range tests {
+		var text []Inst
+		for _, asm := range tt.text {
+			text = append(text, Inst{Asm: asm})
+		}
+		regs, size := abiArgs(text)
+		if strings.Join(regs, " ") != tt.regs || size != tt.size {
+			t.Errorf("abiArgs(%s) = %v, %d, want [%s], %d", tt.text[0], regs, size, tt.regs, tt.size)
+		}
+	}
+}
+
+const commandInput = `package p
+
+func f(x int) int {
+	return x / 10
+}
+
+func fl(x float64, n int) float64 {
+	return x * float64(n)
+}
+
+func g(a, b int, s string) int {
+	return a + b + len(s) + h(a)
+}
+
+//go:noinline
+func h(x int) int { return x * 3 }
+`
+
+// TestCommand compiles a package and converts it with go2asm,
+// both from the compiler's -S output and with -objdump.
+func TestCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "x.go")
+	if err := os.WriteFile(src, []byte(commandInput), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "tool", "compile", "-p", "p", "-S", "-o", filepath.Join(dir, "p.a"), src)
+	cmd.Dir = dir
+	asm, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go tool compile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "p.s"), asm, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`(?m)^TEXT p·f\(SB\), NOSPLIT.*, \$0-8 //.*\n\t// WARNING`,
+		`(?m)^\t// ABIInternal: arguments in AX$`,
+		`(?m)^TEXT p·fl\(SB\), NOSPLIT.*, \$0-16 //`,
+		`(?m)^\t// ABIInternal: arguments in AX, X0$`,
+		`(?m)^TEXT p·g\(SB\), \$16-32 //`,
+		`(?m)^\t// ABIInternal: arguments in AX, BX, CX, DI$`,
+	}
+	for _, args := range [][]string{
+		{filepath.Join(dir, "p.s")},
+		{"-objdump", filepath.Join(dir, "p.a")},
+	} {
+		out := go2asm(t, args...)
+		for _, w := range want {
+			if !regexp.MustCompile(w).MatchString(out) {
+				t.Errorf("go2asm %s: missing %q in output:\n%s", strings.Join(args, " "), w, out)
+			}
+		}
+	}
+}
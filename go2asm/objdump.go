@@ -0,0 +1,326 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	objTextRE  = regexp.MustCompile(`^TEXT (.+)\(SB\)(?: (.*))?$`)
+	objRelocRE = regexp.MustCompile(`^\[[0-9]+:[0-9]+\]R_[A-Z0-9_]+:(.+)$`)
+	objDispRE  = regexp.MustCompile(`(^|[ ,])(-?)0x([0-9a-f]+)\(`)
+	objZeroRE  = regexp.MustCompile(`(^|[ ,])0\(`)
+	objSPRE    = regexp.MustCompile(`(^|[\t ,])(-?[0-9]+)?\(SP\)`)
+)
+
+// conds maps the Intel condition code suffixes printed by objdump
+// to the ones the Go assembler uses, so that JBE becomes JLS.
+var conds = map[string]string{
+	"A":  "HI",
+	"AE": "CC",
+	"B":  "CS",
+	"BE": "LS",
+	"E":  "EQ",
+	"G":  "GT",
+	"GE": "GE",
+	"L":  "LT",
+	"LE": "LE",
+	"NE": "NE",
+	"S":  "MI",
+	"NS": "PL",
+	"P":  "PS",
+	"NP": "PC",
+	"O":  "OS",
+	"NO": "OC",
+}
+
+// runObjdump returns the output of "go tool objdump" for file,
+// a compiled package archive or executable.
+func runObjdump(file string) ([]byte, error) {
+	cmd := exec.Command("go", "tool", "objdump", file)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go tool objdump %s: %v", file, err)
+	}
+	return out, nil
+}
+
+// An objFunc is a function from the output of go tool objdump.
+type objFunc struct {
+	Sym  string // symbol name, without any .abi0 suffix
+	Text []Inst // instructions, in the form of the compiler's -S output
+}
+
+// objdumpFuncs parses the output of go tool objdump,
+// returning the functions with names matching symRE.
+func objdumpFuncs(data []byte) []objFunc {
+	var (
+		funcs []objFunc
+		sym   string // objdump symbol name
+		file  string // source file from TEXT line
+		raw   []objInst
+	)
+	flush := func() {
+		if len(raw) > 0 {
+			funcs = append(funcs, objConvert(sym, file, raw))
+		}
+		raw = nil
+	}
+
+	for lineno, line := range strings.Split(string(data), "\n") {
+		lineno++
+		if m := objTextRE.FindStringSubmatch(line); m != nil {
+			flush()
+			sym, file = m[1], m[2]
+			if path, name := splitSym(strings.TrimSuffix(sym, ".abi0")); !symRE.MatchString(path + "." + name) {
+				sym = ""
+			}
+			continue
+		}
+		if sym == "" || !strings.HasPrefix(line, "  ") {
+			continue
+		}
+		var f []string
+		for _, field := range strings.Split(line, "\t") {
+			if field = strings.TrimSpace(field); field != "" {
+				f = append(f, field)
+			}
+		}
+		if len(f) < 4 {
+			warn(lineno, "unexpected objdump line: %s", line)
+			continue
+		}
+		addr, err := strconv.ParseUint(strings.TrimPrefix(f[1], "0x"), 16, 64)
+		if err != nil {
+			warn(lineno, "invalid address in objdump line: %s", line)
+			continue
+		}
+		inst := objInst{Lineno: lineno, FileLine: f[0], Addr: addr, Enc: f[2], Asm: f[3]}
+		for _, r := range f[4:] {
+			if m := objRelocRE.FindStringSubmatch(r); m != nil {
+				inst.Reloc = m[1]
+			}
+		}
+		raw = append(raw, inst)
+	}
+	flush()
+	return funcs
+}
+
+// An objInst is a single instruction line from go tool objdump.
+type objInst struct {
+	Lineno   int
+	FileLine string // short file:line
+	Addr     uint64
+	Enc      string // instruction encoding, in hex
+	Asm      string
+	Reloc    string // target of relocation, if any
+}
+
+// objConvert converts the objdump instructions for sym, from the given
+// source file, into the form of the compiler's -S output, so that asmText
+// can process them. It rewrites objdump's Intel mnemonics, hexadecimal
+// addresses, and relocations into the compiler's syntax, and it synthesizes
+// a TEXT instruction, inferring the frame size from the function prologue
+// and the argument size from the stack references.
+// For ABIInternal functions, asmText raises the argument size
+// to cover the arguments passed in registers.
+// Functions without an .abi0 suffix are marked ABIInternal,
+// and functions without a stack check are marked NOSPLIT.
+func objConvert(sym, file string, raw []objInst) objFunc {
+	name := strings.TrimSuffix(sym, ".abi0")
+	start := raw[0].Addr
+	end := raw[len(raw)-1].Addr
+
+	text := make([]Inst, 1, 1+len(raw))
+	for _, r := range raw {
+		fileLine := r.FileLine
+		if i := strings.LastIndex(fileLine, ":"); i >= 0 && fileLine[:i] == filepath.Base(file) {
+			fileLine = file + fileLine[i:]
+		}
+		op, args, _ := strings.Cut(r.Asm, " ")
+		op = objOp(op, r.Enc)
+
+		if r.Reloc != "" {
+			if strings.Contains(args, "0(IP)") {
+				args = strings.Replace(args, "0(IP)", r.Reloc+"(SB)", 1)
+			} else if (op == "CALL" || strings.HasPrefix(op, "J")) && strings.HasPrefix(args, "0x") {
+				args = r.Reloc + "(SB)"
+			}
+		}
+		args = strings.Replace(args, ".abi0(SB)", "(SB)", -1)
+		if args == name+"(SB)" && strings.HasPrefix(op, "J") {
+			args = "0"
+		} else if strings.HasPrefix(args, "0x") && (op == "CALL" || strings.HasPrefix(op, "J")) {
+			if addr, err := strconv.ParseUint(args[2:], 16, 64); err == nil && op != "CALL" && start <= addr && addr <= end {
+				args = strconv.FormatUint(addr-start, 10)
+			} else {
+				warn(r.Lineno, "%s: unresolved target: %s", name, r.Asm)
+			}
+		}
+		args = objDispRE.ReplaceAllStringFunc(args, func(s string) string {
+			m := objDispRE.FindStringSubmatch(s)
+			n, _ := strconv.ParseUint(m[3], 16, 64)
+			return m[1] + m[2] + strconv.FormatUint(n, 10) + "("
+		})
+		args = objZeroRE.ReplaceAllString(args, "$1(")
+		if strings.Contains(args, "(IP)") {
+			warn(r.Lineno, "%s: unresolved PC-relative reference: %s", name, r.Asm)
+		}
+
+		asm := op
+		if args != "" {
+			asm += "\t" + args
+		}
+		if strings.HasPrefix(op, "NOP") {
+			asm = "// " + asm + " (padding)"
+		}
+		text = append(text, Inst{
+			Lineno:   r.Lineno,
+			PC:       strconv.FormatUint(r.Addr-start, 10),
+			FileLine: fileLine,
+			Asm:      asm,
+		})
+	}
+
+	// Find the frame set up by the prologue.
+	var (
+		frame      int
+		body       = 1 // first instruction after prologue
+		stackCheck bool
+	)
+prologue:
+	for i := 1; i < len(text); i++ {
+		asm := text[i].Asm
+		switch {
+		case asm == "CMPQ\tSP, 16(R14)" || strings.HasPrefix(asm, "MOVQ\t(TLS)"):
+			stackCheck = true
+		case strings.HasPrefix(asm, "JLS\t") || asm == "MOVQ\tSP, BP":
+			// part of prologue
+		case asm == "PUSHQ\tBP":
+			frame += wordSize
+			body = i + 1
+		case strings.HasPrefix(asm, "SUBQ\t$") && strings.HasSuffix(asm, ", SP"):
+			n, err := strconv.ParseInt(strings.TrimSuffix(asm[len("SUBQ\t$"):], ", SP"), 0, 64)
+			if err != nil {
+				warn(text[i].Lineno, "invalid frame size: %s", asm)
+			}
+			frame += int(n)
+			body = i + 1
+			break prologue
+		default:
+			break prologue
+		}
+	}
+
+	// Name the stack references, so that asmText rewrites them
+	// relative to the pseudo-registers, and infer the argument size.
+	// Arguments start after the return address.
+	// The stack growth call runs before the frame is allocated.
+	args := 0
+	tail := morestackStart(text[1:]) + 1
+	for i := body; i < len(text); i++ {
+		size := opSize(text[i].Asm)
+		text[i].Asm = objSPRE.ReplaceAllStringFunc(text[i].Asm, func(s string) string {
+			m := objSPRE.FindStringSubmatch(s)
+			off, _ := strconv.Atoi(m[2])
+			if i >= tail {
+				if off >= wordSize && off-wordSize+size > args {
+					args = off - wordSize + size
+				}
+				return s
+			}
+			switch e := off - frame; {
+			case e >= wordSize:
+				if e-wordSize+size > args {
+					args = e - wordSize + size
+				}
+				return fmt.Sprintf("%sarg+%d(SP)", m[1], off)
+			case e < 0:
+				return fmt.Sprintf("%slocal+%d(SP)", m[1], off)
+			}
+			return s
+		})
+	}
+
+	var flags []string
+	if !stackCheck {
+		flags = append(flags, "NOSPLIT")
+	}
+	if !strings.HasSuffix(sym, ".abi0") {
+		flags = append(flags, "ABIInternal")
+	}
+	textInst := "TEXT\t" + name + "(SB), "
+	if len(flags) > 0 {
+		textInst += strings.Join(flags, "|") + ", "
+	}
+	text[0] = Inst{
+		Lineno:   raw[0].Lineno - 1,
+		PC:       "0",
+		FileLine: text[1].FileLine,
+		Asm:      fmt.Sprintf("%s$%d-%d", textInst, frame, args),
+	}
+	return objFunc{Sym: name, Text: text}
+}
+
+// objOp returns the Go assembler name for the objdump opcode op,
+// which has the instruction encoding enc (in hex).
+func objOp(op, enc string) string {
+	op = strings.TrimSuffix(op, "_XMM") // MOVSD_XMM is the assembler's MOVSD
+	for _, prefix := range []string{"J", "SET", "CMOV"} {
+		c, ok := conds[strings.TrimPrefix(op, prefix)]
+		if !ok || !strings.HasPrefix(op, prefix) {
+			continue
+		}
+		if prefix == "CMOV" {
+			// The assembler names the operand size,
+			// which objdump leaves implicit in the prefix bytes.
+			size := "L"
+			for i := 0; i+2 <= len(enc); i += 2 {
+				b, err := strconv.ParseUint(enc[i:i+2], 16, 8)
+				if err != nil || b != 0x66 && b&0xf0 != 0x40 {
+					break
+				}
+				if b == 0x66 {
+					size = "W"
+				} else if b&0x08 != 0 {
+					size = "Q"
+				}
+			}
+			return prefix + size + c
+		}
+		return prefix + c
+	}
+	return op
+}
+
+// opSize returns the size of the memory operand of the instruction asm,
+// guessing from its opcode suffix.
+func opSize(asm string) int {
+	op, _, _ := strings.Cut(asm, "\t")
+	switch {
+	case strings.HasSuffix(op, "SS"):
+		return 4
+	case strings.HasSuffix(op, "SD"):
+		return 8
+	case strings.HasSuffix(op, "UPS"), strings.HasSuffix(op, "UPD"), strings.HasSuffix(op, "OU"), strings.HasSuffix(op, "O"):
+		return 16
+	case strings.HasSuffix(op, "B"):
+		return 1
+	case strings.HasSuffix(op, "W"):
+		return 2
+	case strings.HasSuffix(op, "L"):
+		return 4
+	}
+	return wordSize
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// manifestFile is the name of the file in a template's root directory
+// declaring the steps to run after copying the template.
+// It is not copied into the new project.
+const manifestFile = ".gonew-template.json"
+
+// A Manifest describes the steps that produce a template's generated code.
+type Manifest struct {
+	Generate []string   // packages to run go generate on, such as "./..."
+	Run      [][]string // commands to run, as argument lists
+}
+
+// readManifest parses the manifest in files, if any,
+// and removes it from files.
+func readManifest(files map[string][]byte) (*Manifest, error) {
+	data, ok := files[manifestFile]
+	if !ok {
+		return nil, nil
+	}
+	delete(files, manifestFile)
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", manifestFile, err)
+	}
+	for _, args := range m.Run {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("parsing %s: empty Run command", manifestFile)
+		}
+	}
+	return m, nil
+}
+
+// steps returns the commands that m declares, in the order to run them:
+// the explicit commands first, since go generate directives
+// may depend on their output, and then go generate.
+func (m *Manifest) steps() [][]string {
+	var cmds [][]string
+	cmds = append(cmds, m.Run...)
+	if len(m.Generate) > 0 {
+		cmds = append(cmds, append([]string{"go", "generate"}, m.Generate...))
+	}
+	return cmds
+}
+
+// confirm prints the commands in cmds to w and asks whether to run them,
+// reading the answer from r. An answer other than y or yes,
+// including end of input, means no.
+func confirm(w io.Writer, r io.Reader, cmds [][]string) bool {
+	fmt.Fprintf(w, "the template declares commands to run in the new project:\n")
+	for _, args := range cmds {
+		fmt.Fprintf(w, "\t%s\n", strings.Join(args, " "))
+	}
+	fmt.Fprintf(w, "run them? [y/N] ")
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// runSteps runs the commands in cmds in dir, stopping at the first failure.
+// It writes each command's combined output, indented, to w.
+func runSteps(w io.Writer, dir string, cmds [][]string) error {
+	for _, args := range cmds {
+		fmt.Fprintf(w, "%s\n", strings.Join(args, " "))
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			out = bytes.TrimSuffix(out, []byte("\n"))
+			fmt.Fprintf(w, "\t%s\n", bytes.ReplaceAll(out, []byte("\n"), []byte("\n\t")))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+// generate runs the steps declared in m in the new project in dir,
+// asking for confirmation first unless -y was given.
+func generate(dir string, m *Manifest) {
+	cmds := m.steps()
+	if len(cmds) == 0 {
+		return
+	}
+	if !*yesFlag && !confirm(os.Stderr, os.Stdin, cmds) {
+		log.Printf("skipped generate steps; %s may not build until they are run", dir)
+		return
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := runSteps(os.Stderr, abs, cmds); err != nil {
+		log.Fatalf("generate steps failed: %v\n%s may not build until the remaining steps are run", err, dir)
+	}
+}
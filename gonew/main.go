@@ -6,13 +6,40 @@
 //
 // Usage:
 //
-//	gonew srcMod[@version] [dstMod [dir]]
+//	gonew [-y] srcMod[@version] [dstMod [dir]]
+//	gonew -upgrade [-w] [dir [version]]
 //
 // Gonew makes a copy of the srcMod, changing its module path to dstMod.
 // It writes that new to a new directory named by dir.
 // If dir already exists it must be an empty directory.
 // If dir is omitted, gonew uses ./elem where elem is the final path element of dstMod.
 //
+// Gonew records the template module and version it used, along with
+// the time and the version of gonew itself, in dir/.gonew.json.
+//
+// A template that relies on generated code, such as protocol buffer or
+// sqlc output, can declare the steps that produce it in a manifest file
+// named .gonew-template.json in its root directory:
+//
+//	{
+//		"Run": [["buf", "generate"], ["sqlc", "generate"]],
+//		"Generate": ["./..."]
+//	}
+//
+// After copying the template, gonew runs each Run command and then
+// go generate on the Generate packages, in the new project's directory,
+// printing their output. It lists the commands and asks for confirmation
+// before running them; the -y flag runs them without asking.
+// The manifest itself is not copied into the project.
+//
+// The -upgrade flag compares the project in dir (default ".") against
+// a newer version of its template (default latest). For each file,
+// it reports the template-side change, if any, and whether that change
+// can be applied: a change applies cleanly when the project's copy of the
+// file is unchanged from the original template, and it conflicts
+// when the project has edited the file. The -w flag applies the
+// changes that do not conflict and updates .gonew.json.
+//
 // This command is highly experimental and subject to change.
 //
 // Example
@@ -43,14 +70,37 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"time"
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "gonew srcMod[@version] [dstMod [dir]]\n")
+	fmt.Fprintf(os.Stderr, "gonew [-y] srcMod[@version] [dstMod [dir]]\n")
+	fmt.Fprintf(os.Stderr, "gonew -upgrade [-w] [dir [version]]\n")
 	os.Exit(2)
 }
 
+var (
+	upgradeFlag = flag.Bool("upgrade", false, "upgrade project to a newer template version")
+	writeFlag   = flag.Bool("w", false, "with -upgrade, apply non-conflicting changes")
+	yesFlag     = flag.Bool("y", false, "run the template's generate steps without asking")
+)
+
+// provenanceFile is the name of the file recording
+// where a project came from, in the project's root directory.
+const provenanceFile = ".gonew.json"
+
+// A Provenance records the template used to create a project.
+type Provenance struct {
+	Template string    // template module path
+	Version  string    // template module version
+	Module   string    // new module path
+	Time     time.Time // time project was created or last upgraded
+	Gonew    string    // version of gonew that wrote the file
+}
+
 func main() {
 	log.SetPrefix("gonew: ")
 	log.SetFlags(0)
@@ -58,7 +108,22 @@ func main() {
 	flag.Parse()
 	args := flag.Args()
 
-	if len(args) < 1 || len(args) > 3 {
+	if *upgradeFlag {
+		if len(args) > 2 || *yesFlag {
+			usage()
+		}
+		dir, vers := ".", "latest"
+		if len(args) >= 1 {
+			dir = args[0]
+		}
+		if len(args) >= 2 {
+			vers = args[1]
+		}
+		upgrade(dir, vers)
+		return
+	}
+
+	if len(args) < 1 || len(args) > 3 || *writeFlag {
 		usage()
 	}
 
@@ -68,7 +133,6 @@ func main() {
 		srcModVers += "@latest"
 	}
 	srcMod, _, _ = strings.Cut(srcMod, "@")
-	srcBase := path.Base(srcMod)
 
 	dstMod := srcMod
 	if len(args) >= 2 {
@@ -90,26 +154,79 @@ func main() {
 	}
 	needMkdir := err != nil
 
+	info := download(srcModVers)
+
+	if needMkdir {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	files, dirs := templateFiles(info.Dir, srcMod, dstMod)
+	manifest, err := readManifest(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, rel := range dirs {
+		if err := os.MkdirAll(filepath.Join(dir, rel), 0777); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, rel := range sortedKeys(files) {
+		dst := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(dst, files[rel], 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	writeProvenance(dir, &Provenance{
+		Template: srcMod,
+		Version:  info.Version,
+		Module:   dstMod,
+	})
+
+	if manifest != nil {
+		generate(dir, manifest)
+	}
+
+	log.Printf("initialized %s in %s", dstMod, dir)
+}
+
+// A modInfo is the result of go mod download -json.
+type modInfo struct {
+	Dir     string
+	Version string
+}
+
+// download downloads the module modVers (path@version)
+// and returns information about it.
+func download(modVers string) *modInfo {
 	var stdout, stderr bytes.Buffer
-	cmd := exec.Command("go", "mod", "download", "-json", srcModVers)
+	cmd := exec.Command("go", "mod", "download", "-json", modVers)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		log.Fatalf("go mod download -json %s: %v\n%s%s", srcModVers, err, stderr.Bytes(), stdout.Bytes())
+		log.Fatalf("go mod download -json %s: %v\n%s%s", modVers, err, stderr.Bytes(), stdout.Bytes())
 	}
 
-	var info struct {
-		Dir string
-	}
-	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
-		log.Fatalf("go mod download -json %s: invalid JSON output: %v\n%s%s", srcMod, err, stderr.Bytes(), stdout.Bytes())
+	info := new(modInfo)
+	if err := json.Unmarshal(stdout.Bytes(), info); err != nil {
+		log.Fatalf("go mod download -json %s: invalid JSON output: %v\n%s%s", modVers, err, stderr.Bytes(), stdout.Bytes())
 	}
+	return info
+}
 
-	if needMkdir {
-		if err := os.MkdirAll(dir, 0777); err != nil {
-			log.Fatal(err)
-		}
-	}
+// templateFiles returns the files in the template module
+// in srcDir, rewritten from srcMod to dstMod, along with the
+// template's directories, so that empty ones can be created too.
+// The map is keyed by slash-separated relative path,
+// and the directories are also slash-separated relative paths.
+func templateFiles(srcDir, srcMod, dstMod string) (files map[string][]byte, dirs []string) {
+	srcBase := path.Base(srcMod)
+	dstBase := path.Base(dstMod)
 
 	// Replace srcMod -> dstMod in go.mod file module line and imports.
 	r := strings.NewReplacer(
@@ -118,15 +235,15 @@ func main() {
 		`"`+srcMod+`/`, `"`+dstMod+`/`,
 	)
 
-	filepath.WalkDir(info.Dir, func(src string, d fs.DirEntry, err error) error {
+	files = make(map[string][]byte)
+	filepath.WalkDir(srcDir, func(src string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Fatal(err)
 		}
-		rel := strings.Trim(strings.TrimPrefix(src, info.Dir), string(filepath.Separator))
-		dst := filepath.Join(dir, rel)
+		rel := strings.Trim(strings.TrimPrefix(src, srcDir), string(filepath.Separator))
 		if d.IsDir() {
-			if err := os.MkdirAll(dst, 0777); err != nil {
-				log.Fatal(err)
+			if rel != "" {
+				dirs = append(dirs, filepath.ToSlash(rel))
 			}
 			return nil
 		}
@@ -142,11 +259,144 @@ func main() {
 			old = strings.ReplaceAll(old, "package "+srcBase+"\n", "package "+dstBase+"\n")
 		}
 		r.WriteString(&buf, old)
-		if err := os.WriteFile(dst, buf.Bytes(), 0666); err != nil {
-			log.Fatal(err)
-		}
+		files[filepath.ToSlash(rel)] = buf.Bytes()
 		return nil
 	})
+	return files, dirs
+}
 
-	log.Printf("initialized %s in %s", dstMod, dir)
+func sortedKeys(m map[string][]byte) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeProvenance writes p to dir's provenance file,
+// filling in the time and gonew version.
+func writeProvenance(dir string, p *Provenance) {
+	p.Time = time.Now().UTC().Truncate(time.Second)
+	p.Gonew = "(devel)"
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		p.Gonew = bi.Main.Version
+	}
+	js, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+	js = append(js, '\n')
+	if err := os.WriteFile(filepath.Join(dir, provenanceFile), js, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// upgrade compares the project in dir against version vers of its template.
+// See the package comment for details.
+func upgrade(dir, vers string) {
+	data, err := os.ReadFile(filepath.Join(dir, provenanceFile))
+	if err != nil {
+		log.Fatalf("cannot upgrade: %v", err)
+	}
+	var p Provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		log.Fatalf("cannot upgrade: parsing %s: %v", provenanceFile, err)
+	}
+
+	oldInfo := download(p.Template + "@" + p.Version)
+	newInfo := download(p.Template + "@" + vers)
+	if newInfo.Version == p.Version {
+		log.Printf("%s is already at %s@%s", dir, p.Template, p.Version)
+		return
+	}
+	oldFiles, _ := templateFiles(oldInfo.Dir, p.Template, p.Module)
+	newFiles, newDirs := templateFiles(newInfo.Dir, p.Template, p.Module)
+	delete(oldFiles, manifestFile)
+	delete(newFiles, manifestFile)
+	conflicts := applyUpgrade(dir, oldFiles, newFiles, newDirs, *writeFlag)
+
+	if !*writeFlag {
+		return
+	}
+	if conflicts > 0 {
+		// Leave the recorded version alone, so that the next upgrade
+		// reports the conflicting changes again.
+		log.Printf("%d conflicting files must be updated by hand; %s remains at %s@%s until gonew -upgrade -w is rerun", conflicts, dir, p.Template, p.Version)
+		return
+	}
+	p.Version = newInfo.Version
+	writeProvenance(dir, &p)
+	log.Printf("upgraded %s to %s@%s", dir, p.Template, p.Version)
+}
+
+// applyUpgrade compares the project in dir against the change
+// from oldFiles to newFiles in its template, printing a line for each
+// changed file. If write is true, it applies the changes that do not
+// conflict with local edits, and it creates any directories in newDirs
+// that are missing. It returns the number of conflicting files.
+func applyUpgrade(dir string, oldFiles, newFiles map[string][]byte, newDirs []string, write bool) (conflicts int) {
+	if write {
+		for _, rel := range newDirs {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(rel)), 0777); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	all := make(map[string][]byte)
+	for rel := range oldFiles {
+		all[rel] = nil
+	}
+	for rel := range newFiles {
+		all[rel] = nil
+	}
+
+	for _, rel := range sortedKeys(all) {
+		oldData, inOld := oldFiles[rel]
+		newData, inNew := newFiles[rel]
+		if inOld == inNew && bytes.Equal(oldData, newData) {
+			continue // unchanged in template
+		}
+		file := filepath.Join(dir, filepath.FromSlash(rel))
+		local, err := os.ReadFile(file)
+		inLocal := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+
+		var what string
+		switch {
+		case !inNew:
+			what = "delete"
+		case !inOld:
+			what = "add"
+		default:
+			what = "update"
+		}
+
+		switch {
+		case inLocal == inNew && bytes.Equal(local, newData):
+			fmt.Printf("%s %s: already up to date\n", what, rel)
+		case inLocal == inOld && bytes.Equal(local, oldData):
+			fmt.Printf("%s %s\n", what, rel)
+			if write {
+				if !inNew {
+					err = os.Remove(file)
+				} else {
+					if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+						log.Fatal(err)
+					}
+					err = os.WriteFile(file, newData, 0666)
+				}
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+		default:
+			fmt.Printf("%s %s: conflicts with local edits\n", what, rel)
+			conflicts++
+		}
+	}
+	return conflicts
 }
@@ -0,0 +1,211 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTemplateFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":      "module example.com/tmpl\n",
+		"main.go":     "package main\n\nimport \"example.com/tmpl/sub\"\n",
+		"sub/sub.go":  "package sub\n",
+		"tmpl.go":     "package tmpl\n",
+		"sub/tmpl.go": "package tmpl\n",
+	})
+	if err := os.MkdirAll(filepath.Join(dir, "empty/inner"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	files, dirs := templateFiles(dir, "example.com/tmpl", "your.domain/prog")
+	got := make(map[string]string)
+	for name, data := range files {
+		got[name] = string(data)
+	}
+	want := map[string]string{
+		"go.mod":      "module your.domain/prog\n",
+		"main.go":     "package main\n\nimport \"your.domain/prog/sub\"\n",
+		"sub/sub.go":  "package sub\n",
+		"tmpl.go":     "package prog\n",
+		"sub/tmpl.go": "package tmpl\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("templateFiles files:\nhave %q\nwant %q", got, want)
+	}
+	wantDirs := []string{"empty", "empty/inner", "sub"}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("templateFiles dirs = %q, want %q", dirs, wantDirs)
+	}
+}
+
+func TestApplyUpgrade(t *testing.T) {
+	oldFiles := map[string][]byte{
+		"same.go":    []byte("same\n"),
+		"update.go":  []byte("old\n"),
+		"edited.go":  []byte("old\n"),
+		"delete.go":  []byte("gone\n"),
+		"current.go": []byte("old\n"),
+	}
+	newFiles := map[string][]byte{
+		"same.go":    []byte("same\n"),
+		"update.go":  []byte("new\n"),
+		"edited.go":  []byte("new\n"),
+		"current.go": []byte("new\n"),
+		"sub/add.go": []byte("added\n"),
+	}
+	local := map[string]string{
+		"same.go":    "same\n",
+		"update.go":  "old\n",
+		"edited.go":  "mine\n",
+		"delete.go":  "gone\n",
+		"current.go": "new\n",
+	}
+
+	for _, write := range []bool{false, true} {
+		dir := t.TempDir()
+		writeFiles(t, dir, local)
+		conflicts := applyUpgrade(dir, oldFiles, newFiles, []string{"sub", "empty"}, write)
+		if conflicts != 1 {
+			t.Errorf("write=%v: applyUpgrade = %d conflicts, want 1", write, conflicts)
+		}
+
+		want := local
+		if write {
+			want = map[string]string{
+				"same.go":    "same\n",
+				"update.go":  "new\n",
+				"edited.go":  "mine\n",
+				"current.go": "new\n",
+				"sub/add.go": "added\n",
+			}
+		}
+		got := make(map[string]string)
+		var dirs []string
+		filepath.WalkDir(dir, func(file string, d os.DirEntry, err error) error {
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, _ := filepath.Rel(dir, file)
+			rel = filepath.ToSlash(rel)
+			if d.IsDir() {
+				if rel != "." {
+					dirs = append(dirs, rel)
+				}
+				return nil
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[rel] = string(data)
+			return nil
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("write=%v: after applyUpgrade:\nhave %q\nwant %q", write, got, want)
+		}
+		var wantDirs []string
+		if write {
+			wantDirs = []string{"empty", "sub"}
+		}
+		if !reflect.DeepEqual(dirs, wantDirs) {
+			t.Errorf("write=%v: directories = %q, want %q", write, dirs, wantDirs)
+		}
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	files := map[string][]byte{
+		"go.mod":     []byte("module your.domain/prog\n"),
+		manifestFile: []byte(`{"Run": [["sqlc", "generate"]], "Generate": ["./..."]}`),
+	}
+	m, err := readManifest(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files[manifestFile]; ok {
+		t.Errorf("readManifest did not remove %s from files", manifestFile)
+	}
+	want := [][]string{{"sqlc", "generate"}, {"go", "generate", "./..."}}
+	if steps := m.steps(); !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %q, want %q", steps, want)
+	}
+
+	m, err = readManifest(map[string][]byte{"go.mod": nil})
+	if m != nil || err != nil {
+		t.Errorf("readManifest without manifest = %v, %v, want nil, nil", m, err)
+	}
+	_, err = readManifest(map[string][]byte{manifestFile: []byte(`{"Run": [[]]}`)})
+	if err == nil {
+		t.Errorf("readManifest with empty command succeeded")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want bool
+	}{
+		{"y\n", true},
+		{" Yes\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	} {
+		var out strings.Builder
+		if got := confirm(&out, strings.NewReader(tt.in), [][]string{{"go", "generate", "./..."}}); got != tt.want {
+			t.Errorf("confirm(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if !strings.Contains(out.String(), "\tgo generate ./...\n") {
+			t.Errorf("confirm(%q) did not list command:\n%s", tt.in, out.String())
+		}
+	}
+}
+
+func TestRunSteps(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module your.domain/prog\n",
+		"prog.go": "package prog\n\n//go:generate go run gen.go\n",
+		"gen.go":  "//go:build ignore\n\npackage main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\tfmt.Println(\"generating\")\n\tos.WriteFile(\"zgen.go\", []byte(\"package prog\\n\\nconst Generated = true\\n\"), 0666)\n}\n",
+	})
+	var out strings.Builder
+	if err := runSteps(&out, dir, [][]string{{"go", "generate", "./..."}}); err != nil {
+		t.Fatalf("runSteps: %v\n%s", err, out.String())
+	}
+	if want := "go generate ./...\n\tgenerating\n"; out.String() != want {
+		t.Errorf("runSteps output = %q, want %q", out.String(), want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "zgen.go")); err != nil {
+		t.Errorf("go generate did not write zgen.go: %v", err)
+	}
+
+	out.Reset()
+	err := runSteps(&out, dir, [][]string{{"go", "vet", "./nonexistent"}, {"go", "generate", "./..."}})
+	if err == nil {
+		t.Fatalf("runSteps with failing command succeeded")
+	}
+	if strings.Contains(out.String(), "go generate") {
+		t.Errorf("runSteps continued after failure:\n%s", out.String())
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A Mismatch classifies the difference between a rebuilt file and the posted one.
+type Mismatch string
+
+// The mismatch classes, from least to most serious.
+// Undiagnosed means that the files could not be compared in detail,
+// because they are not archives that Diagnose can read.
+const (
+	Undiagnosed   Mismatch = "undiagnosed"
+	MetadataOnly  Mismatch = "metadata-only"   // archive headers differ; all file content matches
+	SignatureOnly Mismatch = "signature-only"  // content matches after stripping code signatures
+	BuildInfoOnly Mismatch = "buildinfo-only"  // executables match except for build ID and embedded build info
+	CodeDiff      Mismatch = "code difference" // other content differs
+)
+
+var mismatchRank = map[Mismatch]int{
+	MetadataOnly:  1,
+	SignatureOnly: 2,
+	BuildInfoOnly: 3,
+	CodeDiff:      4,
+}
+
+// maxDiagnosed is the number of differing files for which
+// Diagnose logs details.
+const maxDiagnosed = 20
+
+// Diagnose examines the archives rebuilt and posted for the named file,
+// which do not match, logging what it finds, and returns a classification
+// of the mismatch. It compares the content of the files in the archives,
+// ignoring archive headers such as timestamps. For each file whose content
+// differs, it strips code signatures and re-compares, and for Go executables
+// it compares the build IDs and embedded build info.
+// The overall classification is the most serious found for any file.
+// If name is not a .tar.gz or .zip file, or the archives cannot be read,
+// Diagnose returns Undiagnosed.
+func Diagnose(log *Log, name string, rebuilt, posted []byte) Mismatch {
+	rsums := contentSums(log, name, rebuilt)
+	psums := contentSums(log, name, posted)
+	if rsums == nil || psums == nil {
+		log.Printf("diagnosis: %s: cannot read archives", Undiagnosed)
+		return Undiagnosed
+	}
+
+	var differ []string
+	for file, sum := range rsums {
+		psum, ok := psums[file]
+		if !ok {
+			log.Printf("diagnosis: %s: missing from posted archive", file)
+			return CodeDiff
+		}
+		if sum != psum {
+			differ = append(differ, file)
+		}
+	}
+	for file := range psums {
+		if _, ok := rsums[file]; !ok {
+			log.Printf("diagnosis: %s: unexpected file in posted archive", file)
+			return CodeDiff
+		}
+	}
+	sort.Strings(differ)
+	if len(differ) == 0 {
+		log.Printf("diagnosis: %s: all file content matches; only archive metadata differs", MetadataOnly)
+		return MetadataOnly
+	}
+
+	want := make(map[string]bool)
+	for _, file := range differ {
+		want[file] = true
+	}
+	rdata := archiveFiles(log, name, rebuilt, want)
+	pdata := archiveFiles(log, name, posted, want)
+	if rdata == nil || pdata == nil {
+		log.Printf("diagnosis: %s: cannot read archives", Undiagnosed)
+		return Undiagnosed
+	}
+
+	worst := MetadataOnly
+	count := make(map[Mismatch]int)
+	for i, file := range differ {
+		flog := log
+		if i >= maxDiagnosed {
+			flog = nil
+		}
+		m := diagnoseFile(flog, file, rdata[file], pdata[file])
+		count[m]++
+		if mismatchRank[m] > mismatchRank[worst] {
+			worst = m
+		}
+	}
+	if len(differ) > maxDiagnosed {
+		log.Printf("diagnosis: eliding details for %d more files", len(differ)-maxDiagnosed)
+	}
+
+	var counts []string
+	for _, m := range []Mismatch{SignatureOnly, BuildInfoOnly, CodeDiff} {
+		if count[m] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count[m], m))
+		}
+	}
+	log.Printf("diagnosis: %s: %d files differ (%s)", worst, len(differ), strings.Join(counts, ", "))
+	return worst
+}
+
+// diagnoseFile classifies the difference between the rebuilt and posted
+// content of a single file, logging details to log if log is non-nil.
+func diagnoseFile(log *Log, file string, rebuilt, posted []byte) Mismatch {
+	logf := func(format string, args ...any) {
+		if log != nil {
+			log.Printf("diagnosis: %s: "+format, append([]any{file}, args...)...)
+		}
+	}
+
+	// Strip variant data: code signatures.
+	// StripDarwinSig edits its argument, so pass copies.
+	slog := log
+	if slog == nil {
+		slog = new(Log)
+	}
+	rs := StripDarwinSig(slog, file, bytes.Clone(rebuilt))
+	ps := StripDarwinSig(slog, file, bytes.Clone(posted))
+	if bytes.Equal(rs, ps) {
+		logf("content matches after stripping code signatures")
+		return SignatureOnly
+	}
+
+	rinfo, rerr := buildinfo.Read(bytes.NewReader(rs))
+	pinfo, perr := buildinfo.Read(bytes.NewReader(ps))
+	if rerr != nil || perr != nil {
+		logf("content differs (%d bytes rebuilt, %d bytes posted)", len(rebuilt), len(posted))
+		return CodeDiff
+	}
+
+	if rid, pid := buildID(rs), buildID(ps); rid != pid {
+		logf("rebuilt build ID %q, posted %q", rid, pid)
+	}
+	rstr, pstr := rinfo.String(), pinfo.String()
+	if rstr != pstr {
+		for _, line := range lineDiff(rstr, pstr) {
+			logf("build info %s", line)
+		}
+	}
+	if bytes.Equal(blankBuildInfo(blankBuildID(rs)), blankBuildInfo(blankBuildID(ps))) {
+		logf("content matches except for build ID and build info")
+		return BuildInfoOnly
+	}
+	if rstr == pstr {
+		logf("executable code differs with identical build info")
+	} else {
+		logf("executable code differs as well as build info")
+	}
+	return CodeDiff
+}
+
+// contentSums returns the SHA256 of the content of each file
+// in the named archive data, or nil if the archive cannot be read.
+func contentSums(log *Log, name string, data []byte) map[string]string {
+	sums := make(map[string]string)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"):
+		ix := IndexTarGz(log, data, nil)
+		if ix == nil {
+			return nil
+		}
+		for file, f := range ix {
+			sums[file] = f.SHA256
+		}
+	case strings.HasSuffix(name, ".zip"):
+		ix := IndexZip(log, data, nil)
+		if ix == nil {
+			return nil
+		}
+		for file, f := range ix {
+			sums[file] = f.SHA256
+		}
+	default:
+		return nil
+	}
+	return sums
+}
+
+// archiveFiles returns the content of the files listed in want
+// from the named archive data, or nil if the archive cannot be read.
+func archiveFiles(log *Log, name string, data []byte, want map[string]bool) map[string][]byte {
+	files := make(map[string][]byte)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"):
+		tr, err := OpenTarGz(data)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("reading tgz: %v", err)
+				return nil
+			}
+			if !want[hdr.Name] {
+				continue
+			}
+			if files[hdr.Name], err = io.ReadAll(tr); err != nil {
+				log.Printf("reading %s from tgz: %v", hdr.Name, err)
+				return nil
+			}
+		}
+	case strings.HasSuffix(name, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		for _, f := range zr.File {
+			if !want[f.Name] {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				log.Printf("%v", err)
+				return nil
+			}
+			files[f.Name], err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				log.Printf("%v", err)
+				return nil
+			}
+		}
+	default:
+		return nil
+	}
+	return files
+}
+
+// buildIDPrefix and buildIDSuffix bracket the Go build ID
+// at the start of the text segment of Mach-O and PE executables.
+// ELF executables record it in a note instead.
+// See cmd/internal/buildid.
+var (
+	buildIDPrefix = []byte("\xff Go build ID: \"")
+	buildIDSuffix = []byte("\"\n \xff")
+)
+
+// buildID returns the Go build ID in the executable data, or "".
+func buildID(data []byte) string {
+	if f, err := elf.NewFile(bytes.NewReader(data)); err == nil {
+		s := f.Section(".note.go.buildid")
+		if s == nil {
+			return ""
+		}
+		note, err := s.Data()
+		if err != nil || len(note) < 16 {
+			return ""
+		}
+		// The note is namesz, descsz, type (4 bytes each),
+		// the name "Go\x00\x00", and the build ID.
+		descsz := int(f.ByteOrder.Uint32(note[4:]))
+		if string(note[12:16]) != "Go\x00\x00" || 16+descsz > len(note) {
+			return ""
+		}
+		return string(note[16 : 16+descsz])
+	}
+
+	i := bytes.Index(data, buildIDPrefix)
+	if i < 0 {
+		return ""
+	}
+	rest := data[i+len(buildIDPrefix):]
+	j := bytes.Index(rest, buildIDSuffix)
+	if j < 0 {
+		return ""
+	}
+	return string(rest[:j])
+}
+
+// gnuBuildID returns the GNU build ID in the ELF executable data, or "".
+// The Go linker derives it from the Go build ID.
+func gnuBuildID(data []byte) string {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	s := f.Section(".note.gnu.build-id")
+	if s == nil {
+		return ""
+	}
+	note, err := s.Data()
+	if err != nil || len(note) < 16 {
+		return ""
+	}
+	// The note is namesz, descsz, type (4 bytes each),
+	// the name "GNU\x00", and the build ID.
+	descsz := int(f.ByteOrder.Uint32(note[4:]))
+	if string(note[12:16]) != "GNU\x00" || 16+descsz > len(note) {
+		return ""
+	}
+	return string(note[16 : 16+descsz])
+}
+
+// blankBuildID returns a copy of the executable data with
+// every occurrence of its build ID, and of the GNU build ID
+// derived from it, replaced by zeros.
+func blankBuildID(data []byte) []byte {
+	for _, id := range []string{buildID(data), gnuBuildID(data)} {
+		if id != "" {
+			data = bytes.ReplaceAll(data, []byte(id), make([]byte, len(id)))
+		}
+	}
+	return data
+}
+
+// buildInfoMagic is the start of the build info blob in a Go executable.
+// See debug/buildinfo.
+var buildInfoMagic = []byte("\xff Go buildinf:")
+
+// blankBuildInfo returns a copy of the executable data with
+// the Go version and module information recorded in its build info
+// replaced by zeros, wherever they appear.
+// It only understands the build info written by Go 1.18 and later,
+// which stores the strings inline; for older executables,
+// it returns data unchanged.
+func blankBuildInfo(data []byte) []byte {
+	i := bytes.Index(data, buildInfoMagic)
+	if i < 0 || len(data)-i < 32 || data[i+15]&2 == 0 {
+		return data
+	}
+	data = bytes.Clone(data)
+	blob := data[i+32:]
+	var strs [][]byte
+	for j := 0; j < 2; j++ {
+		n, w := binary.Uvarint(blob)
+		if w <= 0 || uint64(len(blob)-w) < n {
+			return data
+		}
+		end := w + int(n)
+		strs = append(strs, bytes.Clone(blob[w:end]))
+		copy(blob[:end], make([]byte, end))
+		blob = blob[end:]
+	}
+	for _, s := range strs {
+		if len(s) > 0 {
+			data = bytes.ReplaceAll(data, s, make([]byte, len(s)))
+		}
+	}
+	return data
+}
+
+// lineDiff returns the lines that appear in only one of x and y,
+// prefixed by "rebuilt: " or "posted: ".
+func lineDiff(x, y string) []string {
+	inX := make(map[string]bool)
+	inY := make(map[string]bool)
+	for _, line := range strings.Split(x, "\n") {
+		inX[line] = true
+	}
+	for _, line := range strings.Split(y, "\n") {
+		inY[line] = true
+	}
+	var diff []string
+	for _, line := range strings.Split(x, "\n") {
+		if !inY[line] {
+			diff = append(diff, "rebuilt: "+line)
+		}
+	}
+	for _, line := range strings.Split(y, "\n") {
+		if !inX[line] {
+			diff = append(diff, "posted: "+line)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Rough disk space needed for a single rebuild and a single bootstrap toolchain.
+// A rebuild unpacks the Go source tree, builds it, and writes the
+// distpack archives; a bootstrap unpacks and builds (or downloads)
+// an older toolchain.
+const (
+	reproSpace     = 1 << 30
+	bootstrapSpace = 512 << 20
+)
+
+// EstimateSpace returns the approximate disk space in bytes needed
+// to rebuild the files in r, along with the number of rebuilds
+// and bootstrap toolchains that the estimate assumes.
+func (r *Report) EstimateSpace() (space int64, repros, bootstraps int) {
+	need := make(map[string]bool)
+	for _, rel := range r.Releases {
+		for _, f := range rel.Files {
+			if f.dl != nil && f.dl.Kind == "archive" {
+				repros++
+			}
+		}
+		// Full bootstraps build the whole chain of earlier toolchains;
+		// otherwise only the directly needed toolchain is downloaded.
+		v := rel.Version
+		for {
+			bver, err := BootstrapVersion(v)
+			if err != nil || bver == "" || need[bver] {
+				break
+			}
+			need[bver] = true
+			if !r.Full {
+				break
+			}
+			v = bver
+		}
+	}
+	bootstraps = len(need)
+	return int64(repros)*reproSpace + int64(bootstraps)*bootstrapSpace, repros, bootstraps
+}
+
+// CheckSpace checks that the file system holding the work directory
+// has room for the rebuilds in r, returning an error if not.
+// If the available space cannot be determined, CheckSpace assumes it is enough.
+func (r *Report) CheckSpace() error {
+	need, repros, bootstraps := r.EstimateSpace()
+	avail, ok := diskFree(r.Work)
+	if !ok {
+		r.Log.Printf("need about %s disk space for %d rebuilds and %d bootstraps", bytesString(need), repros, bootstraps)
+		return nil
+	}
+	r.Log.Printf("need about %s disk space for %d rebuilds and %d bootstraps; %s available in %s",
+		bytesString(need), repros, bootstraps, bytesString(avail), r.Work)
+	if avail < need {
+		return fmt.Errorf("insufficient disk space in %s: need about %s, have %s (use -work to choose another directory, -keep to prune earlier runs, or name fewer targets)",
+			r.Work, bytesString(need), bytesString(avail))
+	}
+	return nil
+}
+
+// workDone is the name of the file that marks a finished work directory.
+// Run creates it when it is done with the directory,
+// and PruneWork removes only marked directories,
+// so that it never removes the work directory of a running gorebuild.
+const workDone = "gorebuild.done"
+
+// MarkWorkDone marks the work directory dir as finished.
+func MarkWorkDone(log *Log, dir string) {
+	if err := os.WriteFile(filepath.Join(dir, workDone), nil, 0666); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// PruneWork removes all but the keep most recently finished
+// gorebuild work directories in the parent directory dir.
+// It leaves alone directories not marked finished by MarkWorkDone,
+// which may belong to other gorebuild processes that are still running.
+func PruneWork(log *Log, dir string, keep int) {
+	list, err := filepath.Glob(filepath.Join(dir, "gorebuild-*"))
+	if err != nil {
+		return
+	}
+	type work struct {
+		name  string
+		mtime int64
+	}
+	var works []work
+	for _, name := range list {
+		info, err := os.Stat(filepath.Join(name, workDone))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		works = append(works, work{name, info.ModTime().UnixNano()})
+	}
+	sort.Slice(works, func(i, j int) bool { return works[i].mtime > works[j].mtime })
+	for i := keep; i < len(works); i++ {
+		log.Printf("removing old work directory %s", works[i].name)
+		if err := os.RemoveAll(works[i].name); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+// bytesString returns a human-readable form of the byte count n.
+func bytesString(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,14 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !(darwin || freebsd || linux || openbsd)
+
+package main
+
+// diskFree returns the number of bytes available to unprivileged users
+// in the file system holding dir.
+// On this system, the space is unknown.
+func diskFree(dir string) (int64, bool) {
+	return 0, false
+}
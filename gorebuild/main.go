@@ -6,7 +6,7 @@
 //
 // Usage:
 //
-//	gorebuild [-p N] [goos-goarch][@version]...
+//	gorebuild [-p N] [-work dir] [-keep N] [goos-goarch][@version]...
 //
 // With no arguments, gorebuild rebuilds and verifies the files for all systems
 // (that is, all operating system-architecture pairs) for up to three versions of Go:
@@ -29,6 +29,18 @@
 //
 // The -p flag specifies how many toolchain rebuilds to run in parallel (default 2).
 //
+// Gorebuild does its work in a new directory named gorebuild-* in the
+// directory given by the -work flag (default the system temporary directory).
+// It leaves the work directory in place after it finishes, for inspection.
+// Each rebuild can need a gigabyte or more of disk space, so before starting,
+// gorebuild estimates the space needed for the requested rebuilds and the
+// bootstrap toolchains they require, and it fails immediately if the
+// file system holding the work directory does not have that much space available.
+// The -keep flag specifies how many work directories from earlier runs to keep;
+// gorebuild removes older ones before starting. The default, -1, keeps them all.
+// Gorebuild only removes directories from runs that have finished,
+// so it does not disturb other gorebuild processes using the same directory.
+//
 // When running on linux-amd64, gorebuild does a full bootstrap, building Go 1.4
 // (written in C) with the host C compiler, then building Go 1.17 with Go 1.4,
 // then building Go 1.20 using Go 1.17, and so on, up to the target toolchain.
@@ -68,6 +80,15 @@
 //     If “msiextract” is not found in the PATH, the .msi file is skipped
 //     rather than considered a failure.
 //
+// When a rebuilt file does not match the posted one, gorebuild diagnoses the
+// difference: it compares the files in the two archives, strips code signatures
+// and re-compares, and compares the build IDs and embedded build information
+// of executables. The report classifies each mismatch as metadata-only
+// (only archive headers such as timestamps differ), signature-only,
+// buildinfo-only (executables match once their build IDs and build
+// information are blanked out), or a code difference.
+// Files that are not .tar.gz or .zip archives are reported as undiagnosed.
+//
 // Gorebuild prints log messages to standard error but also accumulates them
 // in a structured report. Before exiting, it writes the report as JSON to gorebuild.json
 // and as HTML to gorebuild.html.
@@ -88,10 +109,14 @@ import (
 	"strings"
 )
 
-var pFlag = flag.Int("p", 1, "run `n` builds in parallel")
+var (
+	pFlag    = flag.Int("p", 1, "run `n` builds in parallel")
+	workFlag = flag.String("work", "", "create work directory in `dir` (default system temporary directory)")
+	keepFlag = flag.Int("keep", -1, "keep only the `n` most recent earlier work directories (-1 for all)")
+)
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: gorebuild [goos-goarch][@version]...\n")
+	fmt.Fprintf(os.Stderr, "usage: gorebuild [-p n] [-work dir] [-keep n] [goos-goarch][@version]...\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
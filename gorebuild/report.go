@@ -58,6 +58,10 @@ type File struct {
 	SHA256 string // SHA256 hex of file
 	Log    Log
 
+	// Mismatch classifies the difference from the posted file
+	// when the rebuilt file does not match.
+	Mismatch Mismatch `json:",omitempty"`
+
 	dl *DLFile
 }
 
@@ -130,10 +134,21 @@ func Run(args []string) *Report {
 		}
 	}()
 
-	r.Work, err = os.MkdirTemp("", "gorebuild-")
+	workDir := *workFlag
+	if workDir == "" {
+		workDir = os.TempDir()
+	} else if err = os.MkdirAll(workDir, 0777); err != nil {
+		err = fmt.Errorf("creating work directory: %v", err)
+		return r
+	}
+	if *keepFlag >= 0 {
+		PruneWork(&r.Log, workDir, *keepFlag)
+	}
+	r.Work, err = os.MkdirTemp(workDir, "gorebuild-")
 	if err != nil {
 		return r
 	}
+	defer MarkWorkDone(&r.Log, r.Work)
 
 	r.dl, err = DLReleases(&r.Log)
 	if err != nil {
@@ -165,6 +180,10 @@ func Run(args []string) *Report {
 		}
 	}
 
+	if err = r.CheckSpace(); err != nil {
+		return r
+	}
+
 	// Do the work.
 	// Fetch or build the bootstraps single-threaded.
 	for _, rel := range r.Releases {
@@ -351,7 +370,8 @@ func (r *Report) ReproFile(rel *Release, file *File, src []byte) (err error) {
 			if strings.HasSuffix(bf.Name, ".zip") {
 				DiffZip(&bf.Log, data, pubData, nil)
 			}
-			bf.Log.Printf("FAIL: rebuilt SHA256 %s does not match public download SHA256 %s", SHA256(data), SHA256(pubData))
+			bf.Mismatch = Diagnose(&bf.Log, bf.Name, data, pubData)
+			bf.Log.Printf("FAIL: rebuilt SHA256 %s does not match public download SHA256 %s (%s)", SHA256(data), SHA256(pubData), bf.Mismatch)
 			continue
 		}
 		bf.Log.Printf("PASS: rebuilt with GOOS=%s GOARCH=%s", file.GOOS, file.GOARCH)
@@ -1,3 +1,26 @@
+// Grpcbench measures the latency of large gRPC and HTTP calls.
+//
+// Usage:
+//
+//	grpcbench [flags]
+//	grpcbench serve [flags]
+//	grpcbench load -target host:port [flags]
+//
+// By default, grpcbench runs both a server and a client in a single process,
+// connected over loopback with artificial latency (see -latency),
+// and prints the duration of the client's calls.
+//
+// To measure a real network path instead, run the server and the client
+// as separate processes, usually on separate machines.
+// "grpcbench serve" runs only the server, listening on -addr.
+// "grpcbench load" runs only the client, calling the server at -target,
+// which defaults to -addr. The -grpc and -http2 flags must match
+// between the two. The -latency flag adds artificial latency
+// on the server side; it is usually set to 0 when measuring a real network.
+// In load mode, -latency only labels the printed results,
+// so it should be set to match the server.
+// The server in serve mode accepts messages of any size, since it
+// cannot know the -size used by the client.
 package main
 
 import (
@@ -20,10 +43,13 @@ import (
 )
 
 var (
-	numRuns  = flag.Int("n", 2, "number of calls to make")
+	numRuns  = flag.Int("n", 2, "number of calls to measure")
+	warmup   = flag.Int("warmup", 0, "number of unmeasured calls to make first")
+	raw      = flag.Bool("raw", false, "print each call's duration instead of summary statistics")
 	latency  = flag.Duration("latency", 4*time.Millisecond, "artificial latency to introduce (symmetric)")
 	msgSize  = flag.Int("size", 1<<20, "message size")
 	addr     = flag.String("addr", "localhost:8080", "listen address")
+	target   = flag.String("target", "", "with load, server `address` to call (default -addr)")
 	useGRPC  = flag.Bool("grpc", true, "use GRPC (fall back is plain HTTP)")
 	useHTTP2 = flag.Bool("http2", true, "use HTTP2")
 	verbose  = flag.Bool("v", false, "verbose output")
@@ -34,89 +60,139 @@ const (
 	keyFile  = "key.pem"
 )
 
-func main() {
-	flag.Parse()
+// checkSize reports whether the server should check
+// that received messages are -size bytes long.
+var checkSize = true
 
-	ready := make(chan struct{})
-	go func() {
-		<-ready
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: grpcbench [flags]\n")
+	fmt.Fprintf(os.Stderr, "       grpcbench serve [flags]\n")
+	fmt.Fprintf(os.Stderr, "       grpcbench load -target host:port [flags]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
 
-		var client helloworld.GreeterClient
-		if *useGRPC {
-			opts := []grpc.DialOption{
-				grpc.WithBlock(),
-				grpc.WithTimeout(3 * time.Second),
-				grpc.WithInsecure(),
-			}
-			conn, err := grpc.Dial(*addr, opts...)
-			if err != nil {
-				log.Fatalf("grpc.Dial: %v", err)
-			}
-			client = helloworld.NewGreeterClient(conn)
-		} else {
-			t := (http.DefaultTransport.(*http.Transport))
-			t.TLSClientConfig = &tls.Config{
-				InsecureSkipVerify: true,
-			}
-			if *useHTTP2 {
-				if err := http2.ConfigureTransport(t); err != nil {
-					log.Fatal(err)
-				}
-			}
+func main() {
+	flag.Usage = usage
+	mode := ""
+	if len(os.Args) > 1 && (os.Args[1] == "serve" || os.Args[1] == "load") {
+		mode = os.Args[1]
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+	if flag.NArg() != 0 || *target != "" && mode != "load" {
+		usage()
+	}
+
+	switch mode {
+	case "serve":
+		checkSize = false
+		serve(listen())
+	case "load":
+		if *target == "" {
+			*target = *addr
 		}
+		load(*target)
+	default:
+		l := listen()
+		go func() {
+			load(*addr)
+			os.Exit(0)
+		}()
+		serve(l)
+	}
+}
 
-		ctx := context.Background()
+// listen returns a listener on -addr that adds -latency to each connection.
+func listen() net.Listener {
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rate := Rate{Latency: *latency}
+	return &Listener{l, rate, rate}
+}
 
-		for i := 0; i < *numRuns; i++ {
-			randomBytes := make([]byte, *msgSize)
-			n, err := rand.Read(randomBytes)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if n != *msgSize {
-				log.Fatal("didn't read enough bytes")
-			}
-			msg := string(randomBytes)
-
-			t1 := time.Now()
-			var proto string
-			if *useGRPC {
-				_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: msg})
-				proto = "GRPC"
-			} else {
-				var resp *http.Response
-				resp, err = http.Post("https://"+*addr, "text/plain", bytes.NewReader(randomBytes))
-				proto = "HTTP"
-				if resp != nil {
-					proto = resp.Proto
-					resp.Body.Close()
-				}
-			}
-			if *verbose {
-				fmt.Println()
-			}
-			fmt.Printf("%v\t%v\t%v\n", time.Now().Sub(t1), *latency, proto)
-			if err != nil {
+// load makes the calls to the server at target and prints their durations.
+func load(target string) {
+	var client helloworld.GreeterClient
+	if *useGRPC {
+		opts := []grpc.DialOption{
+			grpc.WithBlock(),
+			grpc.WithTimeout(3 * time.Second),
+			grpc.WithInsecure(),
+		}
+		conn, err := grpc.Dial(target, opts...)
+		if err != nil {
+			log.Fatalf("grpc.Dial: %v", err)
+		}
+		client = helloworld.NewGreeterClient(conn)
+	} else {
+		t := (http.DefaultTransport.(*http.Transport))
+		t.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+		if *useHTTP2 {
+			if err := http2.ConfigureTransport(t); err != nil {
 				log.Fatal(err)
 			}
 		}
+	}
 
-		os.Exit(0)
-	}()
+	ctx := context.Background()
 
-	var server *grpc.Server
-	if *useGRPC {
-		server = grpc.NewServer()
-		helloworld.RegisterGreeterServer(server, greeter{})
+	var times []time.Duration
+	var proto string
+	for i := 0; i < *warmup+*numRuns; i++ {
+		randomBytes := make([]byte, *msgSize)
+		n, err := rand.Read(randomBytes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n != *msgSize {
+			log.Fatal("didn't read enough bytes")
+		}
+		msg := string(randomBytes)
+
+		t1 := time.Now()
+		if *useGRPC {
+			_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: msg})
+			proto = "GRPC"
+		} else {
+			var resp *http.Response
+			resp, err = http.Post("https://"+target, "text/plain", bytes.NewReader(randomBytes))
+			proto = "HTTP"
+			if resp != nil {
+				proto = resp.Proto
+				resp.Body.Close()
+			}
+		}
+		d := time.Since(t1)
+		if *verbose {
+			fmt.Println()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i < *warmup {
+			continue
+		}
+		if *raw {
+			fmt.Printf("%v\t%v\t%v\n", d, *latency, proto)
+		}
+		times = append(times, d)
 	}
-	l, err := net.Listen("tcp", *addr)
-	if err != nil {
-		log.Fatal(err)
+	if !*raw {
+		printSummary(proto, times)
 	}
-	rate := Rate{Latency: *latency}
-	l = &Listener{l, rate, rate}
-	close(ready)
+}
+
+// serve runs the server on l.
+func serve(l net.Listener) {
 	if *useGRPC {
+		server := grpc.NewServer()
+		helloworld.RegisterGreeterServer(server, greeter{})
 		log.Fatal(server.Serve(l))
 	} else {
 		var config tls.Config
@@ -141,7 +217,7 @@ func validate(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Fatalf("validate: %v", err)
 	}
-	if len(b) != *msgSize {
+	if checkSize && len(b) != *msgSize {
 		log.Fatalf("validate: got %d bytes, want %d", len(b), *msgSize)
 	}
 }
@@ -150,7 +226,7 @@ type greeter struct {
 }
 
 func (s greeter) SayHello(ctx context.Context, req *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
-	if len(req.Name) != *msgSize {
+	if checkSize && len(req.Name) != *msgSize {
 		log.Fatalf("greeter: got %d bytes, want %d", len(req.Name), *msgSize)
 	}
 	return &helloworld.HelloReply{}, nil
@@ -2,7 +2,7 @@
 
 set -e
 
-echo "Duration	Latency	Proto"
+echo "Proto	Latency	N	Mean	Median	P99 (95% confidence intervals)"
 go build -o grpcbench
 for latency in 0 1 2 4 8 16 32; do
 	for grpc in true false; do
@@ -10,8 +10,7 @@ for latency in 0 1 2 4 8 16 32; do
 			if [[ "$grpc" == "true" && "$http2" == "false" ]]; then
 				continue
 			fi
-			echo
-			./grpcbench -latency=${latency}ms -grpc=$grpc -http2=$http2 -n 5
+			./grpcbench -latency=${latency}ms -grpc=$grpc -http2=$http2 -warmup 2 -n 20
 		done
 	done
 done
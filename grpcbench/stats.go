@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// resamples is the number of bootstrap resamples used
+// to compute confidence intervals.
+const resamples = 10000
+
+// printSummary prints a tab-separated line summarizing the call durations
+// in times, matching the header printed by run.sh: the protocol, latency,
+// and number of calls, followed by the mean, median, and 99th percentile,
+// each with a 95% confidence interval computed by bootstrap resampling.
+// With few samples, the 99th percentile is simply the maximum,
+// and its confidence interval is correspondingly unreliable.
+func printSummary(proto string, times []time.Duration) {
+	if len(times) == 0 {
+		return
+	}
+	fmt.Printf("%v\t%v\t%d", proto, *latency, len(times))
+	for _, stat := range []func([]time.Duration) time.Duration{
+		mean,
+		percentile(50),
+		percentile(99),
+	} {
+		lo, hi := bootstrap(times, stat)
+		fmt.Printf("\t%v [%v, %v]", round(stat(times)), round(lo), round(hi))
+	}
+	fmt.Printf("\n")
+}
+
+// round rounds d to a precision suitable for printing.
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+func mean(times []time.Duration) time.Duration {
+	var total time.Duration
+	for _, t := range times {
+		total += t
+	}
+	return total / time.Duration(len(times))
+}
+
+// percentile returns a function computing the p'th percentile
+// of a list of durations, using the nearest-rank method.
+func percentile(p int) func([]time.Duration) time.Duration {
+	return func(times []time.Duration) time.Duration {
+		sorted := slices.Clone(times)
+		slices.Sort(sorted)
+		i := (p*len(sorted)+99)/100 - 1
+		return sorted[max(i, 0)]
+	}
+}
+
+// bootstrap returns a 95% confidence interval for stat(times),
+// computed using the bootstrap percentile method.
+// It uses a fixed random seed, so that results are reproducible.
+func bootstrap(times []time.Duration, stat func([]time.Duration) time.Duration) (lo, hi time.Duration) {
+	r := rand.New(rand.NewSource(1))
+	sample := make([]time.Duration, len(times))
+	stats := make([]time.Duration, resamples)
+	for i := range stats {
+		for j := range sample {
+			sample[j] = times[r.Intn(len(times))]
+		}
+		stats[i] = stat(sample)
+	}
+	slices.Sort(stats)
+	return stats[resamples*25/1000], stats[resamples*975/1000-1]
+}
@@ -93,8 +93,31 @@ func node2md(ctxt string, n *html.Node) (block, error) {
 		case "pre":
 			c := n.FirstChild
 			if c != nil && c.NextSibling == nil && c.Type == html.TextNode {
+				if lang := language(n); lang != "" {
+					return fenced{lang, c.Data}, nil
+				}
 				return pre(c.Data), nil
 			}
+			// <pre><code class="language-go">...</code></pre>
+			if c != nil && c.NextSibling == nil && c.Type == html.ElementNode && c.Data == "code" {
+				t := c.FirstChild
+				if t == nil || t.NextSibling == nil && t.Type == html.TextNode {
+					data := ""
+					if t != nil {
+						data = t.Data
+					}
+					lang := language(c)
+					if lang == "" {
+						lang = language(n)
+					}
+					if lang == "" && !extraAttr(c) {
+						return pre(data), nil
+					}
+					if lang != "" {
+						return fenced{lang, data}, nil
+					}
+				}
+			}
 			return tagBlock(printHTML(n)), nil
 
 		case "textarea", "select":
@@ -129,26 +152,8 @@ func node2md(ctxt string, n *html.Node) (block, error) {
 
 		case "dl":
 			var l defns
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.TextNode && strings.TrimSpace(c.Data) == "" {
-					continue
-				}
-				if c.Type != html.ElementNode || c.Data != "dt" && c.Data != "dd" || c.Data == "dd" && len(l) == 0 {
-					return nil, fmt.Errorf("%s: unexpected %d %q", ctxt, c.Type, c.Data)
-				}
-				if c.Data == "dt" {
-					inner, err := inline2md(ctxt+">dt", c)
-					if err != nil {
-						return nil, err
-					}
-					l = append(l, defn{dt: inner})
-					continue
-				}
-				inner, err := block2md(ctxt+">dd", c)
-				if err != nil {
-					return nil, err
-				}
-				l[len(l)-1].dd = append(l[len(l)-1].dd, inner...)
+			if err := dl2md(ctxt, n, &l); err != nil {
+				return nil, err
 			}
 			return l, nil
 
@@ -164,11 +169,179 @@ func node2md(ctxt string, n *html.Node) (block, error) {
 			return b, nil
 
 		case "table":
+			if t, ok := table2md(ctxt, n); ok {
+				return t, nil
+			}
 			return tagBlock(noBlankLines(printHTML(n))), nil
 		}
 	}
 }
 
+// dl2md appends to l the terms and definitions in the <dl> n,
+// including those grouped in <div> elements.
+// Each <dt> starts a new entry, and each <dd> is added to the
+// latest entry or, if that entry already has a definition,
+// starts a new entry with no term.
+func dl2md(ctxt string, n *html.Node, l *defns) error {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode && strings.TrimSpace(c.Data) == "" {
+			continue
+		}
+		if c.Type == html.ElementNode && c.Data == "div" && len(c.Attr) == 0 {
+			if err := dl2md(ctxt+">div", c, l); err != nil {
+				return err
+			}
+			continue
+		}
+		if c.Type != html.ElementNode || c.Data != "dt" && c.Data != "dd" || c.Data == "dd" && len(*l) == 0 {
+			return fmt.Errorf("%s: unexpected %d %q", ctxt, c.Type, c.Data)
+		}
+		if c.Data == "dt" {
+			inner, err := inline2md(ctxt+">dt", c)
+			if err != nil {
+				return err
+			}
+			*l = append(*l, defn{dt: inner})
+			continue
+		}
+		inner, err := block2md(ctxt+">dd", c)
+		if err != nil {
+			return err
+		}
+		if last := &(*l)[len(*l)-1]; last.dd == nil {
+			last.dd = inner
+		} else {
+			*l = append(*l, defn{dd: inner})
+		}
+	}
+	return nil
+}
+
+// language returns the language named by a class="language-x"
+// or class="lang-x" attribute on n, or else the empty string.
+func language(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		if lang, ok := strings.CutPrefix(class, "language-"); ok {
+			return lang
+		}
+		if lang, ok := strings.CutPrefix(class, "lang-"); ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+// table2md converts the <table> n to a pipe table.
+// It reports false if the table cannot be written as a pipe table:
+// the table must have a header row of <th> cells, possibly in a <thead>,
+// every row must have the same number of cells, and the cells must
+// contain only inline content that fits on a single line,
+// with no row or column spans.
+func table2md(ctxt string, n *html.Node) (table, bool) {
+	if extraAttr(n) {
+		return table{}, false
+	}
+	var rows []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode && strings.TrimSpace(c.Data) == "":
+			// ignore
+		case c.Type == html.ElementNode && (c.Data == "thead" || c.Data == "tbody") && len(c.Attr) == 0:
+			for r := c.FirstChild; r != nil; r = r.NextSibling {
+				switch {
+				case r.Type == html.TextNode && strings.TrimSpace(r.Data) == "":
+					// ignore
+				case r.Type == html.ElementNode && r.Data == "tr" && len(r.Attr) == 0:
+					rows = append(rows, r)
+				default:
+					return table{}, false
+				}
+			}
+		case c.Type == html.ElementNode && c.Data == "tr" && len(c.Attr) == 0:
+			rows = append(rows, c)
+		default:
+			return table{}, false // caption, tfoot, comment, ...
+		}
+	}
+	if len(rows) < 1 {
+		return table{}, false
+	}
+
+	var t table
+	for i, r := range rows {
+		var cells []string
+		for c := r.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode && strings.TrimSpace(c.Data) == "" {
+				continue
+			}
+			want := "td"
+			if i == 0 {
+				want = "th"
+			}
+			if c.Type != html.ElementNode || c.Data != want || extraAttr(c, "align", "style") {
+				return table{}, false
+			}
+			align, ok := cellAlign(c)
+			if !ok {
+				return table{}, false
+			}
+			if i == 0 {
+				t.align = append(t.align, align)
+			} else if len(cells) >= len(t.align) || align != "" && align != t.align[len(cells)] {
+				return table{}, false
+			}
+			inner, err := inline2md(ctxt+">"+c.Data, c)
+			if err != nil {
+				return table{}, false
+			}
+			cell, ok := cellText(inner)
+			if !ok {
+				return table{}, false
+			}
+			cells = append(cells, cell)
+		}
+		if len(cells) == 0 || i > 0 && len(cells) != len(t.rows[0]) {
+			return table{}, false
+		}
+		t.rows = append(t.rows, cells)
+	}
+	return t, true
+}
+
+// cellAlign returns the alignment of the table cell n,
+// "left", "center", "right", or "" for the default,
+// as set by an align attribute or a text-align style.
+// It reports false if n has other styles.
+func cellAlign(n *html.Node) (string, bool) {
+	align := attr(n, "align")
+	if style := attr(n, "style"); style != "" {
+		k, v, ok := strings.Cut(strings.TrimSuffix(strings.TrimSpace(style), ";"), ":")
+		if !ok || strings.TrimSpace(k) != "text-align" {
+			return "", false
+		}
+		align = strings.TrimSpace(v)
+	}
+	switch align {
+	case "", "left", "center", "right":
+		return align, true
+	}
+	return "", false
+}
+
+// cellText returns the Markdown for a table cell containing inner.
+// It reports false if inner cannot be written on a single line.
+func cellText(inner inlines) (string, bool) {
+	for _, inl := range inner {
+		if _, ok := inl.(hardBreak); ok {
+			return "", false
+		}
+	}
+	var p printer
+	inner.printInline(&p)
+	s := strings.Join(strings.Fields(p.buf.String()), " ")
+	return strings.ReplaceAll(s, "|", `\|`), true
+}
+
 func set(s string) map[string]bool {
 	m := make(map[string]bool)
 	for _, k := range strings.Fields(s) {
@@ -104,6 +104,52 @@ var htmlTests = []struct {
 		and
 		<code><small>XTAHU</small></code>.
 	`},
+	{`
+		<table>
+		<thead><tr><th>Name</th><th align="right">Size</th><th style="text-align: center">Kind</th></tr></thead>
+		<tbody>
+		<tr><td><code>a|b</code></td><td>10</td><td><i>x</i></td></tr>
+		<tr><td>long name</td><td>1234</td><td>y</td></tr>
+		</tbody>
+		</table>
+	`, `
+		| Name      | Size | Kind  |
+		| --------- | ---: | :---: |
+		| ` + "`a\\|b`" + `    |   10 | _x_   |
+		| long name | 1234 | y     |
+	`},
+	{`
+		<table><tr><td colspan="2">x</td></tr></table>
+	`, `
+		<table><tbody><tr><td colspan="2">x</td></tr></tbody></table>
+	`},
+	{`
+		<dl>
+		<dt>a</dt><dt>b</dt><dd>alpha</dd><dd>beta</dd>
+		<div><dt>c</dt><dd>charlie</dd></div>
+		</dl>
+	`, `
+		a
+		b
+		:   alpha
+		:   beta
+
+		c
+		:   charlie
+	`},
+	{`
+		<pre><code class="language-go">package main
+		</code></pre>
+	`, "```go\npackage main\n```\n",
+	},
+	{`
+		<pre class="lang-sh">echo ` + "```" + `</pre>
+	`, "````sh\necho ```\n````\n",
+	},
+	{`
+		<pre><code>plain</code></pre>
+	`, "\tplain\n",
+	},
 }
 
 func TestHTML(t *testing.T) {
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 type block interface {
@@ -75,8 +76,93 @@ func (x pre) printBlock(p *printer) {
 	}
 }
 
+type fenced struct {
+	info string
+	text string
+}
+
+func (x fenced) printBlock(p *printer) {
+	s := strings.Trim(x.text, "\n")
+	fence := "```"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	p.buf.Write(p.prefix)
+	p.buf.WriteString(fence + x.info + "\n")
+	if s != "" {
+		for _, line := range strings.Split(s, "\n") {
+			line = strings.TrimRight(line, " \t")
+			if line != "" {
+				p.buf.Write(p.prefix)
+				p.buf.WriteString(line)
+			}
+			p.printNL(true)
+		}
+	}
+	p.buf.Write(p.prefix)
+	p.buf.WriteString(fence + "\n")
+}
+
+// A table is a pipe table. The first row is the header.
+type table struct {
+	align []string // "left", "center", "right", or "" for each column
+	rows  [][]string
+}
+
+func (x table) printBlock(p *printer) {
+	width := make([]int, len(x.align))
+	for i, a := range x.align {
+		width[i] = 3
+		if a == "center" {
+			width[i] = 5
+		}
+	}
+	for _, row := range x.rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > width[i] {
+				width[i] = n
+			}
+		}
+	}
+	printRow := func(row []string) {
+		p.buf.Write(p.prefix)
+		p.buf.WriteString("|")
+		for i, cell := range row {
+			pad := strings.Repeat(" ", width[i]-utf8.RuneCountInString(cell))
+			if x.align[i] == "right" {
+				cell = pad + cell
+			} else {
+				cell += pad
+			}
+			p.buf.WriteString(" " + cell + " |")
+		}
+		p.buf.WriteString("\n")
+	}
+	printRow(x.rows[0])
+	rule := make([]string, len(x.align))
+	for i, a := range x.align {
+		r := strings.Repeat("-", width[i])
+		switch a {
+		case "left":
+			r = ":" + r[1:]
+		case "center":
+			r = ":" + r[2:] + ":"
+		case "right":
+			r = r[1:] + ":"
+		}
+		rule[i] = r
+	}
+	printRow(rule)
+	for _, row := range x.rows[1:] {
+		printRow(row)
+	}
+}
+
 type defns []defn
 
+// A defn is an entry in a definition list.
+// Consecutive terms share the definition that follows them,
+// and a definition with no term adds to the definitions before it.
 type defn struct {
 	dt inlines
 	dd blocks
@@ -84,16 +170,20 @@ type defn struct {
 
 func (x defns) printBlock(p *printer) {
 	for i, d := range x {
-		if i > 0 {
+		if i > 0 && d.dt != nil && x[i-1].dd != nil {
 			p.printNL(true)
 		}
-		d.dt.printInline(p)
-		p.printNL(false)
-		p.buf.WriteString(":   ")
-		old := len(p.prefix)
-		p.prefix = append(p.prefix, "    "...)
-		d.dd.printBlock(p)
-		p.prefix = p.prefix[:old]
+		if d.dt != nil {
+			d.dt.printInline(p)
+			p.printNL(false)
+		}
+		if d.dd != nil {
+			p.buf.WriteString(":   ")
+			old := len(p.prefix)
+			p.prefix = append(p.prefix, "    "...)
+			d.dd.printBlock(p)
+			p.prefix = p.prefix[:old]
+		}
 	}
 }
 
@@ -0,0 +1,117 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// cacheExpire is how long an unused cache entry is kept.
+const cacheExpire = 30 * 24 * time.Hour
+
+// A blockCache maps the hash of an Ivy code block's input,
+// along with everything executed before it, to the block's updated text.
+type blockCache struct {
+	file    string
+	entries map[string]*cacheEntry
+	dirty   bool
+}
+
+// A cacheEntry is a single cached code block result.
+type cacheEntry struct {
+	Text string    // updated block text, including -- out -- and -- err -- sections
+	Used time.Time // last time the entry was used
+}
+
+// openCache returns the cache stored in the user's cache directory.
+// If the cache cannot be read, openCache returns an empty cache,
+// which is only saved if the cache directory exists or can be created.
+func openCache() *blockCache {
+	c := &blockCache{entries: make(map[string]*cacheEntry)}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return c
+	}
+	c.file = filepath.Join(dir, "ivymark", "cache.json")
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		// Corrupt cache; start over.
+		c.entries = make(map[string]*cacheEntry)
+	}
+	return c
+}
+
+// lookup returns the cached text for key.
+func (c *blockCache) lookup(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	e := c.entries[key]
+	if e == nil {
+		return "", false
+	}
+	e.Used = time.Now()
+	c.dirty = true
+	return e.Text, true
+}
+
+// store records text as the cached text for key.
+func (c *blockCache) store(key, text string) {
+	if c == nil {
+		return
+	}
+	c.entries[key] = &cacheEntry{Text: text, Used: time.Now()}
+	c.dirty = true
+}
+
+// save writes the cache back to its file, dropping expired entries.
+func (c *blockCache) save() error {
+	if c == nil || !c.dirty || c.file == "" {
+		return nil
+	}
+	for key, e := range c.entries {
+		if time.Since(e.Used) > cacheExpire {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0777); err != nil {
+		return err
+	}
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+// ivyVersion returns the version of Ivy linked into this program,
+// which is part of every cache key, so that upgrading Ivy
+// invalidates the cached results.
+func ivyVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, m := range info.Deps {
+		if m.Path == "robpike.io/ivy" {
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			return m.Path + "@" + m.Version + " " + m.Sum
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"robpike.io/ivy/config"
+)
+
+// A setting is an Ivy configuration setting from a document's front matter.
+type setting struct {
+	line  int // line number in the document
+	key   string
+	value string
+}
+
+// frontMatter splits data into its YAML front matter, including the
+// --- delimiter lines, and the rest of the document.
+// If data does not begin with front matter, front is empty.
+func frontMatter(data []byte) (front, body []byte) {
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		rest, ok = bytes.CutPrefix(data, []byte("---\r\n"))
+	}
+	if !ok {
+		return nil, data
+	}
+	for len(rest) > 0 {
+		line, next, _ := bytes.Cut(rest, []byte("\n"))
+		rest = next
+		if s := strings.TrimRight(string(line), " \t\r"); s == "---" || s == "..." {
+			n := len(data) - len(rest)
+			return data[:n], data[n:]
+		}
+	}
+	return nil, data // unterminated: not front matter
+}
+
+// parseSettings returns the Ivy settings in the front matter front.
+// Front matter is YAML, but ivymark only understands top-level
+// key: value lines with scalar values. It ignores keys it does not use,
+// along with nested values, lists, and comments, so that documents can
+// keep metadata for other tools in the same front matter.
+func parseSettings(front []byte) ([]setting, error) {
+	var settings []setting
+	lines := strings.Split(strings.TrimSuffix(string(front), "\n"), "\n")
+	for i, line := range lines {
+		if i == 0 || i == len(lines)-1 {
+			continue // --- delimiters
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || line[0] == '-' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		key = strings.TrimSpace(key)
+		if !configKeys[key] {
+			continue
+		}
+		value, err := yamlScalar(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", i+1, key, err)
+		}
+		if _, err := settingNum(key, value); err != nil {
+			return nil, fmt.Errorf("line %d: invalid %s %q", i+1, key, value)
+		}
+		settings = append(settings, setting{i + 1, key, value})
+	}
+	return settings, nil
+}
+
+// yamlScalar returns the value of the YAML scalar s,
+// which may be quoted and may be followed by a comment.
+func yamlScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return "", fmt.Errorf("unterminated quoted string")
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", s[:end+1])
+		}
+		return v, checkComment(s[end+1:])
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				return b.String(), checkComment(s[i+1:])
+			}
+			b.WriteByte(s[i])
+		}
+		return "", fmt.Errorf("unterminated quoted string")
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
+
+// checkComment checks that s, the text after a quoted string, is empty or a comment.
+func checkComment(s string) error {
+	s = strings.TrimSpace(s)
+	if s != "" && !strings.HasPrefix(s, "#") {
+		return fmt.Errorf("unexpected text %q after quoted string", s)
+	}
+	return nil
+}
+
+// configKeys is the set of front matter keys that configure Ivy.
+var configKeys = map[string]bool{
+	"format":    true,
+	"maxbits":   true,
+	"maxdigits": true,
+	"maxstack":  true,
+	"origin":    true,
+	"prompt":    true,
+}
+
+// settingNum returns the numeric value for the setting key,
+// or 0 for a setting that is not numeric.
+func settingNum(key, value string) (int64, error) {
+	switch key {
+	case "maxbits", "maxdigits", "maxstack":
+		n, err := strconv.ParseUint(value, 10, 31)
+		return int64(n), err
+	case "origin":
+		return strconv.ParseInt(value, 10, 32)
+	}
+	return 0, nil
+}
+
+// configure applies settings, which parseSettings has checked, to conf.
+func configure(conf *config.Config, settings []setting) {
+	for _, s := range settings {
+		n, _ := settingNum(s.key, s.value)
+		switch s.key {
+		case "format":
+			conf.SetFormat(s.value)
+		case "maxbits":
+			conf.SetMaxBits(uint(n))
+		case "maxdigits":
+			conf.SetMaxDigits(uint(n))
+		case "maxstack":
+			conf.SetMaxStack(uint(n))
+		case "origin":
+			conf.SetOrigin(int(n))
+		case "prompt":
+			conf.SetPrompt(s.value)
+		}
+	}
+}
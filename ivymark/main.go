@@ -6,23 +6,63 @@
 //
 // Usage:
 //
-//	ivymark [-w] [file...]
+//	ivymark [-html] [-nocache] [-w] [file...]
 //
 // Ivymark reads the named files, or else standard input, as Markdown documents,
 // executes any Ivy code blocks and updates them to contain the results,
 // and then reprints the Markdown documents to standard output .
 //
 // The -w flag specifies to rewrite the files in place.
+//
+// A document can load Ivy definitions shared with other documents
+// using an include directive, an HTML comment on a line by itself:
+//
+//	<!-- ivymark: include prelude.ivy -->
+//
+// Before executing any code blocks, ivymark runs each file named
+// by an include directive, in the order the directives appear.
+// The file names are relative to the directory containing the document.
+// Output from included files is discarded, but errors are reported.
+//
+// A document can configure Ivy with YAML front matter, a block of
+// key: value lines at the very top of the file, between --- lines:
+//
+//	---
+//	origin: 0
+//	format: "%.6f"
+//	maxbits: 10000
+//	---
+//
+// The recognized keys are format, maxbits, maxdigits, maxstack, origin,
+// and prompt, which set the Ivy configuration value of the same name
+// before ivymark runs the included files and code blocks. Other keys,
+// such as a title for a static site generator, are ignored.
+// The defaults are origin 1, maxbits 1000000, maxdigits 10000,
+// maxstack 100000, and an empty format and prompt.
+// Ivymark keeps the front matter in Markdown output
+// and omits it from HTML output.
+//
+// Ivymark caches the results of code blocks in the user's cache directory
+// (ivymark/cache.json), to avoid reevaluating unchanged blocks when a long
+// document is updated repeatedly. The result of a block is reused only
+// when the block, every block before it in the document, the included files,
+// the front matter settings, and the Ivy version are all unchanged,
+// so editing a block invalidates the results of the blocks that follow it.
+// Evaluation that depends on anything else, such as random numbers
+// or the current time, sees stale results from the cache.
+// The -nocache flag disables the cache.
 package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"flag"
 	"fmt"
 	"io"
 	"iter"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"robpike.io/ivy/config"
@@ -36,11 +76,13 @@ import (
 var (
 	htmlflag = flag.Bool("html", false, "write HTML output")
 	wflag    = flag.Bool("w", false, "write output back to input files")
+	nocache  = flag.Bool("nocache", false, "do not use cached results")
 	exit     = 0
+	cache    *blockCache
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: ivymark [-html] [-w] [file...]\n")
+	fmt.Fprintf(os.Stderr, "usage: ivymark [-html] [-nocache] [-w] [file...]\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -51,6 +93,9 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if !*nocache {
+		cache = openCache()
+	}
 	if flag.NArg() == 0 {
 		data, err := io.ReadAll(os.Stdin)
 		if err != nil {
@@ -70,19 +115,34 @@ func main() {
 			convert(data, file)
 		}
 	}
+	if err := cache.save(); err != nil {
+		log.Printf("saving cache: %v", err)
+	}
 	os.Exit(exit)
 }
 
 func convert(data []byte, file string) {
+	name := file
+	if name == "" {
+		name = "standard input"
+	}
+	front, body := frontMatter(data)
+	settings, err := parseSettings(front)
+	if err != nil {
+		log.Printf("%s: front matter: %v", name, err)
+		exit = 1
+		return
+	}
+
 	var p markdown.Parser
 	p.Table = true
-	doc := p.Parse(string(data))
-	update(doc)
+	doc := p.Parse(string(body))
+	update(doc, file, settings)
 	var out []byte
 	if *htmlflag {
 		out = []byte(markdown.ToHTML(doc))
 	} else {
-		out = []byte(markdown.Format(doc))
+		out = []byte(string(front) + markdown.Format(doc))
 	}
 	if *wflag && file != "" {
 		if err := os.WriteFile(file, out, 0666); err != nil {
@@ -95,7 +155,10 @@ func convert(data []byte, file string) {
 	}
 }
 
-func update(doc *markdown.Document) {
+// update executes the Ivy code blocks in doc, which was read from file,
+// after applying the front matter settings and running any included files.
+// It reuses cached results for blocks whose input and history are unchanged.
+func update(doc *markdown.Document, file string, settings []setting) {
 	var conf config.Config
 	var outBuf, errBuf bytes.Buffer
 	conf.SetFormat("")
@@ -106,26 +169,76 @@ func update(doc *markdown.Document) {
 	conf.SetPrompt("")
 	conf.SetOutput(&outBuf)
 	conf.SetErrOutput(&errBuf)
+	configure(&conf, settings)
 
 	context := exec.NewContext(&conf)
 
+	// eval runs the Ivy code in text, read from the named file,
+	// returning its standard output and error output.
+	eval := func(name, text string) (stdout, stderr string) {
+		scanner := scan.New(context, name, strings.NewReader(text))
+		parser := parse.NewParser(name, scanner, context)
+		outBuf.Reset()
+		errBuf.Reset()
+		run.Run(parser, context, false)
+		return addNL(outBuf.String()), addNL(errBuf.String())
+	}
+
+	// key is a running hash of everything that affects
+	// the evaluation of the next code block.
+	key := sha256.New()
+	fmt.Fprintf(key, "ivymark cache 1\n%s\n", ivyVersion())
+	for _, s := range settings {
+		fmt.Fprintf(key, "set %s %q\n", s.key, s.value)
+	}
+
+	name := file
+	if name == "" {
+		name = "standard input"
+	}
+	for _, inc := range includes(doc) {
+		path := filepath.Join(filepath.Dir(file), inc)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			exit = 1
+			continue
+		}
+		fmt.Fprintf(key, "include %q %d\n%s", inc, len(data), data)
+		if _, err := eval(path, addNL(string(data))); err != "" {
+			log.Printf("%s: include %s:\n%s", name, inc, err)
+			exit = 1
+		}
+	}
+
+	// pending holds the cached blocks that have not been evaluated.
+	// They must be evaluated before any later block that is not cached,
+	// to establish the state that block expects.
+	var pending []string
+
 	for code := range codeBlocks(doc) {
 		text := strings.Join(code.Text, "\n")
 		text, _, _ = strings.Cut(text, "\n-- err --\n")
 		text, _, _ = strings.Cut(text, "\n-- out --\n")
 		text = addNL(text)
-		if text != "" {
-			scanner := scan.New(context, "input", strings.NewReader(text))
-			parser := parse.NewParser("input", scanner, context)
-			outBuf.Reset()
-			errBuf.Reset()
-			run.Run(parser, context, false)
-			if out := addNL(outBuf.String()); out != "" {
+		fmt.Fprintf(key, "block %d\n%s", len(text), text)
+		k := fmt.Sprintf("%x", key.Sum(nil))
+		if cached, ok := cache.lookup(k); ok {
+			pending = append(pending, text)
+			text = cached
+		} else if text != "" {
+			for _, p := range pending {
+				eval("input", p)
+			}
+			pending = nil
+			out, err := eval("input", text)
+			if out != "" {
 				text += "-- out --\n" + out
 			}
-			if err := addNL(errBuf.String()); err != "" {
+			if err != "" {
 				text += "-- err --\n" + err
 			}
+			cache.store(k, text)
 		}
 		lines := strings.Split(text, "\n")
 		lines = lines[:len(lines)-1] // remove empty line after last \n
@@ -133,6 +246,33 @@ func update(doc *markdown.Document) {
 	}
 }
 
+// includes returns the file names listed in include directives in doc.
+func includes(doc *markdown.Document) []string {
+	var files []string
+	for _, b := range doc.Blocks {
+		html, ok := b.(*markdown.HTMLBlock)
+		if !ok {
+			continue
+		}
+		for _, line := range html.Text {
+			line = strings.TrimSpace(line)
+			line, ok := strings.CutPrefix(line, "<!--")
+			if !ok {
+				continue
+			}
+			line, ok = strings.CutSuffix(line, "-->")
+			if !ok {
+				continue
+			}
+			f := strings.Fields(line)
+			if len(f) == 3 && f[0] == "ivymark:" && f[1] == "include" {
+				files = append(files, f[2])
+			}
+		}
+	}
+	return files
+}
+
 func addNL(s string) string {
 	s = strings.TrimRight(s, "\n")
 	if s == "" {
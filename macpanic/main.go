@@ -6,13 +6,28 @@
 //
 // Usage:
 //
-//	macpanic [-k kernel] [file...]
+//	macpanic [-k kernel | -kdk dir] [file...]
 //
 // Macpanic reads each of the named panic logs and summarizes the panic.
 // With no arguments it reads /Library/Logs/DiagnosticReports/Kernel*panic.
 // To add symbol information to the panic summary, macpanic uses symbols
 // from kernel (default /System/Library/Kernels/kernel) and also inspects
-// installed kernel modules.
+// installed kernel modules. Macpanic skips panics from a kernel version
+// other than the one in kernel.
+//
+// To summarize panics from other kernel versions, such as after a
+// system update, macpanic can instead use the symbols from a Kernel Debug Kit
+// (KDK), available from Apple's developer downloads site.
+// The -kdk flag names the KDK directory, such as
+// /Library/Developer/KDKs/KDK_14.2_23C64.kdk. For each panic, macpanic
+// uses the kernel binary from the KDK matching the variant (release or
+// development) and platform recorded in the panic, and it looks for
+// kernel modules in the KDK before the installed ones.
+// If the directory is "auto", macpanic uses the installed KDK
+// in /Library/Developer/KDKs matching the macOS build number
+// recorded in each panic. Macpanic cannot download KDKs itself:
+// when the matching KDK is not installed, it reports the build number
+// to download.
 package main
 
 import (
@@ -24,6 +39,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,19 +49,36 @@ import (
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: macpanic [-k kernel] [file...]\n")
+	fmt.Fprintf(os.Stderr, "usage: macpanic [-k kernel | -kdk dir] [file...]\n")
 	os.Exit(2)
 }
 
-var kernel = flag.String("k", "/System/Library/Kernels/kernel", "kernel binary")
-var version string
+var (
+	kernelFlag = flag.String("k", "/System/Library/Kernels/kernel", "kernel binary")
+	kdkFlag    = flag.String("kdk", "", "use symbols from Kernel Debug Kit `dir` (or auto)")
+)
+
+// kdkRoot is the directory where Kernel Debug Kits are installed.
+const kdkRoot = "/Library/Developer/KDKs"
 
 type sym struct {
 	addr uint64
 	name string
 }
 
-var syms []sym
+// A kernel is a kernel binary and its symbols.
+type kernel struct {
+	file    string
+	version string
+	syms    []sym
+	extDirs []string // directories holding kernel extensions
+}
+
+// kernels caches loaded kernels, keyed by file name.
+var kernels = make(map[string]*kernel)
+
+// installedExts lists the directories holding installed kernel extensions.
+var installedExts = []string{"/System/Library/Extensions", "/Library/Extensions"}
 
 func main() {
 	log.SetPrefix("macpanic: ")
@@ -53,40 +86,150 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
-	data, err := ioutil.ReadFile(*kernel)
+	kflag := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "k" {
+			kflag = true
+		}
+	})
+	if kflag && *kdkFlag != "" {
+		usage()
+	}
+	if *kdkFlag == "" {
+		if _, err := loadKernel(*kernelFlag, installedExts); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		list, err := filepath.Glob("/Library/Logs/DiagnosticReports/Kernel*panic")
+		if err != nil {
+			log.Fatal(err)
+		}
+		args = list
+	}
+	for _, arg := range args {
+		process(arg)
+	}
+}
+
+// loadKernel returns the kernel for file,
+// whose extensions are found in extDirs.
+func loadKernel(file string, extDirs []string) (*kernel, error) {
+	if k := kernels[file]; k != nil {
+		return k, nil
+	}
+	version, err := kernelVersion(file)
+	if err != nil {
+		return nil, err
+	}
+	syms, err := nm(file)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("kernel %s: %s\n", file, version)
+	k := &kernel{file, version, syms, extDirs}
+	kernels[file] = k
+	return k, nil
+}
+
+// kernelVersion returns the Darwin kernel version string in the kernel binary file.
+func kernelVersion(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	i := bytes.Index(data, []byte("Darwin Kernel Version"))
 	if i < 0 {
-		log.Fatalf("cannot find 'Darwin Kernel Version' in kernel")
+		return "", fmt.Errorf("%s: cannot find 'Darwin Kernel Version' in kernel", file)
 	}
 	data = data[i:]
 	i = bytes.IndexByte(data, 0)
 	if i < 0 || !utf8.Valid(data[:i]) {
-		log.Fatalf("found malformed 'Darwin Kernel Version' in kernel")
+		return "", fmt.Errorf("%s: found malformed 'Darwin Kernel Version' in kernel", file)
 	}
-	version = string(data[:i])
-	fmt.Printf("kernel %s: %s\n", *kernel, version)
+	return string(data[:i]), nil
+}
 
-	syms, err = nm(*kernel)
-	if err != nil {
-		log.Fatal(err)
+// findKernel returns the kernel to use for a panic from the given kernel version
+// and macOS build number.
+func findKernel(version, build string) (*kernel, error) {
+	if *kdkFlag == "" {
+		k := kernels[*kernelFlag]
+		if k.version != version {
+			return nil, fmt.Errorf("mismatched kernel version %q != %q", version, k.version)
+		}
+		return k, nil
 	}
 
-	args := flag.Args()
-	if len(args) == 0 {
-		list, err := filepath.Glob("/Library/Logs/DiagnosticReports/Kernel*panic")
-		if err != nil {
-			log.Fatal(err)
+	dir := *kdkFlag
+	if dir == "auto" {
+		if build == "" {
+			return nil, fmt.Errorf("cannot find macOS build number")
 		}
-		args = list
+		list, _ := filepath.Glob(filepath.Join(kdkRoot, "KDK_*_"+build+".kdk"))
+		if len(list) == 0 {
+			return nil, fmt.Errorf("no KDK for build %s in %s; download Kernel Debug Kit for build %s from developer.apple.com", build, kdkRoot, build)
+		}
+		dir = list[0]
 	}
-	for _, arg := range args {
-		process(arg)
+	extDirs := append([]string{filepath.Join(dir, "System/Library/Extensions")}, installedExts...)
+
+	// Try the name implied by the kernel variant first,
+	// and then any kernel in the KDK with the right version.
+	kdir := filepath.Join(dir, "System/Library/Kernels")
+	var list []string
+	if name := kernelName(version); name != "" {
+		list = append(list, filepath.Join(kdir, name))
 	}
+	all, _ := filepath.Glob(filepath.Join(kdir, "kernel*"))
+	list = append(list, all...)
+	for _, file := range list {
+		if k := kernels[file]; k != nil && k.version == version {
+			return k, nil
+		}
+		if strings.HasSuffix(file, ".dSYM") {
+			continue
+		}
+		v, err := kernelVersion(file)
+		if err == nil && v == version {
+			return loadKernel(file, extDirs)
+		}
+	}
+	return nil, fmt.Errorf("no kernel in %s matches kernel version %q", kdir, version)
+}
+
+// kernelName returns the name of the KDK kernel binary for the given kernel version,
+// or the empty string if the version does not record the kernel variant.
+// For example, the version ending in root:xnu-10002.61.3~2/RELEASE_ARM64_T8103
+// corresponds to kernel.release.t8103, and the version ending in
+// root:xnu-6153.141.1~1/DEVELOPMENT_X86_64 corresponds to kernel.development.
+func kernelName(version string) string {
+	i := strings.LastIndex(version, "/")
+	if i < 0 {
+		return ""
+	}
+	variant, arch, ok := strings.Cut(version[i+1:], "_")
+	if !ok {
+		return ""
+	}
+	variant = strings.ToLower(variant)
+	name := "kernel"
+	if soc, ok := strings.CutPrefix(arch, "ARM64_"); ok {
+		return name + "." + variant + "." + strings.ToLower(soc)
+	}
+	if variant != "release" {
+		name += "." + variant
+	}
+	return name
 }
 
+// buildRE matches the macOS build number in a panic log,
+// in either the older "Mac OS version:" form
+// or the newer "macOS 14.2 (23C64)" form.
+var buildRE = regexp.MustCompile(`(?:Mac OS version:\s*|macOS [0-9.]+ \()([0-9]+[A-Z][0-9]+[a-z]?)`)
+
 func nm(file string) ([]sym, error) {
 	var syms []sym
 	data, err := exec.Command("nm", file).Output()
@@ -172,8 +315,13 @@ func process(file string) {
 	}
 	j += i + len("Kernel version:\n")
 	v := string(data[i+len("Kernel version:\n") : j])
-	if v != version {
-		log.Printf("%s: mismatched kernel version %q != %q", file, v, version)
+	var build string
+	if m := buildRE.FindSubmatch(data); m != nil {
+		build = string(m[1])
+	}
+	k, err := findKernel(v, build)
+	if err != nil {
+		log.Printf("%s: %v", file, err)
 		return
 	}
 
@@ -257,15 +405,19 @@ func process(file string) {
 	for _, t := range trace {
 		var desc string
 		if t[1] < base {
-			desc = translate(t[1], exts, true)
+			desc = translate(t[1], exts, k.extDirs)
 		} else {
-			desc = translate(t[1]-slide, syms, false)
+			desc = translate(t[1]-slide, k.syms, nil)
 		}
 		fmt.Printf("\t%#x : %#x : %s\n", t[0], t[1], desc)
 	}
 }
 
-func translate(pc uint64, syms []sym, exts bool) string {
+// translate returns a description of pc using syms.
+// If extDirs is non-nil, syms lists kernel extensions,
+// and translate also looks for the extension's own symbols
+// in the directories listed in extDirs.
+func translate(pc uint64, syms []sym, extDirs []string) string {
 	i := sort.Search(len(syms), func(i int) bool {
 		return i+1 >= len(syms) || syms[i+1].addr > pc
 	})
@@ -281,18 +433,19 @@ func translate(pc uint64, syms []sym, exts bool) string {
 		name = n
 	}
 	desc := fmt.Sprintf("%s + %#x", name, pc-syms[i].addr)
-	if exts {
+	if extDirs != nil {
 		name := strings.TrimSuffix(syms[i].name, ".kext")
 		elem := name[strings.LastIndex(name, ".")+1:]
-		esyms, err := nm("/System/Library/Extensions/" + elem + ".kext/Contents/MacOS/" + elem)
-		if err != nil {
-			esyms, err = nm("/Library/Extensions/" + elem + ".kext/Contents/MacOS/" + elem)
-		}
-		if err == nil {
-			d := translate(pc-syms[i].addr, esyms, false)
+		for _, dir := range extDirs {
+			esyms, err := nm(dir + "/" + elem + ".kext/Contents/MacOS/" + elem)
+			if err != nil {
+				continue
+			}
+			d := translate(pc-syms[i].addr, esyms, nil)
 			if d != "???" {
 				desc += " (" + d + ")"
 			}
+			break
 		}
 	}
 	return desc
@@ -0,0 +1,3 @@
+module rsc.io/tmp/palm
+
+go 1.21
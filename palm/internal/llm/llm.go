@@ -0,0 +1,173 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package llm implements a small client for JSON-over-HTTP
+// language model APIs, with timeouts and retries.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A Client sends requests to a language model API.
+// The zero Client is usable: it sends each request once, with no timeout.
+type Client struct {
+	// Timeout is the time limit for each attempt at a request.
+	// Zero means no limit.
+	Timeout time.Duration
+
+	// Retries is the maximum number of times to retry a request
+	// that fails with a network error, HTTP status 429 (Too Many Requests),
+	// or an HTTP 5xx server error.
+	Retries int
+
+	// Backoff is the delay before the first retry.
+	// Each later retry waits twice as long as the previous one,
+	// up to MaxBackoff, with random jitter added.
+	// If the server sends a Retry-After header, the client waits that long instead.
+	// Zero means 1 second.
+	Backoff time.Duration
+
+	// MaxBackoff is the maximum delay between retries.
+	// Zero means 30 seconds.
+	MaxBackoff time.Duration
+
+	// Log, if non-nil, is called to report each retry.
+	Log func(format string, args ...any)
+}
+
+// An Error is an HTTP error response from the API.
+type Error struct {
+	Status string
+	Code   int
+	Body   []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:\n%s", e.Status, e.Body)
+}
+
+// temporary reports whether the response code indicates
+// a failure that may succeed if retried.
+func (e *Error) temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// Post sends req, encoded as JSON, to endpoint and decodes the JSON response into resp.
+// It returns the raw response data along with any error.
+// Canceling ctx stops the request, including any retries.
+func (c *Client) Post(ctx context.Context, endpoint string, req, resp any) ([]byte, error) {
+	js, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = 1 * time.Second
+	}
+	maxBackoff := c.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	for try := 0; ; try++ {
+		data, retryAfter, err := c.post(ctx, endpoint, js)
+		if err == nil {
+			if err := json.Unmarshal(data, resp); err != nil {
+				return data, err
+			}
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if e, ok := err.(*Error); ok && !e.temporary() || try >= c.Retries {
+			return data, err
+		}
+
+		wait := retryAfter
+		if wait <= 0 {
+			wait = backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+			backoff = min(2*backoff, maxBackoff)
+		}
+		if c.Log != nil {
+			c.Log("%v; retrying in %v", short(err), wait.Round(time.Millisecond))
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// post makes a single attempt at posting js to endpoint.
+// It returns the response data and, for a failed request,
+// the delay requested by a Retry-After header.
+func (c *Client) post(ctx context.Context, endpoint string, js []byte) (data []byte, retryAfter time.Duration, err error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	hreq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(js))
+	if err != nil {
+		return nil, 0, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hresp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = redact(ue.URL)
+		}
+		return nil, 0, err
+	}
+	data, err = io.ReadAll(hresp.Body)
+	hresp.Body.Close()
+	if hresp.StatusCode != 200 {
+		if secs, err := strconv.Atoi(hresp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return data, retryAfter, &Error{Status: hresp.Status, Code: hresp.StatusCode, Body: data}
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading body: %v", err)
+	}
+	return data, 0, nil
+}
+
+// redact returns rawURL with the values of any key query parameters
+// replaced by REDACTED, so that errors do not reveal API keys.
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	q := u.Query()
+	if q.Has("key") {
+		q.Set("key", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// short returns a one-line form of err for logging retries.
+func short(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Status
+	}
+	return err.Error()
+}
@@ -6,7 +6,7 @@
 //
 // Usage:
 //
-//	palm [-l] [-k keyfile] [prompt...]
+//	palm [-l] [-k keyfile] [-timeout d] [-retries n] [prompt...]
 //
 // Palm concatenates its arguments, sends the result as a prompt
 // to the PaLM model, and prints the response.
@@ -21,21 +21,30 @@
 // The -k flag specifies the name of a file containing the PaLM API key
 // (default $HOME/.palmkey).
 //
+// The -timeout flag sets the time limit for each attempt at a request
+// (default 2m). If a request fails because of a network error, a server
+// error, or rate limiting (HTTP status 429), palm retries it with
+// exponential backoff, up to the number of times given by the -retries flag
+// (default 5). Typing ^C (sending SIGINT) cancels the current request;
+// in line mode, palm then prompts for the next line.
+//
 // [Google's PaLM API]: https://developers.generativeai.google/
 package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"rsc.io/tmp/palm/internal/llm"
 )
 
 var (
@@ -43,10 +52,14 @@ var (
 	key      string
 	lineMode = flag.Bool("l", false, "line at a time mode")
 	keyFile  = flag.String("k", filepath.Join(home, ".palmkey"), "read palm API key from `file`")
+	timeout  = flag.Duration("timeout", 2*time.Minute, "time limit for each request attempt")
+	retries  = flag.Int("retries", 5, "retry failed requests at most `n` times")
 )
 
+var client llm.Client
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: palm [-l] [-k keyfile]\n")
+	fmt.Fprintf(os.Stderr, "usage: palm [-l] [-k keyfile] [-timeout d] [-retries n]\n")
 	os.Exit(2)
 }
 
@@ -61,6 +74,7 @@ func main() {
 		log.Fatal(err)
 	}
 	key = strings.TrimSpace(string(data))
+	client = llm.Client{Timeout: *timeout, Retries: *retries, Log: log.Printf}
 
 	if *lineMode {
 		if flag.NArg() != 0 {
@@ -97,28 +111,19 @@ func do(prompt string) {
 	// -d '{ "prompt": { "text": "Write a story about a magic backpack"} }' \
 	// "https://generativelanguage.googleapis.com/v1beta3/models/text-bison-001:generateText?key=YOUR_API_KEY"
 
-	js, err := json.Marshal(map[string]map[string]string{"prompt": {"text": prompt}})
-	if err != nil {
-		log.Fatal(err)
-	}
-	resp, err := http.Post("https://generativelanguage.googleapis.com/v1beta3/models/text-bison-001:generateText?key="+key, "application/json", bytes.NewReader(js))
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err != nil {
-		log.Fatal(err)
-	}
-	data, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if resp.StatusCode != 200 {
-		log.Fatalf("%s:\n%s", resp.Status, data)
-	}
-	if err != nil {
-		log.Fatalf("reading body: %v", err)
-	}
-
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	req := map[string]map[string]string{"prompt": {"text": prompt}}
 	var r Response
-	if err := json.Unmarshal(data, &r); err != nil {
+	_, err := client.Post(ctx, "https://generativelanguage.googleapis.com/v1beta3/models/text-bison-001:generateText?key="+key, req, &r)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("interrupted")
+		}
+		if *lineMode {
+			log.Print(err)
+			return
+		}
 		log.Fatal(err)
 	}
 	if len(r.Candidates) == 0 {
@@ -0,0 +1,157 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/sstable"
+)
+
+// dbDir is the directory holding the open database's files:
+// the database named on the command line or the private copy made by -ro.
+var dbDir string
+
+// A keySpan is the span of user keys lo ≤ k ≤ hi held by a table.
+type keySpan struct {
+	lo, hi []byte
+}
+
+// check runs pebble's consistency checks on db. It verifies the block
+// checksums of every table, printing a line for each damaged table,
+// and then checks that the keys are correctly ordered within each table
+// and across the levels of the database.
+// If file is not empty and any table is damaged, check salvages the rest
+// of the database: it writes every entry outside the key spans of the
+// damaged tables to file, in the format written by dump.
+func check(db *pebble.DB, file string) error {
+	levels, err := db.SSTables()
+	if err != nil {
+		return err
+	}
+	var (
+		tables int
+		bad    []keySpan
+		seen   = make(map[string]bool)
+	)
+	for level, ts := range levels {
+		for _, t := range ts {
+			if t.BackingType != pebble.BackingTypeLocal {
+				continue
+			}
+			name := t.BackingSSTNum.String() + ".sst"
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			tables++
+			if err := checkTable(filepath.Join(dbDir, name)); err != nil {
+				fmt.Printf("L%d %s: %s ... %s: %v\n", level, name, decode(t.Smallest.UserKey), decode(t.Largest.UserKey), err)
+				bad = append(bad, keySpan{t.Smallest.UserKey, t.Largest.UserKey})
+			}
+		}
+	}
+
+	var stats pebble.CheckLevelsStats
+	levelErr := db.CheckLevels(&stats)
+	if levelErr != nil {
+		fmt.Printf("levels: %v\n", levelErr)
+	}
+	fmt.Printf("checked %d tables: %d entries, %d range deletions; %d damaged\n", tables, stats.NumPoints, stats.NumTombstones, len(bad))
+
+	if file != "" && len(bad) > 0 {
+		if err := salvage(db, file, bad); err != nil {
+			return err
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("found %d damaged tables", len(bad))
+	}
+	return levelErr
+}
+
+// checkTable verifies the block checksums of the sstable in file.
+func checkTable(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	readable, err := sstable.NewSimpleReadable(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r, err := sstable.NewReader(readable, sstable.ReaderOptions{})
+	if err != nil {
+		readable.Close()
+		return err
+	}
+	defer r.Close()
+	return r.ValidateBlockChecksums()
+}
+
+// salvage writes the entries in db with keys outside the spans in bad
+// to file, in the format written by dump, so that they can be restored
+// into a new database. Reading the database around the damaged tables
+// avoids the corrupted blocks, at the cost of losing the entries
+// in other tables that fall within the damaged tables' spans.
+func salvage(db *pebble.DB, file string, bad []keySpan) error {
+	sort.Slice(bad, func(i, j int) bool { return bytes.Compare(bad[i].lo, bad[j].lo) < 0 })
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	n := 0
+	copyRange := func(start, end []byte) error {
+		iter, err := db.NewIter(&pebble.IterOptions{LowerBound: start, UpperBound: end})
+		if err != nil {
+			return err
+		}
+		for iter.First(); iter.Valid(); iter.Next() {
+			if err := enc.Encode(&dumpEntry{iter.Key(), iter.Value()}); err != nil {
+				iter.Close()
+				return err
+			}
+			n++
+		}
+		return iter.Close()
+	}
+
+	var start []byte
+	for _, s := range bad {
+		if start == nil || bytes.Compare(start, s.lo) < 0 {
+			if err := copyRange(start, s.lo); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if next := append(bytes.Clone(s.hi), 0); bytes.Compare(next, start) > 0 {
+			start = next
+		}
+	}
+	if err := copyRange(start, nil); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "salvaged %d entries\n", n)
+	return nil
+}
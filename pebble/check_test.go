@@ -0,0 +1,89 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+)
+
+func TestCheck(t *testing.T) {
+	defer func(dir string) { dbDir = dir }(dbDir)
+	dbDir = t.TempDir()
+	db, err := pebble.Open(dbDir, &pebble.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Write two tables, one with keys a0..a9 and one with b0..b9.
+	for _, prefix := range []string{"a", "b"} {
+		for i := 0; i < 10; i++ {
+			if err := db.Set([]byte(fmt.Sprint(prefix, i)), []byte("value"), noSync); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := check(db, ""); err != nil {
+		t.Fatalf("check of good database: %v", err)
+	}
+
+	// Corrupt the first data block of the table holding the b keys.
+	levels, err := db.SSTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	for _, ts := range levels {
+		for _, t := range ts {
+			if string(t.Smallest.UserKey) == "b0" {
+				name = filepath.Join(dbDir, t.FileNum.String()+".sst")
+			}
+		}
+	}
+	if name == "" {
+		t.Fatal("cannot find table holding b keys")
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[10] ^= 0xFF
+	if err := os.WriteFile(name, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "salvage.jsonl")
+	if err := check(db, file); err == nil {
+		t.Fatalf("check of damaged database succeeded")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var keys []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e dumpEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, string(e.Key))
+	}
+	if len(keys) != 10 || keys[0] != "a0" || keys[9] != "a9" {
+		t.Errorf("salvaged keys %q, want a0 through a9", keys)
+	}
+}
@@ -1,10 +1,11 @@
 module rsc.io/tmp/pebble
 
-go 1.23
+go 1.23.0
 
 require (
 	github.com/cockroachdb/pebble v1.1.0
-	rsc.io/ordered v0.0.0-20240601014500-507e6c97885b
+	golang.org/x/term v0.32.0
+	rsc.io/ordered v1.1.1
 )
 
 require (
@@ -30,7 +31,7 @@ require (
 	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/rogpeppe/go-internal v1.9.0 // indirect
 	golang.org/x/exp v0.0.0-20230626212559-97b1e661b5df // indirect
-	golang.org/x/sys v0.11.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.7.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 )
@@ -0,0 +1,133 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// A command describes a command for help.
+type command struct {
+	name     string
+	usage    string
+	summary  string   // one-line description
+	examples []string // example invocations
+}
+
+// commands lists the commands, in the order help lists them.
+var commands = []command{
+	{"get", "get(key [, end])", "print the value for key, or the entries with key ≤ k ≤ end",
+		[]string{`get("user/1")`, `get(o("user", 1), o("user", 9))`}},
+	{"hex", "hex(key [, end])", "like get, but print values as hexadecimal dumps",
+		[]string{`hex("config")`}},
+	{"list", "list(start, end)", "list the keys k with start ≤ k < end",
+		[]string{`list("", "~")`, `list(o("user"), o("user", Inf))`}},
+	{"count", "count(start, end)", "print the number of keys k with start ≤ k < end",
+		[]string{`count(o("user"), o("user", Inf))`}},
+	{"stats", "stats([prefix])", "summarize entries by the first ordered code value after prefix",
+		[]string{`stats()`, `stats(o("user"))`}},
+	{"search", "search(pattern [, start, end [, limit]])", "print entries whose key or value matches the regexp pattern",
+		[]string{`search("alice")`, `search("@example\\.com$", o("user"), o("user", Inf), 10)`}},
+	{"set", "set(key, value)", "set the value for key",
+		[]string{`set("greeting", "hello")`, `set(o("user", 1), o("alice", 42))`}},
+	{"delete", "delete(key [, end])", "delete key, or the entries with key ≤ k ≤ end",
+		[]string{`delete("greeting")`}},
+	{"mvprefix", "mvprefix(old, new)", "move every entry with a key starting with old to start with new",
+		[]string{`mvprefix("tmp/", "old/")`}},
+	{"dump", "dump(file [, start, end])", "write entries with start ≤ k < end to file as JSON lines",
+		[]string{`dump("db.jsonl")`}},
+	{"restore", "restore(file)", "set every entry in a file written by dump",
+		[]string{`restore("db.jsonl")`}},
+	{"load", "load(file, key, value)", "set an entry for each line of a CSV or TSV file",
+		[]string{`load("users.tsv", o("user", $1), $3)`}},
+	{"begin", "begin()", "start a transaction", nil},
+	{"commit", "commit()", "apply the current transaction", nil},
+	{"rollback", "rollback()", "discard the current transaction", nil},
+	{"snapshot", "snapshot()", "read from a snapshot of the database until release", nil},
+	{"release", "release()", "release the snapshot", nil},
+	{"check", "check([file])", "check the database for corruption, salvaging to file if damaged",
+		[]string{`check()`, `check("salvage.jsonl")`}},
+	{"compact", "compact()", "compact the entire database", nil},
+	{"help", "help([command])", "list the commands, or describe command",
+		[]string{`help`, `help(get)`, `help get`}},
+}
+
+// commandNames returns the names of the commands, sorted, for completion.
+func commandNames() []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// helpLine reports whether line is a help command written
+// without parentheses, as in "help" or "help get",
+// and if so, runs it.
+func helpLine(line string) bool {
+	f := strings.Fields(line)
+	if len(f) == 0 || f[0] != "help" || len(f) > 2 {
+		return false
+	}
+	name := ""
+	if len(f) == 2 {
+		name = f[1]
+	}
+	help(name)
+	return true
+}
+
+// helpCall runs the call form of help, help() or help(command),
+// in which command may be an identifier or a quoted string.
+func helpCall(args []ast.Expr) {
+	if len(args) > 1 {
+		errorf("usage: help([command])\n")
+		return
+	}
+	name := ""
+	if len(args) == 1 {
+		if id, ok := args[0].(*ast.Ident); ok {
+			name = id.Name
+		} else if s, ok := getString(args[0]); ok {
+			name = s
+		} else {
+			return
+		}
+	}
+	help(name)
+}
+
+// help prints the list of commands or, if name is not empty,
+// the usage, description, and examples for the named command.
+func help(name string) {
+	if name == "" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, c := range commands {
+			fmt.Fprintf(w, "%s\t%s\n", c.usage, c.summary)
+		}
+		w.Flush()
+		fmt.Printf("\nKeys and values are quoted strings or o(list) ordered code values,\nsuch as o(\"user\", 42). Use help(command) for examples.\n")
+		return
+	}
+	for _, c := range commands {
+		if c.name == name {
+			fmt.Printf("usage: %s\n\n%s.\n", c.usage, strings.ToUpper(c.summary[:1])+c.summary[1:])
+			if len(c.examples) > 0 {
+				fmt.Printf("\nExamples:\n\n")
+				for _, ex := range c.examples {
+					fmt.Printf("\t%s\n", ex)
+				}
+			}
+			return
+		}
+	}
+	errorf("help: unknown command %s\n", name)
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"unicode/utf8"
+
+	"rsc.io/ordered"
+)
+
+// printJSON prints the result of the command op (get, hex, or list)
+// for the entry key, val as a JSON object on a single line.
+func printJSON(op string, key, val []byte) {
+	m := map[string]any{"key": jsonBytes(key)}
+	switch op {
+	case "get":
+		m["value"] = jsonBytes(val)
+	case "hex":
+		m["hex"] = hex.EncodeToString(val)
+	}
+	js, err := json.Marshal(m)
+	if err != nil {
+		errorf("%v\n", err)
+		return
+	}
+	os.Stdout.Write(append(js, '\n'))
+}
+
+// jsonBytes returns the JSON form of the key or value enc,
+// as described in the package documentation.
+func jsonBytes(enc []byte) any {
+	if list, err := ordered.DecodeAny(enc); err == nil {
+		xs := []any{}
+		for _, x := range list {
+			xs = append(xs, jsonOrdered(x))
+		}
+		return map[string]any{"ordered": xs}
+	}
+	if utf8.Valid(enc) {
+		return map[string]any{"string": string(enc)}
+	}
+	return map[string]any{"bytes": enc}
+}
+
+// jsonOrdered returns the JSON form of a value decoded by ordered.DecodeAny.
+func jsonOrdered(x any) any {
+	switch x := x.(type) {
+	case float32:
+		return map[string]any{"float32": jsonFloat(float64(x))}
+	case float64:
+		return map[string]any{"float64": jsonFloat(x)}
+	case ordered.Infinity:
+		return map[string]any{"inf": true}
+	case ordered.Reverse[string]:
+		return map[string]any{"rev": jsonOrdered(x.Value())}
+	case ordered.Reverse[int64]:
+		return map[string]any{"rev": jsonOrdered(x.Value())}
+	case ordered.Reverse[uint64]:
+		return map[string]any{"rev": jsonOrdered(x.Value())}
+	case ordered.Reverse[float32]:
+		return map[string]any{"rev": jsonOrdered(x.Value())}
+	case ordered.Reverse[float64]:
+		return map[string]any{"rev": jsonOrdered(x.Value())}
+	case ordered.Reverse[ordered.Infinity]:
+		return map[string]any{"rev": jsonOrdered(x.Value())}
+	}
+	return x
+}
+
+// jsonFloat returns f as a JSON number,
+// or as a string if f is not representable in JSON.
+func jsonFloat(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, +1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return f
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// openCopy opens a read-only copy of the database in dir,
+// for use when another process has dir open and locked.
+// It returns the database and the temporary directory holding the copy,
+// which the caller must remove after closing the database.
+//
+// The copy links (or, across file systems, copies) the immutable
+// sstables and copies the manifest, options, and write-ahead log files.
+// The other process may delete an sstable or append to the manifest
+// while the copy is being made, so openCopy tries a few times
+// before giving up.
+func openCopy(dir string) (*pebble.DB, string, error) {
+	var err error
+	for try := 0; try < 5; try++ {
+		var tmp string
+		tmp, err = os.MkdirTemp("", "pebble-ro-")
+		if err != nil {
+			return nil, "", err
+		}
+		if err = copyDB(dir, tmp); err == nil {
+			var db *pebble.DB
+			db, err = pebble.Open(tmp, &pebble.Options{ReadOnly: true})
+			if err == nil {
+				return db, tmp, nil
+			}
+		}
+		os.RemoveAll(tmp)
+	}
+	return nil, "", err
+}
+
+// copyDB copies the database files in dir to the empty directory dst.
+// It copies the manifest before linking the sstables, so that every
+// sstable named in the copied manifest that still exists is linked.
+func copyDB(dir, dst string) error {
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for _, e := range names {
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, ".sst"):
+			tables = append(tables, name)
+		case name == "CURRENT", strings.HasPrefix(name, "MANIFEST-"),
+			strings.HasPrefix(name, "OPTIONS-"), strings.HasSuffix(name, ".log"):
+			if err := copyFile(filepath.Join(dir, name), filepath.Join(dst, name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range tables {
+		src, dstName := filepath.Join(dir, name), filepath.Join(dst, name)
+		if err := os.Link(src, dstName); err != nil {
+			if err := copyFile(src, dstName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("copying %s: %v", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// rewriteColumns rewrites each column reference $n in line,
+// which is not valid Go syntax, to the call col(n), which is.
+// A $ inside a quoted string is left alone.
+func rewriteColumns(line string) string {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(line))
+	s.Init(file, []byte(line), func(token.Position, string) {}, 0)
+	var b strings.Builder
+	last := 0
+	dollar := -1 // offset of preceding $, if any
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		off := file.Offset(pos)
+		if tok == token.INT && dollar >= 0 && dollar+1 == off {
+			b.WriteString(line[last:dollar])
+			b.WriteString("col(" + lit + ")")
+			last = off + len(lit)
+		}
+		dollar = -1
+		if tok == token.ILLEGAL && lit == "$" {
+			dollar = off
+		}
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// colRef returns the column number n if x is the column reference col(n).
+func colRef(x ast.Expr) (n int, isRef bool, err error) {
+	call, ok := x.(*ast.CallExpr)
+	if !ok {
+		return 0, false, nil
+	}
+	if id, ok := call.Fun.(*ast.Ident); !ok || id.Name != "col" {
+		return 0, false, nil
+	}
+	if len(call.Args) == 1 {
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.INT {
+			n, err := strconv.Atoi(lit.Value)
+			if err == nil && n > 0 {
+				return n, true, nil
+			}
+		}
+	}
+	return 0, true, fmt.Errorf("invalid column reference %s", gofmt(x))
+}
+
+// fill returns a copy of the load template x with each column reference
+// replaced by a literal holding the corresponding field of rec.
+// If str is true, a column reference at the top of x becomes a quoted string.
+// Otherwise it becomes an integer or floating-point literal when the field
+// is a decimal number, and a quoted string when it is not.
+// Arguments to key types use the field types to decide instead.
+func fill(x ast.Expr, rec []string, str bool) (ast.Expr, error) {
+	if n, isRef, err := colRef(x); isRef {
+		if err != nil {
+			return nil, err
+		}
+		if n > len(rec) {
+			return nil, fmt.Errorf("no column $%d in record with %d columns", n, len(rec))
+		}
+		return cellLit(rec[n-1], str), nil
+	}
+
+	switch x := x.(type) {
+	case *ast.CallExpr:
+		var kt *keyType
+		if id, ok := x.Fun.(*ast.Ident); ok {
+			if id.Name == "string" && len(x.Args) == 1 {
+				if _, isRef, _ := colRef(x.Args[0]); isRef {
+					return fill(x.Args[0], rec, true)
+				}
+			}
+			kt = lookupKeyType(id.Name)
+		}
+		y := *x
+		y.Args = make([]ast.Expr, len(x.Args))
+		for i, arg := range x.Args {
+			argStr := kt != nil && i < len(kt.fields) && kt.fields[i].typ == "string"
+			a, err := fill(arg, rec, argStr)
+			if err != nil {
+				return nil, err
+			}
+			y.Args[i] = a
+		}
+		return &y, nil
+
+	case *ast.CompositeLit:
+		var kt *keyType
+		if id, ok := x.Type.(*ast.Ident); ok {
+			kt = lookupKeyType(id.Name)
+		}
+		y := *x
+		y.Elts = make([]ast.Expr, len(x.Elts))
+		for i, elt := range x.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				y.Elts[i] = elt
+				continue
+			}
+			argStr := false
+			if key, ok := kv.Key.(*ast.Ident); ok && kt != nil {
+				for _, f := range kt.fields {
+					if f.name == key.Name {
+						argStr = f.typ == "string"
+					}
+				}
+			}
+			v, err := fill(kv.Value, rec, argStr)
+			if err != nil {
+				return nil, err
+			}
+			y.Elts[i] = &ast.KeyValueExpr{Key: kv.Key, Colon: kv.Colon, Value: v}
+		}
+		return &y, nil
+
+	case *ast.UnaryExpr:
+		v, err := fill(x.X, rec, false)
+		if err != nil {
+			return nil, err
+		}
+		y := *x
+		y.X = v
+		return &y, nil
+	}
+	return x, nil
+}
+
+// cellLit returns the literal for the record field s.
+// See fill for the meaning of str.
+func cellLit(s string, str bool) ast.Expr {
+	if !str {
+		neg := strings.HasPrefix(s, "-")
+		digits := strings.TrimPrefix(s, "-")
+		var lit *ast.BasicLit
+		if kind := numberKind(digits); kind != token.ILLEGAL {
+			lit = &ast.BasicLit{Kind: kind, Value: digits}
+		}
+		if lit != nil && neg {
+			return &ast.UnaryExpr{Op: token.SUB, X: lit}
+		}
+		if lit != nil {
+			return lit
+		}
+	}
+	return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(s)}
+}
+
+// numberKind returns token.INT or token.FLOAT if s is
+// an unsigned decimal integer or floating-point number,
+// and token.ILLEGAL otherwise.
+// Integers with leading zeros, like ZIP codes, are not numbers.
+func numberKind(s string) token.Token {
+	if s == "" || strings.Trim(s, "0123456789.eE+-") != "" {
+		return token.ILLEGAL
+	}
+	if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+		if len(s) > 1 && s[0] == '0' {
+			return token.ILLEGAL
+		}
+		return token.INT
+	}
+	if s[0] == '.' || s[0] == '+' || s[0] == '-' {
+		return token.ILLEGAL
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return token.FLOAT
+	}
+	return token.ILLEGAL
+}
+
+// load sets an entry for each record in file, a CSV file if its name
+// ends in .csv and a tab-separated file otherwise. The key and value
+// of each entry are the templates keyTmpl and valTmpl with the record's
+// fields substituted for column references.
+// Like restore, it applies the entries in batches, syncing only at the end,
+// and during a transaction, it adds the entries to the transaction instead.
+func load(db *pebble.DB, file string, keyTmpl, valTmpl ast.Expr) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	const batchSize = 1000
+	b := txn
+	if b == nil {
+		b = db.NewBatch()
+	}
+	abort := func(err error) error {
+		if b != txn {
+			b.Close()
+		}
+		return err
+	}
+
+	var next func() ([]string, int, error)
+	if strings.HasSuffix(file, ".csv") {
+		next = readCSV(f)
+	} else {
+		next = readTSV(f)
+	}
+	n := 0
+	for {
+		rec, line, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return abort(fmt.Errorf("%s: %v", file, err))
+		}
+		key, err := encodeTemplate(keyTmpl, rec)
+		if err != nil {
+			return abort(fmt.Errorf("%s:%d: key: %v", file, line, err))
+		}
+		val, err := encodeTemplate(valTmpl, rec)
+		if err != nil {
+			return abort(fmt.Errorf("%s:%d: value: %v", file, line, err))
+		}
+		if err := b.Set(key, val, nil); err != nil {
+			return abort(err)
+		}
+		n++
+		if b != txn && b.Count() >= batchSize {
+			if err := b.Commit(noSync); err != nil {
+				return err
+			}
+			b = db.NewBatch()
+		}
+	}
+	if b != txn {
+		if err := b.Commit(sync); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "loaded %d entries\n", n)
+	return nil
+}
+
+// encodeTemplate returns the encoding of the template tmpl filled in with rec.
+func encodeTemplate(tmpl ast.Expr, rec []string) ([]byte, error) {
+	x, err := fill(tmpl, rec, true)
+	if err != nil {
+		return nil, err
+	}
+	enc, ok := getEnc(x)
+	if !ok {
+		// getEnc has printed the details.
+		return nil, fmt.Errorf("invalid %s", gofmt(x))
+	}
+	return enc, nil
+}
+
+// readTSV returns a function returning successive records
+// of the tab-separated file r, along with their line numbers.
+func readTSV(r io.Reader) func() ([]string, int, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<30)
+	line := 0
+	return func() ([]string, int, error) {
+		if !s.Scan() {
+			if err := s.Err(); err != nil {
+				return nil, line, err
+			}
+			return nil, line, io.EOF
+		}
+		line++
+		return strings.Split(strings.TrimSuffix(s.Text(), "\r"), "\t"), line, nil
+	}
+}
+
+// readCSV returns a function returning successive records
+// of the CSV file r, along with their line numbers.
+func readCSV(r io.Reader) func() ([]string, int, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return func() ([]string, int, error) {
+		rec, err := cr.Read()
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		return rec, line, nil
+	}
+}
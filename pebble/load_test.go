@@ -0,0 +1,115 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"rsc.io/ordered"
+)
+
+func TestRewriteColumns(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{`load("x.tsv", o("u", $1), $3)`, `load("x.tsv", o("u", col(1)), col(3))`},
+		{`set("$1", $12)`, `set("$1", col(12))`},
+		{`set($ 1, "x")`, `set($ 1, "x")`},
+	}
+	for _, tt := range tests {
+		if out := rewriteColumns(tt.in); out != tt.out {
+			t.Errorf("rewriteColumns(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
+
+var templateTests = []struct {
+	tmpl string
+	rec  []string
+	enc  []byte
+}{
+	{`$2`, []string{"a", "42"}, []byte("42")},
+	{`o("u", $2)`, []string{"a", "42"}, ordered.Encode("u", 42)},
+	{`o("u", $2)`, []string{"a", "-4.5"}, ordered.Encode("u", -4.5)},
+	{`o("u", $2)`, []string{"a", "02139"}, ordered.Encode("u", "02139")},
+	{`o("u", string($2))`, []string{"a", "42"}, ordered.Encode("u", "42")},
+	{`o($1, rev($2))`, []string{"a", "7"}, ordered.Encode("a", ordered.Rev(int64(7)))},
+	{`user($2, $1)`, []string{"7", "8"}, ordered.Encode("user", 8, "7")},
+	{`user{field: $1, id: $2}`, []string{"7", "8"}, ordered.Encode("user", 8, "7")},
+	{`temp($1, $2)`, []string{"nyc", "3"}, ordered.Append([]byte("T:"), "nyc", 3.0)},
+}
+
+func TestTemplate(t *testing.T) {
+	loadTestSchema(t)
+	for _, tt := range templateTests {
+		x, err := parser.ParseExpr(rewriteColumns(tt.tmpl))
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", tt.tmpl, err)
+		}
+		enc, err := encodeTemplate(x, tt.rec)
+		if err != nil || !bytes.Equal(enc, tt.enc) {
+			t.Errorf("encodeTemplate(%s, %q) = %q, %v, want %q", tt.tmpl, tt.rec, enc, err, tt.enc)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	loadTestSchema(t)
+	defer func(f bool) { failed = f }(failed)
+	for _, tmpl := range []string{`$3`, `o($0)`, `col("x")`, `user($1)`} {
+		x, err := parser.ParseExpr(rewriteColumns(tmpl))
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", tmpl, err)
+		}
+		if enc, err := encodeTemplate(x, []string{"a", "b"}); err == nil {
+			t.Errorf("encodeTemplate(%s) = %q, want error", tmpl, enc)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db, err := pebble.Open("", &pebble.Options{FS: vfs.NewMem()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.tsv": "1\tx\tone\n2\ty\ttwo\r\n",
+		"b.csv": "3,z,\"three, quoted\"\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, _ := parser.ParseExpr(rewriteColumns(`o("n", $1)`))
+	val, _ := parser.ParseExpr(rewriteColumns(`$3`))
+	for name := range files {
+		if err := load(db, filepath.Join(dir, name), key, val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[int64]string{1: "one", 2: "two", 3: "three, quoted"}
+	for n, v := range want {
+		val, closer, err := db.Get(ordered.Encode("n", n))
+		if err != nil {
+			t.Errorf("get %d: %v", n, err)
+			continue
+		}
+		if string(val) != v {
+			t.Errorf("get %d = %q, want %q", n, val, v)
+		}
+		closer.Close()
+	}
+}
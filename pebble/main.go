@@ -6,20 +6,55 @@
 //
 // Usage:
 //
-//	pebble [-c] database
+//	pebble [-c | -ro] [-q] [-json] [-schema file] [-e cmds | -f file] database
 //
 // The -c flag indicates that pebble should create a new database
 // if it does not exist already. Otherwise, naming a non-existent
 // database is an error.
 //
-// At the > prompt, the following commands are supported:
+// The -ro flag opens the database read-only, and the commands that
+// modify the database (set, delete, mvprefix, restore, load, begin,
+// commit, and compact) are disabled. Pebble locks the database directory even
+// in read-only mode, so if another process has the database open,
+// -ro instead opens a private copy of it, made by linking the database's
+// immutable table files and copying its other files into a temporary
+// directory. The copy reflects the database as of when pebble started;
+// it does not see later changes made by the other process.
+//
+// Pebble reads commands from standard input, printing a > prompt
+// before each one. The -q flag suppresses the prompt.
+// When standard input is a terminal and -q is not given, pebble
+// provides line editing, a command history saved in $HOME/.pebble_history,
+// and completion using the tab key: of command names and, inside a
+// command's arguments, of keys typed or printed earlier in the session.
+// The -f flag reads commands from file instead of standard input,
+// and the -e flag runs the semicolon-separated commands cmds instead.
+// In all cases, blank lines and lines beginning with # are ignored,
+// and a line may hold multiple commands separated by semicolons.
+// If any command fails, pebble exits with a non-zero status
+// after running the remaining commands.
+//
+// The following commands are supported:
 //
 //	get(key [, end])
 //	hex(key [, end])
 //	list(start, end)
+//	count(start, end)
+//	stats([prefix])
+//	search(pattern [, start, end [, limit]])
 //	set(key, value)
 //	delete(key [, end])
 //	mvprefix(old, new)
+//	dump(file [, start, end])
+//	restore(file)
+//	load(file, key, value)
+//	begin()
+//	commit()
+//	rollback()
+//	snapshot()
+//	release()
+//	check([file])
+//	help([command])
 //
 // Get prints the value associated with the given key.
 // If the end argument is given, get prints all key, value pairs
@@ -31,6 +66,23 @@
 // List lists all known keys k such that start ≤ k < end,
 // but not their values.
 //
+// Count prints the number of keys k such that start ≤ k < end.
+//
+// Stats summarizes the entries with keys beginning with prefix
+// (or all entries, if prefix is omitted), grouping them by the
+// first ordered code value following the prefix. For each group,
+// stats prints the group's key prefix, the number of entries,
+// and the total size of their values in bytes.
+// Keys that do not continue with an ordered code value
+// are counted in a final group labeled "other".
+//
+// Search prints the key, value pairs with start ≤ key < end
+// (or all pairs, if start and end are omitted) for which the
+// regular expression pattern, a Go quoted string, matches the printed
+// form of the key or the value. It stops after printing limit
+// matches (default 100). When standard error is a terminal,
+// search shows its progress there during long scans.
+//
 // Set sets the value associated with the given key.
 //
 // Delete deletes the entry with the given key,
@@ -41,6 +93,59 @@
 // Mvprefix replaces every database entry with a key starting with old
 // by an entry with a key starting with new instead (s/old/new/).
 //
+// Dump writes all key, value pairs with start ≤ key < end
+// (or all pairs, if start and end are omitted) to the named file,
+// in JSON lines format: each line is a JSON object
+// {"key": k, "value": v}, where k and v are the base64 encodings
+// of the raw key and value bytes. The lines are in key order,
+// so that dumps of similar databases can be compared with diff.
+//
+// Restore reads a file written by dump and sets every key, value pair
+// it contains. It does not delete existing entries.
+//
+// Load reads the named file, which holds comma-separated values
+// if its name ends in .csv and tab-separated values otherwise,
+// and sets an entry for each line. The key and value arguments are
+// templates in which $n stands for the n'th field (counting from 1)
+// of the line; for example,
+//
+//	load("users.tsv", o("user", $1), $3)
+//
+// sets the key o("user", x) to the value y for each line with first field x
+// and third field y. A field used directly as a key or value is a string.
+// A field used in an o(list) is an integer or floating-point number
+// if it is a decimal number without leading zeros and a string otherwise;
+// string($n) forces a string. A field used in a key type uses the field's type.
+// Like restore, load does not delete existing entries, and it writes
+// the entries in batches, syncing the database only at the end.
+//
+// Begin starts a transaction: until the next commit or rollback,
+// set, delete, mvprefix, restore, and load record their changes in a batch
+// instead of applying them to the database. Reads see the database
+// with the batch's changes applied. Commit applies the batch atomically;
+// rollback discards it. If pebble exits during a transaction,
+// the transaction is rolled back and pebble reports an error.
+//
+// Snapshot takes a snapshot of the database: until the next release,
+// get, hex, list, and dump read from the snapshot, so that a sequence
+// of reads sees a consistent view of the database. Reads from a snapshot
+// do not see later changes, including those in a current transaction.
+//
+// Check runs pebble's consistency checks, to triage a damaged database.
+// It verifies the block checksums of every table, printing the level, name,
+// and key span of each table that is corrupted, and then checks that the keys
+// are ordered correctly within each table and across the database's levels.
+// Pebble cannot rewrite a damaged table in place, so if the file argument
+// is given and some table is damaged, check salvages the rest of the database
+// instead: it writes every entry outside the key spans of the damaged tables
+// to file, in the format written by dump, for restoring into a new database.
+// The salvage loses the entries in other tables that fall within the damaged
+// tables' spans, since the database cannot be read there.
+//
+// Help lists the commands with a short description of each.
+// Given a command name, help prints that command's usage and examples.
+// Help can also be written without parentheses, as in "help get".
+//
 // Each of the key, value, start, and end arguments can be a
 // Go quoted string or else a Go expression o(list) denoting an
 // an [ordered code] value encoding the values in the argument list.
@@ -55,11 +160,48 @@
 //   - a float32 value: float32(f) where f is an integer or floating-point literal
 //     or NaN, Inf, +Inf, or -Inf
 //   - rev(x) where x is one of the preceding choices, for a reverse-ordered value
+//     (the output prints Rev(x), which is also accepted)
 //
 // Note that Inf is an ordered infinity, while float64(Inf) is a floating-point infinity.
 //
 // The command output uses the same syntax to print keys and values.
 //
+// The -schema flag loads a file describing the structure of the database's keys.
+// Each line in the file not blank and not beginning with # defines a key type:
+//
+//	name(field type, ...) = prefix
+//
+// The prefix is a quoted string or o(list), as in commands, and each type
+// is string, int64, uint64, float32, float64, or any. A key type denotes
+// the keys consisting of the prefix followed by the ordered code encoding
+// of the field values. For example, given the schema line
+//
+//	user(id int64, field string) = o("user")
+//
+// the key o("user", 42, "email") can be written as user(42, "email")
+// or as user{id: 42, field: "email"}, and the command output prints
+// it in the second form. Either form may give only the first few
+// fields, as in user(42) or user{id: 42}, which is useful for ranges:
+// list(user(42), user(43)) lists all the keys for user 42.
+// When a key matches more than one key type, the output uses
+// the one with the longest prefix.
+//
+// The -json flag changes get, hex, and list to print one JSON object
+// per entry, for processing by tools like jq. Get prints
+// {"key": k, "value": v}, hex prints {"key": k, "hex": h},
+// where h is the value in hexadecimal, and list prints {"key": k}.
+// Each k and v is itself a JSON object describing the decoded bytes:
+//
+//   - {"ordered": [x, ...]} for an ordered code encoding
+//   - {"string": s} for other UTF-8 text
+//   - {"bytes": b} for other data, where b is the base64 encoding
+//
+// In an ordered code list, a string or integer x is a JSON string or number;
+// a float32 or float64 f is {"float32": f} or {"float64": f},
+// where f is a number or one of the strings "NaN", "+Inf", or "-Inf";
+// the ordered infinity is {"inf": true};
+// and a reverse-ordered value rev(x) is {"rev": x}.
+//
 // [ordered code]: https://pkg.go.dev/rsc.io/ordered
 package main
 
@@ -67,25 +209,39 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/printer"
+	"go/scanner"
 	"go/token"
+	"io"
 	"log"
 	"math"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/cockroachdb/pebble"
+	"golang.org/x/term"
 	"rsc.io/ordered"
 )
 
-var createDB = flag.Bool("c", false, "create database")
+var (
+	createDB   = flag.Bool("c", false, "create database")
+	readOnly   = flag.Bool("ro", false, "open database read-only")
+	jsonOut    = flag.Bool("json", false, "print get, hex, and list results as JSON lines")
+	schemaFile = flag.String("schema", "", "read key types from `file`")
+	quiet      = flag.Bool("q", false, "do not print prompts")
+	execCmds   = flag.String("e", "", "run semicolon-separated `cmds` instead of reading standard input")
+	cmdFile    = flag.String("f", "", "read commands from `file` instead of standard input")
+)
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: pebble [-c] dbdir\n")
+	fmt.Fprintf(os.Stderr, "usage: pebble [-c | -ro] [-q] [-json] [-schema file] [-e cmds | -f file] dbdir\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -96,31 +252,140 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
-	if flag.NArg() != 1 {
+	if flag.NArg() != 1 || *execCmds != "" && *cmdFile != "" || *createDB && *readOnly {
 		usage()
 	}
 	dbfile := flag.Arg(0)
 
+	if *schemaFile != "" {
+		if err := loadSchema(*schemaFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if !*createDB {
 		_, err := os.Stat(dbfile)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
-	db, err := pebble.Open(dbfile, nil)
+	db, err := pebble.Open(dbfile, &pebble.Options{ReadOnly: *readOnly})
+	var copyDir string
+	if err != nil && *readOnly {
+		var err1 error
+		db, copyDir, err1 = openCopy(dbfile)
+		if err1 != nil {
+			log.Fatalf("%v\nopening copy: %v", err, err1)
+		}
+		fmt.Fprintf(os.Stderr, "database in use; reading a copy\n")
+		err = nil
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	dbDir = dbfile
+	if copyDir != "" {
+		dbDir = copyDir
+	}
+
+	if *execCmds != "" {
+		doLine(db, *execCmds)
+	} else if *cmdFile == "" && !*quiet && isTerminal() {
+		interactive(db)
+	} else {
+		var r io.Reader = os.Stdin
+		if *cmdFile != "" {
+			f, err := os.Open(*cmdFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			r = f
+		}
+		s := bufio.NewScanner(r)
+		for {
+			if !*quiet {
+				fmt.Fprintf(os.Stderr, "> ")
+			}
+			if !s.Scan() {
+				break
+			}
+			doLine(db, s.Text())
+		}
+		if err := s.Err(); err != nil {
+			errorf("%v\n", err)
+		}
+	}
+
+	if txn != nil {
+		errorf("uncommitted transaction rolled back\n")
+		txn.Close()
+	}
+	if snap != nil {
+		snap.Close()
+	}
+	if err := db.Close(); err != nil {
+		errorf("%v\n", err)
+	}
+	if copyDir != "" {
+		os.RemoveAll(copyDir)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// failed records whether any command has failed.
+var failed bool
 
-	s := bufio.NewScanner(os.Stdin)
+// errorf prints an error message and records the failure,
+// so that pebble can exit with a non-zero status.
+func errorf(format string, args ...any) {
+	failed = true
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// doLine runs the semicolon-separated commands in line.
+// Blank lines and lines beginning with # are ignored.
+func doLine(db *pebble.DB, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	for _, cmd := range splitCommands(line) {
+		do(db, cmd)
+	}
+}
+
+// splitCommands splits line into commands at semicolons,
+// ignoring semicolons inside quoted strings.
+func splitCommands(line string) []string {
+	var cmds []string
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(line))
+	s.Init(file, []byte(line), nil, 0)
+	start := 0
 	for {
-		fmt.Fprintf(os.Stderr, "> ")
-		if !s.Scan() {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
 			break
 		}
-		line := s.Text()
-		do(db, line)
+		if tok == token.SEMICOLON && lit == ";" {
+			off := file.Offset(pos)
+			cmds = append(cmds, line[start:off])
+			start = off + 1
+		}
+	}
+	cmds = append(cmds, line[start:])
+
+	var nonEmpty []string
+	for _, cmd := range cmds {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			nonEmpty = append(nonEmpty, cmd)
+		}
 	}
+	return nonEmpty
 }
 
 var (
@@ -128,39 +393,94 @@ var (
 	noSync = &pebble.WriteOptions{Sync: false}
 )
 
+// The current transaction and snapshot, if any.
+var (
+	txn  *pebble.Batch    // started by begin; ended by commit or rollback
+	snap *pebble.Snapshot // started by snapshot; ended by release
+)
+
+// reader returns the reader for commands to use:
+// the current snapshot, the current transaction, or db.
+func reader(db *pebble.DB) pebble.Reader {
+	if snap != nil {
+		return snap
+	}
+	if txn != nil {
+		return txn
+	}
+	return db
+}
+
+// writer returns the writer for commands to use:
+// the current transaction or db.
+func writer(db *pebble.DB) pebble.Writer {
+	if txn != nil {
+		return txn
+	}
+	return db
+}
+
+// writeOps is the set of commands that modify the database,
+// which are disabled by -ro.
+var writeOps = map[string]bool{
+	"begin":    true,
+	"commit":   true,
+	"compact":  true,
+	"delete":   true,
+	"load":     true,
+	"mvprefix": true,
+	"restore":  true,
+	"set":      true,
+}
+
 func do(db *pebble.DB, line string) {
-	x, err := parser.ParseExpr(line)
+	if helpLine(line) {
+		return
+	}
+	x, err := parser.ParseExpr(rewriteColumns(line))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "parse error: %v\n", err)
+		errorf("parse error: %v\n", err)
 		return
 	}
 
 	call, ok := x.(*ast.CallExpr)
 	if !ok {
-		fmt.Fprintf(os.Stderr, "not a call expression\n")
+		errorf("not a call expression\n")
 		return
 	}
 	id, ok := call.Fun.(*ast.Ident)
 	if !ok {
-		fmt.Fprintf(os.Stderr, "call of non-identifier\n")
+		errorf("call of non-identifier\n")
+		return
+	}
+	if *readOnly && writeOps[id.Name] {
+		errorf("%s: database is read-only\n", id.Name)
 		return
 	}
 	switch id.Name {
 	default:
-		fmt.Fprintf(os.Stderr, "unknown operation %s\n", id.Name)
+		errorf("unknown operation %s; use help for a list of commands\n", id.Name)
+
+	case "help":
+		helpCall(call.Args)
 
 	case "get", "hex", "list":
 		key, end, ok := getRange(id.Name, call.Args, id.Name == "list")
 		if !ok {
 			return
 		}
+		r := reader(db)
 		if end == nil {
-			val, closer, err := db.Get(key)
+			val, closer, err := r.Get(key)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s\n", err)
+				errorf("%s\n", err)
 				return
 			}
 			defer closer.Close()
+			if *jsonOut {
+				printJSON(id.Name, key, val)
+				return
+			}
 			if id.Name == "hex" {
 				fmt.Printf("%s\n", hex.Dump(val))
 				return
@@ -169,13 +489,18 @@ func do(db *pebble.DB, line string) {
 			return
 		}
 
-		iter, err := db.NewIter(&pebble.IterOptions{LowerBound: key, UpperBound: end})
+		iter, err := r.NewIter(&pebble.IterOptions{LowerBound: key, UpperBound: end})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", err)
+			errorf("%s\n", err)
 			return
 		}
 		defer iter.Close()
 		for iter.First(); iter.Valid(); iter.Next() {
+			noteKey(iter.Key())
+			if *jsonOut {
+				printJSON(id.Name, iter.Key(), iter.Value())
+				continue
+			}
 			switch id.Name {
 			case "get":
 				fmt.Printf("%s: %s\n", decode(iter.Key()), decode(iter.Value()))
@@ -186,9 +511,78 @@ func do(db *pebble.DB, line string) {
 			}
 		}
 
+	case "count":
+		start, end, ok := getRange(id.Name, call.Args, true)
+		if !ok {
+			return
+		}
+		iter, err := reader(db).NewIter(&pebble.IterOptions{LowerBound: start, UpperBound: end})
+		if err != nil {
+			errorf("%s\n", err)
+			return
+		}
+		defer iter.Close()
+		n := 0
+		for iter.First(); iter.Valid(); iter.Next() {
+			n++
+		}
+		fmt.Printf("%d\n", n)
+
+	case "stats":
+		if len(call.Args) > 1 {
+			errorf("usage: stats([prefix])\n")
+			return
+		}
+		var prefix []byte
+		if len(call.Args) == 1 {
+			prefix, ok = getEnc(call.Args[0])
+			if !ok {
+				return
+			}
+		}
+		if err := stats(reader(db), prefix); err != nil {
+			errorf("stats: %v\n", err)
+		}
+
+	case "search":
+		if len(call.Args) != 1 && len(call.Args) != 3 && len(call.Args) != 4 {
+			errorf("usage: search(pattern [, start, end [, limit]])\n")
+			return
+		}
+		pattern, ok := getString(call.Args[0])
+		if !ok {
+			return
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errorf("search: %v\n", err)
+			return
+		}
+		var start, end []byte
+		if len(call.Args) >= 3 {
+			start, end, ok = getRange(id.Name, call.Args[1:3], true)
+			if !ok {
+				return
+			}
+		}
+		limit := 100
+		if len(call.Args) == 4 {
+			lit, ok := call.Args[3].(*ast.BasicLit)
+			if ok && lit.Kind == token.INT {
+				limit, err = strconv.Atoi(lit.Value)
+			}
+			if !ok || lit.Kind != token.INT || err != nil || limit <= 0 {
+				errorf("search: invalid limit %s\n", gofmt(call.Args[3]))
+				return
+			}
+		}
+		if err := search(reader(db), re, start, end, limit); err != nil {
+			errorf("search: %v\n", err)
+		}
+
 	case "mvprefix":
 		if len(call.Args) != 2 {
-			fmt.Fprintf(os.Stderr, "usage: mvprefix(old, new)\n")
+			errorf("usage: mvprefix(old, new)\n")
 			return
 		}
 		old, ok := getEnc(call.Args[0])
@@ -199,9 +593,11 @@ func do(db *pebble.DB, line string) {
 		if !ok {
 			return
 		}
-		iter, err := db.NewIter(&pebble.IterOptions{LowerBound: old})
+		// Read the keys to move from the writer, not any snapshot.
+		w := writer(db)
+		iter, err := w.(pebble.Reader).NewIter(&pebble.IterOptions{LowerBound: old})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", err)
+			errorf("%s\n", err)
 			return
 		}
 		defer iter.Close()
@@ -210,33 +606,34 @@ func do(db *pebble.DB, line string) {
 			if !bytes.HasPrefix(iter.Key(), old) {
 				break
 			}
-			if err := db.Set(append(new, iter.Key()[len(old):]...), iter.Value(), noSync); err != nil {
-				fmt.Fprintf(os.Stderr, "%v\n", err)
+			if err := w.Set(append(new, iter.Key()[len(old):]...), iter.Value(), noSync); err != nil {
+				errorf("%v\n", err)
 				return
 			}
 			last = bytes.Clone(iter.Key())
 		}
 		if last != nil {
-			if err := db.DeleteRange(old, iter.Key(), sync); err != nil {
-				fmt.Fprintf(os.Stderr, "%s\n", err)
+			if err := w.DeleteRange(old, append(last, 0), sync); err != nil {
+				errorf("%s\n", err)
 			}
 		}
 
 	case "set":
 		if len(call.Args) != 2 {
-			fmt.Fprintf(os.Stderr, "usage: set(key, value)\n")
+			errorf("usage: set(key, value)\n")
 			return
 		}
 		key, ok := getEnc(call.Args[0])
 		if !ok {
 			return
 		}
+		noteKey(key)
 		val, ok := getEnc(call.Args[1])
 		if !ok {
 			return
 		}
-		if err := db.Set(key, val, sync); err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
+		if err := writer(db).Set(key, val, sync); err != nil {
+			errorf("%v\n", err)
 		}
 
 	case "delete":
@@ -244,64 +641,370 @@ func do(db *pebble.DB, line string) {
 		if !ok {
 			return
 		}
+		w := writer(db)
 		if end == nil {
-			if err := db.Delete(key, sync); err != nil {
-				fmt.Fprintf(os.Stderr, "%v\n", err)
+			if err := w.Delete(key, sync); err != nil {
+				errorf("%v\n", err)
 			}
 			return
 		}
-		if err := db.DeleteRange(key, end, sync); err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
+		if err := w.DeleteRange(key, end, sync); err != nil {
+			errorf("%v\n", err)
+		}
+
+	case "dump":
+		if len(call.Args) != 1 && len(call.Args) != 3 {
+			errorf("usage: dump(file [, start, end])\n")
+			return
+		}
+		file, ok := getString(call.Args[0])
+		if !ok {
+			return
+		}
+		var start, end []byte
+		if len(call.Args) == 3 {
+			start, end, ok = getRange(id.Name, call.Args[1:], true)
+			if !ok {
+				return
+			}
+		}
+		if err := dump(reader(db), file, start, end); err != nil {
+			errorf("dump: %v\n", err)
+		}
+
+	case "restore":
+		if len(call.Args) != 1 {
+			errorf("usage: restore(file)\n")
+			return
+		}
+		file, ok := getString(call.Args[0])
+		if !ok {
+			return
+		}
+		if err := restore(db, file); err != nil {
+			errorf("restore: %v\n", err)
+		}
+
+	case "load":
+		if len(call.Args) != 3 {
+			errorf("usage: load(file, key, value)\n")
+			return
+		}
+		file, ok := getString(call.Args[0])
+		if !ok {
+			return
+		}
+		if err := load(db, file, call.Args[1], call.Args[2]); err != nil {
+			errorf("load: %v\n", err)
+		}
+
+	case "begin", "commit", "rollback", "snapshot", "release":
+		if len(call.Args) != 0 {
+			errorf("%s takes no arguments\n", id.Name)
+			return
+		}
+		switch id.Name {
+		case "begin":
+			if txn != nil {
+				errorf("begin: transaction already in progress\n")
+				return
+			}
+			txn = db.NewIndexedBatch()
+		case "commit", "rollback":
+			if txn == nil {
+				errorf("%s: no transaction in progress\n", id.Name)
+				return
+			}
+			b := txn
+			txn = nil
+			if id.Name == "commit" {
+				if err := b.Commit(sync); err != nil {
+					errorf("commit: %v\n", err)
+				}
+			}
+			b.Close()
+		case "snapshot":
+			if snap != nil {
+				errorf("snapshot: snapshot already in use\n")
+				return
+			}
+			snap = db.NewSnapshot()
+		case "release":
+			if snap == nil {
+				errorf("release: no snapshot in use\n")
+				return
+			}
+			snap.Close()
+			snap = nil
+		}
+
+	case "check":
+		if len(call.Args) > 1 {
+			errorf("usage: check([file])\n")
+			return
+		}
+		var file string
+		if len(call.Args) == 1 {
+			file, ok = getString(call.Args[0])
+			if !ok {
+				return
+			}
+		}
+		if err := check(db, file); err != nil {
+			errorf("check: %v\n", err)
 		}
 
 	case "compact":
 		if len(call.Args) != 0 {
-			fmt.Fprintf(os.Stderr, "compact takes no arguments\n")
+			errorf("compact takes no arguments\n")
+			return
+		}
+		if txn != nil || snap != nil {
+			errorf("compact: cannot compact during transaction or snapshot\n")
 			return
 		}
 		if err := db.Compact(nil, ordered.Encode(ordered.Inf), false); err != nil {
-			fmt.Fprintf(os.Stderr, "compact: %v\n", err)
+			errorf("compact: %v\n", err)
 			return
 		}
 		if err := db.Flush(); err != nil {
-			fmt.Fprintf(os.Stderr, "compact: %v\n", err)
+			errorf("compact: %v\n", err)
 			return
 		}
-		if err := db.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "compact: %v\n", err)
-			return
+	}
+}
+
+// stats prints statistics about the entries in r with keys beginning with prefix,
+// grouped by the first ordered code value after the prefix.
+func stats(r pebble.Reader, prefix []byte) error {
+	var upper []byte
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xFF {
+			upper = append(bytes.Clone(prefix[:i]), prefix[i]+1)
+			break
 		}
-		var err error
-		db, err = pebble.Open("pebble.db", nil)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "compact: %v\n", err)
-			log.Fatal("cannot reopen database")
+	}
+	iter, err := r.NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: upper})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	type group struct {
+		key   []byte // prefix shared by group, or nil for other
+		count int
+		bytes int64
+	}
+	var (
+		groups []*group // in key order
+		cur    *group
+		other  group
+		total  group
+	)
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		g := &other
+		var v any
+		if rest, err := ordered.DecodePrefix(key[len(prefix):], &v); err == nil {
+			gkey := key[:len(key)-len(rest)]
+			if cur == nil || !bytes.Equal(cur.key, gkey) {
+				cur = &group{key: bytes.Clone(gkey)}
+				groups = append(groups, cur)
+			}
+			g = cur
+		}
+		n := int64(len(iter.Value()))
+		g.count++
+		g.bytes += n
+		total.count++
+		total.bytes += n
+	}
+
+	for _, g := range groups {
+		fmt.Printf("%s: %d keys, %d value bytes\n", decode(g.key), g.count, g.bytes)
+	}
+	if other.count > 0 {
+		fmt.Printf("other: %d keys, %d value bytes\n", other.count, other.bytes)
+	}
+	fmt.Printf("total: %d keys, %d value bytes\n", total.count, total.bytes)
+	return nil
+}
+
+// search prints up to limit entries in r with start ≤ key < end
+// for which re matches the decoded key or value.
+func search(r pebble.Reader, re *regexp.Regexp, start, end []byte, limit int) error {
+	iter, err := r.NewIter(&pebble.IterOptions{LowerBound: start, UpperBound: end})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	const progressEvery = 100000
+	progress := term.IsTerminal(int(os.Stderr.Fd()))
+	scanned, found := 0, 0
+	for iter.First(); iter.Valid() && found < limit; iter.Next() {
+		scanned++
+		if progress && scanned%progressEvery == 0 {
+			fmt.Fprintf(os.Stderr, "\rscanned %d keys, %d matches", scanned, found)
+		}
+		k, v := decode(iter.Key()), decode(iter.Value())
+		if !re.MatchString(k) && !re.MatchString(v) {
+			continue
+		}
+		found++
+		if progress && scanned >= progressEvery {
+			fmt.Fprintf(os.Stderr, "\r\033[K")
+		}
+		if *jsonOut {
+			printJSON("get", iter.Key(), iter.Value())
+		} else {
+			fmt.Printf("%s: %s\n", k, v)
 		}
 	}
+	if progress && scanned >= progressEvery {
+		fmt.Fprintf(os.Stderr, "\r\033[K")
+	}
+	if found == limit {
+		fmt.Fprintf(os.Stderr, "search stopped after %d matches\n", limit)
+	}
+	return nil
+}
+
+// A dumpEntry is a single line in a dump file.
+type dumpEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// dump writes the entries in r with start ≤ key < end to file.
+// If start and end are nil, dump writes all entries.
+func dump(r pebble.Reader, file string, start, end []byte) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	iter, err := r.NewIter(&pebble.IterOptions{LowerBound: start, UpperBound: end})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	n := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		if err := enc.Encode(&dumpEntry{iter.Key(), iter.Value()}); err != nil {
+			iter.Close()
+			f.Close()
+			return err
+		}
+		n++
+	}
+	if err := iter.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "dumped %d entries\n", n)
+	return nil
+}
+
+// restore sets all the entries listed in file, which was written by dump.
+// It applies the entries in batches, syncing only at the end.
+// During a transaction, it adds the entries to the transaction instead.
+func restore(db *pebble.DB, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	const batchSize = 1000
+	b := txn
+	if b == nil {
+		b = db.NewBatch()
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	n := 0
+	for {
+		var e dumpEntry
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if b != txn {
+				b.Close()
+			}
+			return fmt.Errorf("%s: entry %d: %v", file, n+1, err)
+		}
+		if err := b.Set(e.Key, e.Value, nil); err != nil {
+			if b != txn {
+				b.Close()
+			}
+			return err
+		}
+		n++
+		if b != txn && b.Count() >= batchSize {
+			if err := b.Commit(noSync); err != nil {
+				return err
+			}
+			b = db.NewBatch()
+		}
+	}
+	if b != txn {
+		if err := b.Commit(sync); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "restored %d entries\n", n)
+	return nil
+}
+
+// getString returns the value of the Go quoted string x.
+func getString(x ast.Expr) (string, bool) {
+	if lit, ok := x.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		s, err := strconv.Unquote(lit.Value)
+		if err == nil {
+			return s, true
+		}
+	}
+	errorf("argument %s must be quoted string\n", gofmt(x))
+	return "", false
 }
 
 func getRange(name string, args []ast.Expr, forceRange bool) (lo, hi []byte, ok bool) {
 	if forceRange && len(args) < 2 {
-		fmt.Fprintf(os.Stderr, "need two arguments for key range in call to %s\n", name)
+		errorf("need two arguments for key range in call to %s\n", name)
 		return nil, nil, false
 	}
 	if len(args) > 2 {
-		fmt.Fprintf(os.Stderr, "too many arguments in call to %s", name)
+		errorf("too many arguments in call to %s\n", name)
 		return nil, nil, false
 	}
 	if len(args) == 0 {
-		fmt.Fprintf(os.Stderr, "no arguments in call to %s", name)
+		errorf("no arguments in call to %s\n", name)
 		return nil, nil, false
 	}
 	lo, ok = getEnc(args[0])
 	if !ok {
 		return nil, nil, false
 	}
+	noteKey(lo)
 	if len(args) == 2 {
 		hi, ok = getEnc(args[1])
 		if !ok {
 			return nil, nil, false
 		}
+		noteKey(hi)
 	}
 	return lo, hi, true
 }
@@ -314,13 +1017,25 @@ func getEnc(x ast.Expr) ([]byte, bool) {
 		}
 		enc, err := strconv.Unquote(x.Value)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "invalid quoted string %s\n", x.Value)
+			errorf("invalid quoted string %s\n", x.Value)
 			return nil, false
 		}
 		return []byte(enc), true
 
+	case *ast.CompositeLit:
+		if id, ok := x.Type.(*ast.Ident); ok {
+			if kt := lookupKeyType(id.Name); kt != nil {
+				return getKeyTypeLit(kt, x)
+			}
+		}
+
 	case *ast.CallExpr:
 		fn, ok := x.Fun.(*ast.Ident)
+		if ok && fn.Name != "o" {
+			if kt := lookupKeyType(fn.Name); kt != nil {
+				return encodeKeyType(kt, x.Args)
+			}
+		}
 		if !ok || fn.Name != "o" {
 			break
 		}
@@ -335,7 +1050,7 @@ func getEnc(x ast.Expr) ([]byte, bool) {
 		return ordered.Encode(list...), true
 	}
 
-	fmt.Fprintf(os.Stderr, "argument %s must be quoted string or o(list)\n", gofmt(x))
+	errorf("argument %s must be quoted string, o(list), or key type\n", gofmt(x))
 	return nil, false
 }
 
@@ -351,7 +1066,7 @@ func getArg(x ast.Expr, flags int) (any, bool) {
 		case token.STRING:
 			v, err := strconv.Unquote(x.Value)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "invalid quoted string %s\n", x.Value)
+				errorf("invalid quoted string %s\n", x.Value)
 				return nil, false
 			}
 			return v, true
@@ -389,7 +1104,7 @@ func getArg(x ast.Expr, flags int) (any, bool) {
 				if flags&forceFloat64 != 0 {
 					return math.Inf(sign), true
 				}
-				fmt.Fprintf(os.Stderr, "must use float32(%s) or float64(%s)\n", gofmt(x), gofmt(x))
+				errorf("must use float32(%s) or float64(%s)\n", gofmt(x), gofmt(x))
 				return nil, false
 			}
 			if basic, ok := x.X.(*ast.BasicLit); ok {
@@ -402,7 +1117,7 @@ func getArg(x ast.Expr, flags int) (any, bool) {
 					return v * int64(sign), true
 				case uint64:
 					if sign == -1 && v > 1<<63 {
-						fmt.Fprintf(os.Stderr, "%s is out of range for int64", gofmt(x))
+						errorf("%s is out of range for int64\n", gofmt(x))
 						return nil, false
 					}
 					return v * uint64(sign), true
@@ -423,28 +1138,28 @@ func getArg(x ast.Expr, flags int) (any, bool) {
 			if flags&forceFloat64 != 0 {
 				return math.NaN(), true
 			}
-			fmt.Fprintf(os.Stderr, "must use float32(NaN) or float64(NaN)\n")
+			errorf("must use float32(NaN) or float64(NaN)\n")
 			return nil, false
 		}
 
 	case *ast.CallExpr:
 		fn, ok := x.Fun.(*ast.Ident)
 		if !ok {
-			fmt.Fprintf(os.Stderr, "unknown call to %s\n", gofmt(x.Fun))
+			errorf("unknown call to %s\n", gofmt(x.Fun))
 			return nil, false
 		}
 		if len(x.Args) != 1 {
-			fmt.Fprintf(os.Stderr, "call to %s requires 1 argument\n", gofmt(x.Fun))
+			errorf("call to %s requires 1 argument\n", gofmt(x.Fun))
 			return nil, false
 		}
 		switch fn.Name {
 		default:
-			fmt.Fprintf(os.Stderr, "unknown call to %s\n", fn.Name)
+			errorf("unknown call to %s\n", fn.Name)
 			return nil, false
 
-		case "rev":
+		case "rev", "Rev":
 			if flags&noRev != 0 {
-				fmt.Fprintf(os.Stderr, "invalid nested reverse\n")
+				errorf("invalid nested reverse\n")
 				return nil, false
 			}
 			v, ok := getArg(x.Args[0], noRev)
@@ -464,11 +1179,14 @@ func getArg(x ast.Expr, flags int) (any, bool) {
 			return getArg(x.Args[0], noRev|forceFloat64)
 		}
 	}
-	fmt.Fprintf(os.Stderr, "invalid ordered value %s", gofmt(x))
+	errorf("invalid ordered value %s\n", gofmt(x))
 	return nil, false
 }
 
 func decode(enc []byte) string {
+	if s, ok := decodeKeyType(enc); ok {
+		return s
+	}
 	if s, err := ordered.DecodeFmt(enc); err == nil {
 		return "o" + s
 	}
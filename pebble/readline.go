@@ -0,0 +1,261 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+	"golang.org/x/term"
+)
+
+// verbs lists the command names, for completion.
+var verbs = commandNames()
+
+// maxSeenKeys is the maximum number of keys remembered for completion.
+const maxSeenKeys = 10000
+
+// seenKeys holds the keys typed in commands or printed by them,
+// in the form printed by decode, for completion.
+// It is nil, and keys are not recorded, when pebble is not interactive.
+var seenKeys map[string]bool
+
+// noteKey records key for completion.
+func noteKey(key []byte) {
+	if seenKeys != nil && len(seenKeys) < maxSeenKeys {
+		seenKeys[decode(key)] = true
+	}
+}
+
+// isTerminal reports whether standard input and standard error are terminals,
+// in which case pebble uses a line editor to read commands.
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// stdio is the terminal device: input from standard input,
+// echo and prompts to standard error.
+type stdio struct{}
+
+func (stdio) Read(b []byte) (int, error)  { return os.Stdin.Read(b) }
+func (stdio) Write(b []byte) (int, error) { return os.Stderr.Write(b) }
+
+// interactive reads and runs commands from the terminal,
+// with line editing, history, and completion of command names
+// and of keys seen earlier in the session.
+// The terminal is in raw mode only while reading a line,
+// so that command output is printed normally.
+func interactive(db *pebble.DB) {
+	fd := int(os.Stdin.Fd())
+	t := term.NewTerminal(stdio{}, "> ")
+	t.History = loadHistory()
+	t.AutoCompleteCallback = complete
+	seenKeys = make(map[string]bool)
+	for {
+		old, err := term.MakeRaw(fd)
+		if err != nil {
+			errorf("%v\n", err)
+			return
+		}
+		if w, h, err := term.GetSize(fd); err == nil && w > 0 {
+			t.SetSize(w, h)
+		}
+		line, err := t.ReadLine()
+		term.Restore(fd, old)
+		if err == io.EOF {
+			os.Stderr.WriteString("\n")
+			return
+		}
+		if err != nil && err != term.ErrPasteIndicator {
+			errorf("%v\n", err)
+			return
+		}
+		doLine(db, line)
+	}
+}
+
+// complete is the terminal's AutoCompleteCallback.
+// When the tab key is pressed during a command name,
+// it completes the name as far as possible, adding an opening
+// parenthesis when the name is unambiguous.
+// When the tab key is pressed during a command argument,
+// it completes the argument as far as possible
+// using the keys seen earlier in the session.
+func complete(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := strings.LastIndex(line[:pos], ";") + 1
+	for start < pos && line[start] == ' ' {
+		start++
+	}
+	if arg, ok := argStart(line[start:pos]); ok {
+		start += arg
+		for start < pos && line[start] == ' ' {
+			start++
+		}
+		done, ok := completeKey(line[start:pos])
+		if !ok {
+			return "", 0, false
+		}
+		return line[:start] + done + line[pos:], start + len(done), true
+	}
+	prefix := line[start:pos]
+	if strings.ContainsAny(prefix, "( \t\"`") {
+		return "", 0, false
+	}
+
+	var match []string
+	for _, v := range verbs {
+		if strings.HasPrefix(v, prefix) {
+			match = append(match, v)
+		}
+	}
+	if len(match) == 0 {
+		return "", 0, false
+	}
+	done := commonPrefix(match)
+	if len(match) == 1 {
+		done += "("
+	}
+	return line[:start] + done + line[pos:], start + len(done), true
+}
+
+// argStart returns the offset in the partial command cmd
+// of the start of the argument being typed at its end.
+// It reports false if cmd does not end inside the command's argument list.
+// Commas and parentheses inside quoted strings or inside nested calls
+// such as o(list) do not separate arguments.
+func argStart(cmd string) (start int, ok bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+			if depth == 1 {
+				start = i + 1
+			}
+		case c == ')':
+			depth--
+		case c == ',' && depth == 1:
+			start = i + 1
+		}
+	}
+	return start, depth >= 1
+}
+
+// completeKey returns the completion of the partial key prefix
+// to the longest prefix shared by the matching keys in seenKeys.
+// A prefix beginning with a double quote also matches the keys
+// that decode prints as backquoted strings.
+func completeKey(prefix string) (string, bool) {
+	if prefix == "" {
+		return "", false
+	}
+	var match []string
+	for k := range seenKeys {
+		if strings.HasPrefix(k, "`") && strings.HasPrefix(prefix, `"`) {
+			k = strconv.Quote(k[1 : len(k)-1])
+		}
+		if strings.HasPrefix(k, prefix) {
+			match = append(match, k)
+		}
+	}
+	if len(match) == 0 {
+		return "", false
+	}
+	return commonPrefix(match), true
+}
+
+// commonPrefix returns the longest common prefix of the strings in list,
+// which must not be empty.
+func commonPrefix(list []string) string {
+	done := list[0]
+	for _, s := range list[1:] {
+		for !strings.HasPrefix(s, done) {
+			done = done[:len(done)-1]
+		}
+	}
+	return done
+}
+
+// maxHistory is the maximum number of lines kept in the history file.
+const maxHistory = 1000
+
+// A history is a terminal history backed by a file,
+// by default $HOME/.pebble_history.
+type history struct {
+	file  string
+	lines []string // oldest first
+}
+
+// loadHistory returns the history read from the history file.
+// If the file cannot be read, the history starts empty.
+func loadHistory() *history {
+	h := new(history)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+	h.file = filepath.Join(home, ".pebble_history")
+	f, err := os.Open(h.file)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := s.Text(); line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+	}
+	return h
+}
+
+func (h *history) Len() int { return len(h.lines) }
+
+func (h *history) At(i int) string { return h.lines[len(h.lines)-1-i] }
+
+// Add adds line to the history and appends it to the history file.
+// When the file grows well past maxHistory lines, Add rewrites it
+// to hold only the most recent lines.
+func (h *history) Add(line string) {
+	if strings.TrimSpace(line) == "" || len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if h.file == "" {
+		return
+	}
+	if len(h.lines) > 2*maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+		os.WriteFile(h.file, []byte(strings.Join(h.lines, "\n")+"\n"), 0600)
+		return
+	}
+	f, err := os.OpenFile(h.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	f.WriteString(line + "\n")
+	f.Close()
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"rsc.io/ordered"
+)
+
+var completeTests = []struct {
+	line string
+	out  string // line after completion, with | marking the cursor; "" for no completion
+}{
+	{"ge|", "get(|"},
+	{"co|", "co|"},
+	{"com|", "com|"},
+	{"hel|", "help(|"},
+	{"xyz|", ""},
+	{`get("us|`, `get("user/|`},
+	{"get(`us|", "get(`user/|"},
+	{`get("user/a|`, "get(\"user/alice\"|"},
+	{`list("user/alice", "us|`, `list("user/alice", "user/|`},
+	{`get(o("ac|`, `get(o("acct", |`},
+	{`get(o("acct", 1|`, `get(o("acct", 1)|`},
+	{`set("a,b(", "us|`, `set("a,b(", "user/|`},
+	{`get(|`, ""},
+	{`get("zz|`, ""},
+	{`get("user/bob"); get("user/a|`, `get("user/bob"); get("user/alice"|`},
+}
+
+func TestComplete(t *testing.T) {
+	old := seenKeys
+	defer func() { seenKeys = old }()
+	seenKeys = make(map[string]bool)
+	noteKey([]byte("user/alice"))
+	noteKey([]byte("user/bob"))
+	noteKey(ordered.Encode("acct", 1))
+	noteKey(ordered.Encode("acct", 2))
+
+	for _, tt := range completeTests {
+		pos := len(tt.line) - 1
+		line := tt.line[:pos]
+		out, newPos, ok := complete(line, pos, '\t')
+		have := ""
+		if ok {
+			have = out[:newPos] + "|" + out[newPos:]
+		}
+		if have != tt.out {
+			t.Errorf("complete(%q) = %q, want %q", tt.line, have, tt.out)
+		}
+	}
+}
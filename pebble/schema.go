@@ -0,0 +1,276 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+
+	"rsc.io/ordered"
+)
+
+// A keyType describes a family of keys: a fixed prefix
+// followed by the ordered code encoding of a list of named fields.
+type keyType struct {
+	name   string
+	prefix []byte
+	fields []field
+}
+
+// A field is a single named, typed field in a keyType.
+type field struct {
+	name string
+	typ  string // string, int64, uint64, float32, float64, or any
+}
+
+// schema lists the key types loaded by -schema, in file order.
+var schema []*keyType
+
+// lookupKeyType returns the key type with the given name, or nil.
+func lookupKeyType(name string) *keyType {
+	for _, kt := range schema {
+		if kt.name == name {
+			return kt
+		}
+	}
+	return nil
+}
+
+// loadSchema reads the schema file and appends its key types to schema.
+// Each non-blank line not beginning with # has the form
+//
+//	name(field type, ...) = prefix
+//
+// where prefix is a quoted string or o(list) as in commands.
+func loadSchema(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kt, err := parseKeyType(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", file, i+1, err)
+		}
+		schema = append(schema, kt)
+	}
+	return nil
+}
+
+// parseKeyType parses a single schema line.
+func parseKeyType(line string) (*keyType, error) {
+	decl, pre, ok := strings.Cut(line, "=")
+	i := strings.Index(decl, "(")
+	if !ok || i < 0 {
+		return nil, fmt.Errorf("malformed key type: want name(field type, ...) = prefix")
+	}
+	kt := &keyType{name: strings.TrimSpace(decl[:i])}
+	if !token.IsIdentifier(kt.name) {
+		return nil, fmt.Errorf("invalid key type name %q", kt.name)
+	}
+	switch kt.name {
+	case "o", "rev", "float32", "float64", "Inf", "NaN", "col", "string":
+		return nil, fmt.Errorf("key type name %s is reserved", kt.name)
+	}
+	if lookupKeyType(kt.name) != nil {
+		return nil, fmt.Errorf("duplicate key type %s", kt.name)
+	}
+
+	x, err := parser.ParseExpr("func" + strings.TrimSpace(decl[i:]))
+	ft, ok := x.(*ast.FuncType)
+	if err != nil || !ok || ft.Results != nil {
+		return nil, fmt.Errorf("malformed field list %s", strings.TrimSpace(decl[i:]))
+	}
+	seen := make(map[string]bool)
+	for _, f := range ft.Params.List {
+		typ, ok := f.Type.(*ast.Ident)
+		if !ok || !validFieldType(typ.Name) {
+			return nil, fmt.Errorf("invalid field type %s", gofmt(f.Type))
+		}
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("missing field name for %s", typ.Name)
+		}
+		for _, name := range f.Names {
+			if seen[name.Name] {
+				return nil, fmt.Errorf("duplicate field %s", name.Name)
+			}
+			seen[name.Name] = true
+			kt.fields = append(kt.fields, field{name.Name, typ.Name})
+		}
+	}
+
+	px, err := parser.ParseExpr(strings.TrimSpace(pre))
+	if err != nil {
+		return nil, fmt.Errorf("malformed prefix: %v", err)
+	}
+	kt.prefix, ok = getEnc(px)
+	if !ok {
+		return nil, fmt.Errorf("invalid prefix %s", strings.TrimSpace(pre))
+	}
+	return kt, nil
+}
+
+func validFieldType(typ string) bool {
+	switch typ {
+	case "string", "int64", "uint64", "float32", "float64", "any":
+		return true
+	}
+	return false
+}
+
+// fieldValue reports whether v, decoded by ordered.DecodeAny,
+// is a valid value for a field of type typ.
+func fieldValue(typ string, v any) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "int64":
+		_, ok := v.(int64)
+		return ok
+	case "uint64":
+		i, ok := v.(int64)
+		_, u := v.(uint64)
+		return ok && i >= 0 || u
+	case "float32":
+		_, ok := v.(float32)
+		return ok
+	case "float64":
+		_, ok := v.(float64)
+		return ok
+	}
+	return true
+}
+
+// encodeKeyType returns the encoding of a key of type kt with the given field values.
+// The values may list only a prefix of kt's fields, to denote a range of keys.
+func encodeKeyType(kt *keyType, args []ast.Expr) ([]byte, bool) {
+	if len(args) > len(kt.fields) {
+		errorf("too many fields in %s: have %d, want at most %d\n", kt.name, len(args), len(kt.fields))
+		return nil, false
+	}
+	var list []any
+	for i, arg := range args {
+		f := kt.fields[i]
+		flags := 0
+		if f.typ == "float32" || f.typ == "float64" {
+			flags = noRev | forceFloat64
+		}
+		v, ok := getArg(arg, flags)
+		if !ok {
+			return nil, false
+		}
+		if f.typ == "float32" {
+			v = float32(v.(float64))
+		}
+		if !fieldValue(f.typ, v) {
+			errorf("invalid %s value %s for %s.%s\n", f.typ, gofmt(arg), kt.name, f.name)
+			return nil, false
+		}
+		list = append(list, v)
+	}
+	return ordered.Append(bytes.Clone(kt.prefix), list...), true
+}
+
+// getKeyTypeLit returns the encoding of the key type literal x,
+// which has the form name{field: value, ...}.
+// As with encodeKeyType, the literal may list only a prefix of the fields.
+func getKeyTypeLit(kt *keyType, x *ast.CompositeLit) ([]byte, bool) {
+	vals := make([]ast.Expr, len(kt.fields))
+	n := 0
+	for _, elt := range x.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		var key *ast.Ident
+		if ok {
+			key, ok = kv.Key.(*ast.Ident)
+		}
+		if !ok {
+			errorf("%s literal must use field: value form\n", kt.name)
+			return nil, false
+		}
+		i := 0
+		for i < len(kt.fields) && kt.fields[i].name != key.Name {
+			i++
+		}
+		if i == len(kt.fields) {
+			errorf("unknown field %s.%s\n", kt.name, key.Name)
+			return nil, false
+		}
+		if vals[i] != nil {
+			errorf("duplicate field %s.%s\n", kt.name, key.Name)
+			return nil, false
+		}
+		vals[i] = kv.Value
+		n = max(n, i+1)
+	}
+	for i, v := range vals[:n] {
+		if v == nil {
+			errorf("missing field %s.%s before %s.%s\n", kt.name, kt.fields[i].name, kt.name, kt.fields[n-1].name)
+			return nil, false
+		}
+	}
+	return encodeKeyType(kt, vals[:n])
+}
+
+// decodeKeyType returns the formatted form of enc as a key type literal,
+// using the key type with the longest matching prefix whose fields
+// fit the remainder of enc. If there is no such key type, decodeKeyType
+// returns ok=false.
+func decodeKeyType(enc []byte) (s string, ok bool) {
+	var best *keyType
+	var bestList []any
+	for _, kt := range schema {
+		if !bytes.HasPrefix(enc, kt.prefix) || best != nil && len(kt.prefix) <= len(best.prefix) {
+			continue
+		}
+		list, err := ordered.DecodeAny(enc[len(kt.prefix):])
+		if err != nil || len(list) > len(kt.fields) {
+			continue
+		}
+		fits := true
+		for i, v := range list {
+			if !fieldValue(kt.fields[i].typ, v) {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			best, bestList = kt, list
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(best.name)
+	b.WriteString("{")
+	for i, v := range bestList {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %s", best.fields[i].name, formatValue(v))
+	}
+	b.WriteString("}")
+	return b.String(), true
+}
+
+// formatValue returns the command syntax for the single ordered value v.
+func formatValue(v any) string {
+	s, err := ordered.DecodeFmt(ordered.Encode(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+}
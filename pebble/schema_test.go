@@ -0,0 +1,149 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"testing"
+
+	"rsc.io/ordered"
+)
+
+const testSchema = `user(id int64, field string) = o("user")
+temp(city string, t float64) = "T:"
+admin(id int64) = o("user", "admin")
+`
+
+func loadTestSchema(t *testing.T) {
+	t.Helper()
+	old := schema
+	t.Cleanup(func() { schema = old })
+	schema = nil
+	for _, line := range bytes.Split([]byte(testSchema), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		kt, err := parseKeyType(string(line))
+		if err != nil {
+			t.Fatalf("parseKeyType(%q): %v", line, err)
+		}
+		schema = append(schema, kt)
+	}
+}
+
+func TestParseKeyType(t *testing.T) {
+	loadTestSchema(t)
+	kt := lookupKeyType("user")
+	if kt == nil {
+		t.Fatal("missing key type user")
+	}
+	if !bytes.Equal(kt.prefix, ordered.Encode("user")) {
+		t.Errorf("user prefix = %q, want %q", kt.prefix, ordered.Encode("user"))
+	}
+	want := []field{{"id", "int64"}, {"field", "string"}}
+	if len(kt.fields) != len(want) || kt.fields[0] != want[0] || kt.fields[1] != want[1] {
+		t.Errorf("user fields = %v, want %v", kt.fields, want)
+	}
+	if kt := lookupKeyType("temp"); kt == nil || string(kt.prefix) != "T:" {
+		t.Errorf("temp = %v, want prefix T:", kt)
+	}
+}
+
+var badKeyTypes = []string{
+	`user(id int64)`,
+	`user id int64 = "u"`,
+	`o(id int64) = "u"`,
+	`x(id int) = "u"`,
+	`x(int64) = "u"`,
+	`x(id, id string) = "u"`,
+	`x(id string) int = "u"`,
+	`x(id string) = u`,
+	`user(id int64) = "u"`, // duplicate
+}
+
+func TestParseKeyTypeErrors(t *testing.T) {
+	loadTestSchema(t)
+	for _, line := range badKeyTypes {
+		if kt, err := parseKeyType(line); err == nil {
+			t.Errorf("parseKeyType(%q) = %v, want error", line, kt)
+		}
+	}
+}
+
+var keyTypeTests = []struct {
+	in  string // command syntax
+	enc []byte
+	out string // decode output
+}{
+	{`user(42, "email")`, ordered.Encode("user", 42, "email"), `user{id: 42, field: "email"}`},
+	{`user{id: 42, field: "email"}`, ordered.Encode("user", 42, "email"), `user{id: 42, field: "email"}`},
+	{`user{field: "email", id: 42}`, ordered.Encode("user", 42, "email"), `user{id: 42, field: "email"}`},
+	{`user(42)`, ordered.Encode("user", 42), `user{id: 42}`},
+	{`user()`, ordered.Encode("user"), `user{}`},
+	{`temp("nyc", 1.5)`, ordered.Append([]byte("T:"), "nyc", 1.5), `temp{city: "nyc", t: float64(1.5)}`},
+	{`temp("nyc", 2)`, ordered.Append([]byte("T:"), "nyc", 2.0), `temp{city: "nyc", t: float64(2)}`},
+	{`admin(1)`, ordered.Encode("user", "admin", 1), `admin{id: 1}`}, // longest prefix wins
+	{`o("user", "x")`, ordered.Encode("user", "x"), `o("user", "x")`},
+}
+
+func TestKeyType(t *testing.T) {
+	loadTestSchema(t)
+	for _, tt := range keyTypeTests {
+		x, err := parser.ParseExpr(tt.in)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", tt.in, err)
+		}
+		enc, ok := getEnc(x)
+		if !ok || !bytes.Equal(enc, tt.enc) {
+			t.Errorf("getEnc(%s) = %q, %v, want %q", tt.in, enc, ok, tt.enc)
+		}
+		if out := decode(tt.enc); out != tt.out {
+			t.Errorf("decode(%q) = %s, want %s", tt.enc, out, tt.out)
+		}
+	}
+}
+
+var badKeyTypeExprs = []string{
+	`user(1.5)`,
+	`user("x")`,
+	`user(1, "a", 2)`,
+	`user{field: "a"}`,
+	`user{id: 1, id: 2}`,
+	`user{name: "a"}`,
+	`user{1}`,
+}
+
+func TestKeyTypeErrors(t *testing.T) {
+	loadTestSchema(t)
+	defer func(f bool) { failed = f }(failed)
+	for _, in := range badKeyTypeExprs {
+		x, err := parser.ParseExpr(in)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", in, err)
+		}
+		if enc, ok := getEnc(x); ok {
+			t.Errorf("getEnc(%s) = %q, want error", in, enc)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		v   any
+		out string
+	}{
+		{"a\"b", `"a\"b"`},
+		{int64(-3), `-3`},
+		{float32(0.5), `float32(0.5)`},
+		{ordered.Inf, `Inf`},
+		{ordered.Rev("x"), `Rev("x")`},
+	}
+	for _, tt := range tests {
+		if out := formatValue(tt.v); out != tt.out {
+			t.Errorf("formatValue(%#v) = %s, want %s", tt.v, out, tt.out)
+		}
+	}
+}
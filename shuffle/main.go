@@ -6,7 +6,8 @@
 //
 // Usage:
 //
-//	shuffle [-b] [-g regexp] [-m max] [file...]
+//	shuffle [-b | -0 | -d delim] [-g regexp] [-m max | -r n | -spill mb] [-w | -wf regexp] [-seed n | -secure] [file...]
+//	shuffle -perfile -o dir [-b | -0 | -d delim] [-g regexp] [-m max | -r n | -spill mb] [-w | -wf regexp] [-seed n | -secure] file...
 //
 // Shuffle reads the named files, or else standard input
 // and then prints a random permutation of the input lines.
@@ -14,34 +15,162 @@
 // The -b flag causes shuffle to shuffle blocks of non-blank lines
 // in the input (separated by blank lines) rather than individual lines.
 //
+// The -0 flag causes shuffle to read and write records terminated by
+// NUL bytes instead of lines, as printed by "find -print0" and read by
+// "xargs -0", so that it can shuffle file names containing newlines.
+// More generally, the -d flag causes shuffle to use records terminated
+// by delim, which may contain Go backslash escapes such as \t or \x00.
+// A final record without a terminator is treated as if it had one.
+// The -0 and -d flags cannot be combined with -b.
+//
 // The -g flag only shuffles lines or blocks matching the regexp.
 //
 // The -m flag specifies the maximum number of lines (or blocks) to print.
 // When -m is given, shuffle requires memory only for the output,
 // not for the entire input.
+//
+// Without -m, shuffle holds the entire input in memory.
+// The -spill flag lets shuffle permute inputs larger than memory:
+// once the input lines (or blocks) use more than mb megabytes,
+// shuffle distributes them at random across temporary files
+// in $TMPDIR and then shuffles each file separately.
+// The -spill flag cannot be combined with -m, -r, -w, or -wf.
+//
+// The -w flag makes the shuffle weighted: the first field of each line
+// (or of the first line of each block) is a non-negative number giving its weight.
+// Instead of a uniformly random permutation, shuffle prints the lines in an order
+// in which each next line is chosen with probability proportional to its weight
+// among the lines not yet printed. Lines with weight 0 are never printed.
+// Combined with -m, this samples max lines without replacement, weighted by
+// their weights, still using memory only for the output.
+// The -wf flag is like -w but takes the weight from the first
+// parenthesized submatch of the regexp (or the entire match, if the regexp
+// has no submatches) instead of the first field.
+//
+// The -r flag prints n lines (or blocks) sampled with replacement,
+// so that a line can be printed more than once. Each line is chosen
+// uniformly at random or, with -w or -wf, with probability proportional
+// to its weight. The -r flag cannot be combined with -m.
+//
+// The -seed flag specifies the seed for the random number generator,
+// so that a shuffle can be reproduced. By default, shuffle picks
+// a seed at random. Any value, including 0, may be given.
+// The generator is ChaCha8 (see [math/rand/v2]), keyed by a hash of the seed.
+//
+// The -secure flag uses the operating system's cryptographic random
+// number generator (see [crypto/rand]) instead of a seeded generator,
+// for shuffles that must not be predictable. Such shuffles cannot be
+// reproduced, so -secure cannot be combined with -seed.
+//
+// The -perfile flag causes shuffle to shuffle each named file separately,
+// writing the result to a file with the same base name in the directory
+// given by the -o flag, instead of pooling the input lines of all the
+// files into a single shuffle. Each file's shuffle uses its own random
+// stream, seeded by a hash of the main seed and the file's base name,
+// so that the result for a given file depends only on the seed and
+// that file, not on the other files named on the command line.
+// When neither -seed nor -secure is given, shuffle -perfile prints
+// the seed it picked to standard error, so that the per-file results
+// can be reproduced.
 package main
 
 import (
 	"bufio"
+	"container/heap"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
 	"flag"
+	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/rand"
+	randv2 "math/rand/v2"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 var (
-	max   = flag.Int("m", 0, "print at most `max` lines (or blocks)")
-	block = flag.Bool("b", false, "shuffle blank-line-separated blocks")
-	grep  = flag.String("g", "", "consider only lines (or blocks) matching `regexp`")
+	max    = flag.Int("m", 0, "print at most `max` lines (or blocks)")
+	block  = flag.Bool("b", false, "shuffle blank-line-separated blocks")
+	zero   = flag.Bool("0", false, "shuffle NUL-terminated records instead of lines")
+	delimF = flag.String("d", "", "shuffle records terminated by `delim` instead of lines")
+	grep   = flag.String("g", "", "consider only lines (or blocks) matching `regexp`")
+	seed   = flag.Int64("seed", 0, "seed random number generator with `n` (default random)")
+	secure = flag.Bool("secure", false, "use crypto/rand instead of a seeded generator")
+
+	weightFirst = flag.Bool("w", false, "weight lines (or blocks) by their first field")
+	weightRE    = flag.String("wf", "", "weight lines (or blocks) by the first submatch of `regexp`")
+	repeat      = flag.Int("r", 0, "print `n` lines (or blocks) sampled with replacement")
+	spill       = flag.Int("spill", 0, "spill input to temporary files beyond `mb` megabytes")
+
+	perFile = flag.Bool("perfile", false, "shuffle each file separately into -o dir")
+	outDir  = flag.String("o", "", "with -perfile, write shuffled files to `dir`")
 
 	grepRE *regexp.Regexp
+	wfRE   *regexp.Regexp
+	rng    *rand.Rand
+	delim  = "\n" // record terminator
 )
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: shuffle [-b | -0 | -d delim] [-g regexp] [-m max | -r n | -spill mb] [-w | -wf regexp] [-seed n | -secure] [file...]\n")
+	fmt.Fprintf(os.Stderr, "       shuffle -perfile -o dir [-b | -0 | -d delim] [-g regexp] [-m max | -r n | -spill mb] [-w | -wf regexp] [-seed n | -secure] file...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
 func main() {
+	log.SetFlags(0)
+	log.SetPrefix("shuffle: ")
+	flag.Usage = usage
 	flag.Parse()
+	if *perFile != (*outDir != "") || *perFile && flag.NArg() == 0 {
+		usage()
+	}
+	if *repeat < 0 || *repeat > 0 && *max != 0 || *weightFirst && *weightRE != "" {
+		usage()
+	}
+	if *spill < 0 || *spill > 0 && (*max != 0 || *repeat != 0 || *weightFirst || *weightRE != "") {
+		usage()
+	}
+	if *zero && *delimF != "" || (*zero || *delimF != "") && *block {
+		usage()
+	}
+	if *zero {
+		delim = "\x00"
+	}
+	if *delimF != "" {
+		d, err := strconv.Unquote(`"` + *delimF + `"`)
+		if err != nil || d == "" {
+			log.Fatalf("invalid -d delimiter %q", *delimF)
+		}
+		delim = d
+	}
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+	if seedSet && *secure {
+		usage()
+	}
+	if !seedSet && !*secure {
+		var buf [8]byte
+		if _, err := cryptorand.Read(buf[:]); err != nil {
+			log.Fatal(err)
+		}
+		*seed = int64(binary.BigEndian.Uint64(buf[:]))
+		if *perFile {
+			log.Printf("using -seed %d", *seed)
+		}
+	}
 	if *grep != "" {
 		re, err := regexp.Compile(*grep)
 		if err != nil {
@@ -49,6 +178,18 @@ func main() {
 		}
 		grepRE = re
 	}
+	if *weightRE != "" {
+		re, err := regexp.Compile(*weightRE)
+		if err != nil {
+			log.Fatal(err)
+		}
+		wfRE = re
+	}
+	if *perFile {
+		shufflePerFile(flag.Args())
+		return
+	}
+	rng = newRand(*seed)
 	if flag.NArg() == 0 {
 		collect(os.Stdin)
 	} else {
@@ -61,16 +202,117 @@ func main() {
 			f.Close()
 		}
 	}
-	show()
+	w := bufio.NewWriter(os.Stdout)
+	show(w)
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// shufflePerFile shuffles each of the files separately into *outDir.
+func shufflePerFile(files []string) {
+	seen := make(map[string]string)
+	for _, file := range files {
+		base := filepath.Base(file)
+		if old, ok := seen[base]; ok {
+			log.Fatalf("%s and %s have the same base name", old, file)
+		}
+		seen[base] = file
+	}
+	if err := os.MkdirAll(*outDir, 0777); err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range files {
+		base := filepath.Base(file)
+		list, n, items, recs = nil, 0, nil, pool{}
+		rng = newRand(subSeed(*seed, base))
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		collect(f)
+		f.Close()
+
+		out, err := os.Create(filepath.Join(*outDir, base))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := bufio.NewWriter(out)
+		show(w)
+		if err := w.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// newRand returns a random number generator seeded with seed,
+// or, for -secure, one reading from crypto/rand.
+func newRand(seed int64) *rand.Rand {
+	if *secure {
+		return rand.New(cryptoSource{})
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	return rand.New(chachaSource{randv2.NewChaCha8(sha256.Sum256(buf[:]))})
+}
+
+// A chachaSource adapts a ChaCha8 generator to the math/rand Source64 interface.
+type chachaSource struct {
+	c *randv2.ChaCha8
+}
+
+func (s chachaSource) Uint64() uint64 { return s.c.Uint64() }
+func (s chachaSource) Int63() int64   { return int64(s.c.Uint64() >> 1) }
+func (s chachaSource) Seed(int64)     { panic("chachaSource: Seed not supported") }
+
+// A cryptoSource is a math/rand Source64 reading from crypto/rand.
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var buf [8]byte
+	if _, err := cryptorand.Read(buf[:]); err != nil {
+		log.Fatal(err)
+	}
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+func (s cryptoSource) Int63() int64 { return int64(s.Uint64() >> 1) }
+func (cryptoSource) Seed(int64)     { panic("cryptoSource: Seed not supported") }
+
+// subSeed returns the seed for the random stream used to shuffle
+// the file with the given base name, derived from the main seed.
+func subSeed(seed int64, name string) int64 {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, seed)
+	io.WriteString(h, name)
+	return int64(binary.BigEndian.Uint64(h.Sum(nil)))
 }
 
+// recs holds the records for a uniform shuffle of the entire input.
+var recs pool
+
+// list holds the records for a uniform shuffle with -m,
+// of which n have been read.
 var list []string
 var n int
 
-func add(s string) {
+// add adds the record rec to the shuffle.
+func add(rec []byte) {
+	if weighted() || *repeat > 0 {
+		addItem(string(rec))
+		return
+	}
+	if *max == 0 {
+		recs.add(rec)
+		return
+	}
+	s := string(rec)
 	n++
-	i := rand.Intn(n)
-	if *max == 0 || len(list) < *max {
+	i := rng.Intn(n)
+	if len(list) < *max {
 		list = append(list, s)
 		list[i], list[n-1] = list[n-1], list[i]
 	} else if i < *max {
@@ -78,68 +320,97 @@ func add(s string) {
 	}
 }
 
-func show() {
-	for i, s := range list {
+func show(w io.Writer) {
+	if !weighted() && *repeat == 0 && *max == 0 {
+		first := true
+		recs.each(func(rec []byte) {
+			if *block && !first {
+				io.WriteString(w, "\n")
+			}
+			first = false
+			w.Write(rec)
+		})
+		return
+	}
+	out := list
+	if weighted() || *repeat > 0 {
+		out = samples()
+	}
+	for i, s := range out {
 		if *block && i > 0 {
-			os.Stdout.WriteString("\n")
+			io.WriteString(w, "\n")
 		}
-		os.Stdout.WriteString(s)
+		io.WriteString(w, s)
 	}
 }
 
-func read1(b *bufio.Reader) string {
-	s, err := b.ReadString('\n')
-	if err == io.EOF && s != "" {
-		s += "\n"
-		err = nil
-	}
-	if err != nil && err != io.EOF {
-		log.Fatal(err)
-	}
-	if s == "" {
-		return ""
-	}
-	isBlank := true
-	for i := 0; i < len(s); i++ {
-		if s[i] != ' ' && s[i] != '\t' && s[i] != '\n' {
-			isBlank = false
+// readRecord appends the next record from b to buf and returns the result.
+// A record is a line or, with -0 or -d, a string terminated by delim;
+// a final record without a terminator is given one. A line containing
+// only spaces and tabs is returned as a bare newline.
+// At the end of the input, readRecord returns buf unchanged.
+func readRecord(b *bufio.Reader, buf []byte) []byte {
+	start := len(buf)
+	for {
+		chunk, err := b.ReadSlice(delim[len(delim)-1])
+		buf = append(buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			if len(buf) > start {
+				buf = append(buf, delim...)
+			}
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(buf)-start >= len(delim) && string(buf[len(buf)-len(delim):]) == delim {
 			break
 		}
 	}
-	if isBlank {
-		return "\n"
+	if delim == "\n" && len(buf) > start {
+		for _, c := range buf[start:] {
+			if c != ' ' && c != '\t' && c != '\n' {
+				return buf
+			}
+		}
+		buf = append(buf[:start], '\n')
 	}
-	return s
+	return buf
 }
 
 func collect(r io.Reader) {
 	b := bufio.NewReader(r)
+	var rec []byte
 	for {
-		var s string
+		rec = rec[:0]
 		if *block {
-			var lines []string
+			// A block is a run of non-blank lines, ended by
+			// a blank line or the end of the input.
 			for {
-				s := read1(b)
-				if s == "\n" || s == "" {
-					if len(lines) == 0 {
-						if s == "" {
-							return
-						}
-						continue
+				n := len(rec)
+				rec = readRecord(b, rec)
+				if len(rec) == n {
+					break
+				}
+				if string(rec[n:]) == "\n" {
+					rec = rec[:n]
+					if n == 0 {
+						continue // skip blank lines before block
 					}
 					break
 				}
-				lines = append(lines, s)
 			}
-			s = strings.Join(lines, "")
 		} else {
-			s = read1(b)
-			if s == "" {
-				return
-			}
+			rec = readRecord(b, rec)
 		}
-		if grepRE == nil || grepRE.MatchString(s) {
-			add(s)
+		if len(rec) == 0 {
+			return
+		}
+		if grepRE == nil || grepRE.Match(rec) {
+			add(rec)
 		}
 	}
 }
@@ -150,3 +421,136 @@ func addNL(data []byte) []byte {
 	}
 	return data
 }
+
+// weighted reports whether the lines have weights (-w or -wf).
+func weighted() bool {
+	return *weightFirst || wfRE != nil
+}
+
+// An item is a line (or block) with its weight and,
+// for a weighted shuffle, its sort key.
+type item struct {
+	s   string
+	w   float64
+	key float64
+}
+
+// items holds the lines for a weighted shuffle or for -r.
+// For a weighted shuffle with -m, it is a min-heap ordered by key
+// holding the max lines with the largest keys.
+var items itemHeap
+
+// addItem adds s to items.
+//
+// A weighted shuffle gives each line the key log(u)/w,
+// where u is uniformly random in (0, 1] and w is the line's weight,
+// and prints the lines in decreasing key order.
+// See Efraimidis and Spirakis, “Weighted random sampling
+// with a reservoir,” Information Processing Letters, 2006.
+func addItem(s string) {
+	it := item{s: s, w: 1}
+	if weighted() {
+		it.w = weight(s)
+		if it.w == 0 {
+			return
+		}
+	}
+	if *repeat > 0 {
+		items = append(items, it)
+		return
+	}
+	it.key = math.Log(1-rng.Float64()) / it.w
+	if *max == 0 {
+		items = append(items, it)
+		return
+	}
+	if len(items) < *max {
+		heap.Push(&items, it)
+	} else if it.key > items[0].key {
+		items[0] = it
+		heap.Fix(&items, 0)
+	}
+}
+
+// samples returns the lines to print from items:
+// n lines sampled with replacement for -r,
+// or else the weighted shuffle of items.
+func samples() []string {
+	var out []string
+	if *repeat > 0 {
+		if len(items) == 0 {
+			return nil
+		}
+		cum := make([]float64, len(items))
+		total := 0.0
+		for i, it := range items {
+			total += it.w
+			cum[i] = total
+		}
+		for i := 0; i < *repeat; i++ {
+			var j int
+			if weighted() {
+				r := rng.Float64() * total
+				j = sort.Search(len(cum), func(k int) bool { return cum[k] > r })
+			} else {
+				j = rng.Intn(len(items))
+			}
+			out = append(out, items[j].s)
+		}
+		return out
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].key > items[j].key })
+	for _, it := range items {
+		out = append(out, it.s)
+	}
+	return out
+}
+
+// weight returns the weight of the line (or block) s.
+func weight(s string) float64 {
+	var f string
+	if wfRE != nil {
+		m := wfRE.FindStringSubmatch(s)
+		if m == nil {
+			log.Fatalf("no weight matching -wf in %q", firstLine(s))
+		}
+		f = m[0]
+		if len(m) > 1 {
+			f = m[1]
+		}
+	} else {
+		fields := strings.Fields(firstLine(strings.TrimSuffix(s, delim)))
+		if len(fields) == 0 {
+			log.Fatalf("no weight in %q", firstLine(s))
+		}
+		f = fields[0]
+	}
+	w, err := strconv.ParseFloat(f, 64)
+	if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+		log.Fatalf("invalid weight %q in %q", f, firstLine(s))
+	}
+	return w
+}
+
+// firstLine returns the first line of s, without its newline.
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// An itemHeap is a min-heap of items ordered by key.
+type itemHeap []item
+
+func (h itemHeap) Len() int            { return len(h) }
+func (h itemHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h itemHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *itemHeap) Push(x interface{}) { *h = append(*h, x.(item)) }
+
+func (h *itemHeap) Pop() interface{} {
+	q := *h
+	x := q[len(q)-1]
+	*h = q[:len(q)-1]
+	return x
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+)
+
+// spillBuckets is the number of temporary files
+// across which a pool spills its records.
+const spillBuckets = 64
+
+// A pool holds the records for a uniform shuffle of the entire input.
+// To avoid the time and memory overhead of a separate allocation
+// for each record, it stores the records back to back in a single
+// byte slice, along with their end offsets.
+//
+// When the records use more than -spill megabytes, the pool moves them
+// to temporary files instead: it assigns each record to one of
+// spillBuckets files chosen uniformly at random, and then it shuffles
+// each file separately in a pool of its own (which may spill again),
+// printing the results one after the other. Because every record is
+// equally likely to land in each file, and each file is shuffled
+// uniformly, the result is still a uniformly random permutation.
+type pool struct {
+	arena   []byte
+	ends    []int     // ends[i] is the end offset of record i in arena
+	order   []int     // random permutation of the record indexes
+	buckets []*bucket // temporary files, once the pool has spilled
+}
+
+// A bucket is a temporary file holding spilled records,
+// each written as a uvarint length followed by the record.
+type bucket struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// add adds a copy of rec to the pool.
+// The records are kept in random order as they are added,
+// by an “inside-out” Fisher-Yates shuffle.
+func (p *pool) add(rec []byte) {
+	if p.buckets != nil {
+		p.buckets[rng.Intn(len(p.buckets))].write(rec)
+		return
+	}
+	k := len(p.ends)
+	p.arena = append(p.arena, rec...)
+	p.ends = append(p.ends, len(p.arena))
+	i := rng.Intn(k + 1)
+	p.order = append(p.order, k)
+	p.order[i], p.order[k] = p.order[k], p.order[i]
+	if *spill > 0 && len(p.ends) > 1 && p.size() > *spill<<20 {
+		p.startSpill()
+	}
+}
+
+// size returns the approximate memory used by the records in p.
+func (p *pool) size() int {
+	return len(p.arena) + 2*8*len(p.ends)
+}
+
+// record returns the k'th record added to p.
+func (p *pool) record(k int) []byte {
+	start := 0
+	if k > 0 {
+		start = p.ends[k-1]
+	}
+	return p.arena[start:p.ends[k]]
+}
+
+// startSpill moves the records in p to newly created temporary files.
+func (p *pool) startSpill() {
+	for i := 0; i < spillBuckets; i++ {
+		f, err := os.CreateTemp("", "shuffle-")
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Remove the file now, so that it disappears even if shuffle
+		// exits early. On systems that cannot remove an open file,
+		// bucket.read removes it after closing it instead.
+		os.Remove(f.Name())
+		p.buckets = append(p.buckets, &bucket{f: f, w: bufio.NewWriter(f)})
+	}
+	for k := range p.ends {
+		p.buckets[rng.Intn(len(p.buckets))].write(p.record(k))
+	}
+	p.arena, p.ends, p.order = nil, nil, nil
+}
+
+func (b *bucket) write(rec []byte) {
+	var buf [binary.MaxVarintLen64]byte
+	b.w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(rec)))])
+	b.w.Write(rec)
+}
+
+// each calls do for each record in p, in shuffled order.
+// The record passed to do is only valid until do returns.
+func (p *pool) each(do func([]byte)) {
+	if p.buckets == nil {
+		for _, k := range p.order {
+			do(p.record(k))
+		}
+		return
+	}
+	for _, b := range p.buckets {
+		var sub pool
+		b.read(sub.add)
+		sub.each(do)
+	}
+	p.buckets = nil
+}
+
+// read calls do for each record in b and then removes b's file.
+func (b *bucket) read(do func([]byte)) {
+	if err := b.w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := b.f.Seek(0, io.SeekStart); err != nil {
+		log.Fatal(err)
+	}
+	r := bufio.NewReader(b.f)
+	var rec []byte
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("reading %s: %v", b.f.Name(), err)
+		}
+		if uint64(cap(rec)) < n {
+			rec = make([]byte, n)
+		}
+		rec = rec[:n]
+		if _, err := io.ReadFull(r, rec); err != nil {
+			log.Fatalf("reading %s: %v", b.f.Name(), err)
+		}
+		do(rec)
+	}
+	b.f.Close()
+	os.Remove(b.f.Name()) // in case startSpill could not
+}
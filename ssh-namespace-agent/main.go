@@ -8,6 +8,35 @@
 //
 //	eval $(ssh-namespace-agent)
 //
+// On the local system, the agent exports the name space directory
+// reported by plan9client.Namespace (usually $NAMESPACE).
+// On the remote system, it creates a socket for each exported service
+// in a directory named plan9 next to $SSH_AUTH_SOCK and sets $NAMESPACE
+// to that directory.
+//
+// The -ns flag sets the name space explicitly. On the local system,
+// it names the directory to export. On the remote system, it names
+// the directory in which to create the sockets; the agent assumes that
+// if the directory already exists, another copy of the agent is serving it.
+// On Linux, a remote name space beginning with @ is a prefix for abstract
+// Unix sockets, which do not appear in the file system.
+//
+// The -abstract flag asks for the remote name space to use abstract sockets,
+// which avoids creating files and keeps socket names short.
+// Given on the local system, it is passed along to the remote agent when that
+// agent starts; the remote agent falls back to a directory if it is not
+// running on Linux. Given on the remote system, it overrides the local choice.
+//
+// On SIGINT or SIGTERM, the agent shuts down cleanly: it closes the
+// connections it is proxying, removes the sockets it created
+// (sshns.socket on the local system, or the plan9 directory and
+// its sockets on the remote system), and notes the shutdown in
+// $HOME/.sshns.log before exiting.
+//
+// The -selftest flag runs the agent's client and server code paths
+// against each other in a single process, using a fake ssh-agent
+// and a fake name space service, and reports the result of each step.
+//
 package main
 
 import (
@@ -22,6 +51,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,17 +62,23 @@ import (
 	plan9client "9fans.net/go/plan9/client"
 )
 
-var verbose = flag.Bool("v", false, "enable verbose debugging")
+var (
+	verbose  = flag.Bool("v", false, "enable verbose debugging")
+	selftest = flag.Bool("selftest", false, "run self-test and exit")
+	nsFlag   = flag.String("ns", "", "use name space `dir`")
+	abstract = flag.Bool("abstract", false, "use abstract sockets for the remote name space")
+)
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: eval $(ssh-namespace-agent)\n")
+	fmt.Fprintf(os.Stderr, "usage: eval $(ssh-namespace-agent [-abstract] [-ns dir])\n")
 	os.Exit(2)
 }
 
 func main() {
 	log.SetPrefix("ssh-namespace-agent: ")
 	log.SetFlags(0)
-	if len(os.Args) == 2 && os.Args[1] == "--daemon--" {
+	if len(os.Args) >= 2 && os.Args[1] == "--daemon--" {
+		flag.CommandLine.Parse(os.Args[2:])
 		daemon()
 		return
 	}
@@ -52,6 +88,12 @@ func main() {
 	if flag.NArg() != 0 {
 		usage()
 	}
+	if *selftest {
+		if !runSelfTest() {
+			os.Exit(1)
+		}
+		return
+	}
 
 	r1, w1, err := os.Pipe()
 	if err != nil {
@@ -61,7 +103,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	cmd := exec.Command(os.Args[0], "--daemon--")
+	cmd := exec.Command(os.Args[0], append([]string{"--daemon--"}, os.Args[1:]...)...)
 	cmd.Stdout = w1
 	cmd.Stderr = w2
 	err = cmd.Start()
@@ -186,6 +228,7 @@ func parseExtmsg(m []byte) (string, []byte) {
 }
 
 func daemon() {
+	handleSignals()
 	if os.Getenv("SSH_CONNECTION") != "" {
 		server()
 		return
@@ -206,19 +249,37 @@ func server() {
 		log.Fatal(err)
 	}
 
-	dir := filepath.Dir(sock)
-	plan9 := filepath.Join(dir, "plan9")
-	_, err = os.Stat(plan9)
-	if err == nil {
-		// Daemon already running.
-		fmt.Printf("export NAMESPACE=%s\n", plan9)
-		fmt.Printf("OK\n")
-		return
-	}
-	err = os.Mkdir(plan9, 0700)
+	plan9, err := remoteNamespace(sock)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if strings.HasPrefix(plan9, "@") {
+		// An abstract name space has no directory to create.
+		// Instead, listen on the name space itself, which fails
+		// if another daemon holds it and goes away when we exit.
+		l, err := net.Listen("unix", plan9)
+		if err != nil {
+			// Daemon already running.
+			fmt.Printf("export NAMESPACE=%s\n", plan9)
+			fmt.Printf("OK\n")
+			return
+		}
+		defer l.Close()
+		addListener(l)
+	} else {
+		_, err = os.Stat(plan9)
+		if err == nil {
+			// Daemon already running.
+			fmt.Printf("export NAMESPACE=%s\n", plan9)
+			fmt.Printf("OK\n")
+			return
+		}
+		err = os.Mkdir(plan9, 0700)
+		if err != nil {
+			log.Fatal(err)
+		}
+		addFile(plan9)
+	}
 
 	if err := createSockets(sock, plan9); err != nil {
 		log.Fatal(err)
@@ -234,6 +295,36 @@ func server() {
 	}
 }
 
+// remoteNamespace returns the name space the server should create
+// for the client reached through sock: either a directory or,
+// on Linux, an abstract socket prefix beginning with @.
+// An explicit -ns or -abstract on the server takes precedence;
+// otherwise the server asks the client which form it prefers.
+// Clients that predate the question get a directory.
+func remoteNamespace(sock string) (string, error) {
+	if *nsFlag != "" {
+		if strings.HasPrefix(*nsFlag, "@") && runtime.GOOS != "linux" {
+			return "", fmt.Errorf("-ns %s: abstract sockets not supported on %s", *nsFlag, runtime.GOOS)
+		}
+		return *nsFlag, nil
+	}
+	dir := filepath.Dir(sock)
+	useAbstract := *abstract
+	if !useAbstract {
+		form, err := dialAndRunExt(sock, []byte("form"))
+		useAbstract = err == nil && string(form) == "abstract"
+	}
+	if useAbstract {
+		if runtime.GOOS == "linux" {
+			// The agent directory is named ssh-XXXXXXXXXX,
+			// which keeps the abstract names unique and short.
+			return "@" + filepath.Base(dir) + "/plan9", nil
+		}
+		log.Printf("abstract sockets not supported on %s; using directory", runtime.GOOS)
+	}
+	return filepath.Join(dir, "plan9"), nil
+}
+
 var connCache struct {
 	sync.Mutex
 	c []net.Conn
@@ -385,6 +476,10 @@ func proxySocket(sock, plan9, name string) {
 		log.Printf("post %s: %v", name, err)
 		return
 	}
+	addListener(l)
+	if !strings.HasPrefix(plan9, "@") {
+		addFile(filepath.Join(plan9, name))
+	}
 
 	for {
 		c, err := l.Accept()
@@ -403,6 +498,10 @@ func proxySocket(sock, plan9, name string) {
 }
 
 func proxy(c, c1 io.ReadWriteCloser) {
+	track(c)
+	track(c1)
+	defer untrack(c)
+	defer untrack(c1)
 	done := make(chan bool, 2)
 	go func() {
 		io.Copy(c, c1)
@@ -435,9 +534,16 @@ func client() {
 		return
 	}
 
-	ns := plan9client.Namespace()
+	ns := *nsFlag
 	if ns == "" {
-		log.Fatal("no plan9 namespace")
+		ns = plan9client.Namespace()
+	}
+	if ns == "" {
+		log.Fatal("no plan9 namespace; use -ns")
+	}
+	if strings.HasPrefix(ns, "@") {
+		// handleList needs a directory to list.
+		log.Fatalf("-ns %s: cannot export abstract name space", ns)
 	}
 	if err := os.MkdirAll(ns, 0700); err != nil {
 		log.Fatal(err)
@@ -467,14 +573,28 @@ func client() {
 		}
 	}
 
+	addListener(l)
+	addFile(newSock)
+
 	fmt.Printf("export SSH_AUTH_SOCK=%s\n", newSock)
 	fmt.Printf("OK\n")
 	closeStdout()
 
+	err = acceptLoop(l, oldSock, ns)
+	if exiting() {
+		select {} // wait for shutdown to finish
+	}
+	log.Fatal(err)
+}
+
+// acceptLoop accepts connections on l, serving each one
+// with the underlying agent oldSock and name space ns.
+// It returns only when l.Accept fails.
+func acceptLoop(l net.Listener, oldSock, ns string) error {
 	for {
 		c, err := l.Accept()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		go serve(c, oldSock, ns)
 	}
@@ -483,6 +603,8 @@ func client() {
 func serve(c net.Conn, oldSock, ns string) {
 	log.Printf("serving on client\n")
 	var c1 net.Conn
+	track(c)
+	defer untrack(c)
 	defer c.Close()
 	for {
 		m, err := readMsg(c)
@@ -523,6 +645,9 @@ func serve(c net.Conn, oldSock, ns string) {
 			case "list":
 				handleList(c, ns)
 				continue
+			case "form":
+				handleForm(c)
+				continue
 			case "dial":
 				if len(f) == 2 {
 					handleDial(c, ns, f[1])
@@ -570,6 +695,16 @@ func handleList(c net.Conn, ns string) {
 	writeExtReply(c, reply)
 }
 
+// handleForm replies with the form of name space
+// the remote agent should create: "abstract" or "dir".
+func handleForm(c net.Conn) {
+	form := "dir"
+	if *abstract {
+		form = "abstract"
+	}
+	writeExtReply(c, []byte("ok\n"+form))
+}
+
 type conn struct {
 	c      net.Conn
 	expire time.Time
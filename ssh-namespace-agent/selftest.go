@@ -0,0 +1,216 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runSelfTest runs the self-test and reports whether it passed.
+//
+// The test plays both ends of an ssh connection in one process.
+// On the client side, it runs serve (via acceptLoop) on a temporary
+// socket, backed by a fake ssh-agent and a name space directory
+// holding a single echo service. On the server side, it uses the
+// same functions that server and proxySocket use
+// (listRemote, reverseDial, and the remoteConn methods)
+// to reach the echo service through the client.
+func runSelfTest() bool {
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	// Note: keep socket paths short; see the comment in client.
+	dir, err := os.MkdirTemp("", "sshns")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	ns := filepath.Join(dir, "ns")
+	if err := os.Mkdir(ns, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return false
+	}
+
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	listen := func(file string, serve func(net.Conn)) error {
+		l, err := net.Listen("unix", file)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+		go func() {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go serve(c)
+			}
+		}()
+		return nil
+	}
+
+	// Fake ssh-agent: answers every request with SSH_AGENT_FAILURE.
+	agentSock := filepath.Join(dir, "agent")
+	err = listen(agentSock, func(c net.Conn) {
+		defer c.Close()
+		for {
+			if _, err := readMsg(c); err != nil {
+				return
+			}
+			if err := writeMsg(c, []byte{SSH_AGENT_FAILURE}); err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: fake agent: %v\n", err)
+		return false
+	}
+
+	// Fake name space service: echoes everything back.
+	err = listen(filepath.Join(ns, "echo"), func(c net.Conn) {
+		io.Copy(c, c)
+		c.Close()
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: fake service: %v\n", err)
+		return false
+	}
+
+	// Client side of the agent.
+	sock := filepath.Join(dir, "sshns.socket")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return false
+	}
+	listeners = append(listeners, l)
+	go acceptLoop(l, agentSock, ns)
+
+	var (
+		rc   *remoteConn
+		ok   = true
+		msg  = []byte("hello, world\n")
+		echo = make([]byte, 100)
+	)
+	steps := []struct {
+		name string
+		f    func() error
+	}{
+		{"list", func() error {
+			names, err := listRemote(sock)
+			if err != nil {
+				return err
+			}
+			if len(names) != 1 || names[0] != "echo" {
+				return fmt.Errorf("list = %q, want [echo]", names)
+			}
+			return nil
+		}},
+		{"form", func() error {
+			form, err := dialAndRunExt(sock, []byte("form"))
+			if err != nil {
+				return err
+			}
+			want := "dir"
+			if *abstract {
+				want = "abstract"
+			}
+			if string(form) != want {
+				return fmt.Errorf("form = %q, want %q", form, want)
+			}
+			return nil
+		}},
+		{"dial", func() error {
+			var err error
+			rc, err = reverseDial(sock, "echo")
+			return err
+		}},
+		{"write", func() error {
+			n, err := rc.Write(msg)
+			if err == nil && n != len(msg) {
+				err = fmt.Errorf("wrote %d bytes, want %d", n, len(msg))
+			}
+			return err
+		}},
+		{"read", func() error {
+			n, err := rc.Read(echo)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(echo[:n], msg) {
+				return fmt.Errorf("read %q, want %q", echo[:n], msg)
+			}
+			return nil
+		}},
+		{"refresh", func() error {
+			_, err := dialAndRunExt(sock, []byte("refresh "+rc.id))
+			return err
+		}},
+		{"close", func() error {
+			return rc.Close()
+		}},
+		{"read after close", func() error {
+			if _, err := rc.Read(echo); err == nil {
+				return fmt.Errorf("read succeeded on closed connection")
+			}
+			return nil
+		}},
+		{"agent passthrough", func() error {
+			c, err := net.Dial("unix", sock)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			const SSH_AGENTC_REQUEST_IDENTITIES = 11
+			if err := writeMsg(c, []byte{SSH_AGENTC_REQUEST_IDENTITIES}); err != nil {
+				return err
+			}
+			m, err := readMsg(c)
+			if err != nil {
+				return err
+			}
+			if len(m) != 1 || m[0] != SSH_AGENT_FAILURE {
+				return fmt.Errorf("reply %x, want %x", m, []byte{SSH_AGENT_FAILURE})
+			}
+			return nil
+		}},
+	}
+
+	for _, step := range steps {
+		start := time.Now()
+		err := step.f()
+		d := time.Since(start).Round(time.Microsecond)
+		if err != nil {
+			fmt.Printf("FAIL %s (%v): %v\n", step.name, d, err)
+			ok = false
+			break
+		}
+		fmt.Printf("ok   %s (%v)\n", step.name, d)
+	}
+	if ok {
+		fmt.Printf("PASS\n")
+	} else {
+		fmt.Printf("FAIL\n")
+	}
+	return ok
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// cleanup records what the daemon must undo when it is told to exit.
+var cleanup struct {
+	sync.Mutex
+	exiting   bool
+	listeners []net.Listener
+	files     []string           // files and directories created, in order
+	active    map[io.Closer]bool // proxied connections in use
+}
+
+// handleSignals arranges for SIGINT and SIGTERM to shut the daemon down
+// cleanly, so that it does not leave behind stale sockets that would
+// confuse the next agent to start.
+func handleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		shutdown(<-c)
+	}()
+}
+
+// shutdown closes the listeners and active connections,
+// removes the files the daemon created, and exits.
+func shutdown(sig os.Signal) {
+	cleanup.Lock()
+	cleanup.exiting = true
+	listeners, files, active := cleanup.listeners, cleanup.files, cleanup.active
+	cleanup.active = nil
+	cleanup.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+	n := 0
+	for c := range active {
+		c.Close()
+		n++
+	}
+	conns.Lock()
+	for id, cc := range conns.m {
+		cc.c.Close()
+		delete(conns.m, id)
+		n++
+	}
+	conns.Unlock()
+
+	// Closing a listener removes its socket, so most of these are gone.
+	// Remove in reverse order, so that sockets go before their directory.
+	for i := len(files) - 1; i >= 0; i-- {
+		if err := os.Remove(files[i]); err != nil && !os.IsNotExist(err) {
+			log.Print(err)
+		}
+	}
+	log.Printf("exiting on %v: closed %d connections, removed %s", sig, n, files)
+	os.Exit(0)
+}
+
+// exiting reports whether the daemon is shutting down.
+func exiting() bool {
+	cleanup.Lock()
+	defer cleanup.Unlock()
+	return cleanup.exiting
+}
+
+// addListener records that l must be closed on exit.
+func addListener(l net.Listener) {
+	cleanup.Lock()
+	cleanup.listeners = append(cleanup.listeners, l)
+	cleanup.Unlock()
+}
+
+// addFile records that the file or empty directory name must be removed on exit.
+func addFile(name string) {
+	cleanup.Lock()
+	cleanup.files = append(cleanup.files, name)
+	cleanup.Unlock()
+}
+
+// track records that c is an active connection, to be closed on exit.
+func track(c io.Closer) {
+	cleanup.Lock()
+	if cleanup.active == nil {
+		cleanup.active = make(map[io.Closer]bool)
+	}
+	cleanup.active[c] = true
+	cleanup.Unlock()
+}
+
+// untrack records that c is no longer active.
+func untrack(c io.Closer) {
+	cleanup.Lock()
+	delete(cleanup.active, c)
+	cleanup.Unlock()
+}
@@ -2,61 +2,332 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Unhex is the opposite of hexdump -C or Plan 9's "xd -b".
+// Unhex is the opposite of hexdump -C, od, xxd, or Plan 9's "xd -b".
+//
+// Usage:
+//
+//	unhex [-f format] [-strict] <dump >data
+//	unhex -l <hex >data
+//	unhex -d <data >dump
+//
+// Unhex reads a hex dump on standard input and writes the original data
+// to standard output. It detects the format of the dump from its first line;
+// the -f flag sets the format instead: hexdump (the output of "hexdump -C"),
+// od (the output of "od -A x -t x1", with or without z), xd (the output
+// of Plan 9's "xd -b"), or xxd.
+//
+// The -strict flag checks the dump for corruption: each line's address
+// must follow on from the line before it, and the text column, if present,
+// must match the bytes on the line.
+//
+// The -l flag selects lenient mode, which accepts plain hex strings
+// without address columns, such as snippets copied out of protocol
+// documents or Wireshark's "copy as hex stream". In lenient mode,
+// bytes may be separated by spaces, newlines, or commas or run
+// together, may carry an optional 0x prefix, and text from // to
+// the end of a line is ignored.
+//
+// The -d flag reverses the conversion: unhex reads binary data
+// and writes it in the format of "hexdump -C", which unhex can
+// convert back to the original data. Like hexdump, it replaces
+// runs of identical 16-byte lines after the first with a single *.
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 )
 
+// dumpFormats lists the hex dump formats accepted by -f.
+var dumpFormats = []string{"hexdump", "od", "xd", "xxd"}
+
 // parseHexdump parses the hex dump in text, which should be the
-// output of "hexdump -C" or Plan 9's "xd -b",
-// and returns the original data used to produce the dump.
+// output of "hexdump -C", "od -A x -t x1" (with or without z),
+// Plan 9's "xd -b", or xxd, and returns the original data used to
+// produce the dump. The format names which of those it is, or, if empty,
+// parseHexdump detects the format from the first line of the dump.
 // It is meant to enable storing golden binary files as text, so that
 // changes to the golden files can be seen during code reviews.
-func parseHexdump(text string) ([]byte, error) {
-	var out []byte
-	for _, line := range strings.Split(text, "\n") {
-		if i := strings.Index(line, "|"); i >= 0 { // remove text dump
-			line = line[:i]
+//
+// A line containing only * stands for repeats of the line before it,
+// up to the address on the next line.
+//
+// If strict is set, parseHexdump also checks that each line's address
+// follows on from the line before it and that the text column,
+// if present, matches the bytes on the line.
+func parseHexdump(text, format string, strict bool) ([]byte, error) {
+	lines := strings.Split(text, "\n")
+	if format == "" {
+		format = detectFormat(lines)
+	}
+	var out, last []byte
+	repeat := false
+	for i, line := range lines {
+		lineno := i + 1
+		line = strings.TrimSuffix(line, "\r")
+		if t := strings.TrimSpace(line); t == "" {
+			continue
+		} else if t == "*" { // repeated lines omitted
+			repeat = true
+			continue
 		}
-		f := strings.Fields(line)
-		if len(f) > 1+16 {
-			return nil, fmt.Errorf("parsing hex dump: too many fields on line %q", line)
+
+		// Split line into address, hex bytes, and text column.
+		var addrText, hex, ascii string
+		hasASCII := false
+		if format == "xxd" {
+			var ok bool
+			addrText, hex, ok = strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("parsing hex dump: line %d: missing address", lineno)
+			}
+			// Two spaces separate the hex bytes from the text,
+			// which is as long as the number of bytes.
+			hex, _, hasASCII = strings.Cut(strings.TrimPrefix(hex, " "), "  ")
+		} else {
+			delim := map[string]string{"hexdump": "|", "od": ">"}[format]
+			if delim != "" {
+				if j := strings.Index(line, delim); j >= 0 { // remove text dump
+					line, ascii, hasASCII = line[:j], line[j+1:], true
+				}
+			}
+			addrText, hex, _ = strings.Cut(strings.TrimSpace(line), " ")
+			if hasASCII {
+				end := map[string]string{"hexdump": "|", "od": "<"}[format]
+				var ok bool
+				if ascii, ok = strings.CutSuffix(strings.TrimRight(ascii, " \t"), end); !ok && strict {
+					return nil, fmt.Errorf("parsing hex dump: line %d: unterminated text column", lineno)
+				}
+			}
 		}
-		if len(f) == 0 || len(f) == 1 && f[0] == "*" { // all zeros block omitted
-			continue
+		f := strings.Fields(hex)
+		if len(f) > 16 && (format == "hexdump" || format == "xd") {
+			return nil, fmt.Errorf("parsing hex dump: too many fields on line %q", line)
 		}
-		addr64, err := strconv.ParseUint(f[0], 16, 0)
+
+		addr64, err := strconv.ParseUint(strings.TrimSpace(addrText), 16, 0)
 		if err != nil {
-			return nil, fmt.Errorf("parsing hex dump: invalid address %q", f[0])
+			return nil, fmt.Errorf("parsing hex dump: line %d: invalid address %q", lineno, addrText)
 		}
 		addr := int(addr64)
+		if strict {
+			if err := checkAddr(addr, len(out), repeat, last); err != nil {
+				return nil, fmt.Errorf("parsing hex dump: line %d: %v", lineno, err)
+			}
+		}
+		for repeat && len(last) > 0 && len(out) < addr {
+			out = append(out, last[:min(len(last), addr-len(out))]...)
+		}
+		repeat = false
 		if len(out) < addr {
 			out = append(out, make([]byte, addr-len(out))...)
 		}
-		for _, x := range f[1:] {
-			val, err := strconv.ParseUint(x, 16, 8)
-			if err != nil {
-				return nil, fmt.Errorf("parsing hexdump: invalid hex byte %q", x)
+		start := len(out)
+		for _, x := range f {
+			if len(x)%2 != 0 {
+				return nil, fmt.Errorf("parsing hex dump: line %d: invalid hex byte %q", lineno, x)
+			}
+			for ; x != ""; x = x[2:] {
+				val, err := strconv.ParseUint(x[:2], 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("parsing hex dump: line %d: invalid hex byte %q", lineno, x[:2])
+				}
+				out = append(out, byte(val))
+			}
+		}
+		last = out[start:len(out):len(out)]
+
+		if strict && hasASCII {
+			if format == "xxd" {
+				ascii = line[max(0, len(line)-len(last)):]
+			}
+			if want := printable(last); ascii != want {
+				return nil, fmt.Errorf("parsing hex dump: line %d: text column %q does not match bytes (%q)", lineno, ascii, want)
 			}
-			out = append(out, byte(val))
 		}
 	}
 	return out, nil
 }
 
+// detectFormat returns the format of the hex dump in lines,
+// judging by the first line.
+func detectFormat(lines []string) string {
+	for _, line := range lines {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 0 || len(f) == 1 && f[0] == "*":
+			continue
+		case strings.HasSuffix(f[0], ":"):
+			return "xxd"
+		case strings.Contains(line, "|"):
+			return "hexdump"
+		case strings.Contains(line, ">"):
+			return "od"
+		}
+		break
+	}
+	return "hexdump"
+}
+
+// checkAddr checks that a line at address addr can follow
+// n bytes of output, given whether it follows a * line
+// and the bytes on the last line before that.
+func checkAddr(addr, n int, repeat bool, last []byte) error {
+	switch {
+	case !repeat && addr != n:
+		return fmt.Errorf("address %#x does not follow previous line (want %#x)", addr, n)
+	case repeat && (addr <= n || len(last) == 0 || (addr-n)%len(last) != 0):
+		return fmt.Errorf("address %#x after * is not a whole number of repeated lines after %#x", addr, n)
+	}
+	return nil
+}
+
+// printable returns the text column for data, as in hexdump -C:
+// printable ASCII characters stand for themselves, and other bytes are dots.
+func printable(data []byte) string {
+	b := make([]byte, len(data))
+	for i, c := range data {
+		if c < ' ' || c > '~' {
+			c = '.'
+		}
+		b[i] = c
+	}
+	return string(b)
+}
+
+// hexdump writes the data read from r to w
+// in the format of "hexdump -C".
+func hexdump(w io.Writer, r io.Reader) error {
+	b := bufio.NewWriter(w)
+	var line, prev [16]byte
+	addr := 0
+	squeezed := false
+	for {
+		n, err := io.ReadFull(r, line[:])
+		if n == 0 {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+		if n == len(line) && addr > 0 && line == prev {
+			if !squeezed {
+				b.WriteString("*\n")
+				squeezed = true
+			}
+			addr += n
+			continue
+		}
+		squeezed = false
+		prev = line
+
+		fmt.Fprintf(b, "%08x  ", addr)
+		for i := 0; i < len(line); i++ {
+			if i == 8 {
+				b.WriteByte(' ')
+			}
+			if i < n {
+				fmt.Fprintf(b, "%02x ", line[i])
+			} else {
+				b.WriteString("   ")
+			}
+		}
+		fmt.Fprintf(b, " |%s|\n", printable(line[:n]))
+		addr += n
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if addr > 0 {
+		fmt.Fprintf(b, "%08x\n", addr)
+	}
+	return b.Flush()
+}
+
+// parseHex parses text as a plain sequence of hex bytes
+// with no address columns. See the package comment for the
+// accepted syntax.
+func parseHex(text string) ([]byte, error) {
+	var out []byte
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 { // remove comment
+			line = line[:i]
+		}
+		f := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})
+		for _, x := range f {
+			x = strings.TrimPrefix(strings.TrimPrefix(x, "0x"), "0X")
+			if len(x)%2 != 0 {
+				return nil, fmt.Errorf("parsing hex: odd number of hex digits in %q", x)
+			}
+			for ; x != ""; x = x[2:] {
+				val, err := strconv.ParseUint(x[:2], 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("parsing hex: invalid hex byte %q", x[:2])
+				}
+				out = append(out, byte(val))
+			}
+		}
+	}
+	return out, nil
+}
+
+var (
+	lenient = flag.Bool("l", false, "accept plain hex strings without addresses")
+	dump    = flag.Bool("d", false, "write hexdump -C output from binary input")
+	format  = flag.String("f", "", "parse dump in `format` hexdump, od, xd, or xxd (default detect)")
+	strict  = flag.Bool("strict", false, "check dump addresses and text columns")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: unhex [-f format] [-strict] <dump >data\n")
+	fmt.Fprintf(os.Stderr, "       unhex -l <hex >data\n")
+	fmt.Fprintf(os.Stderr, "       unhex -d <data >dump\n")
+	os.Exit(2)
+}
+
 func main() {
+	log.SetFlags(0)
+	log.SetPrefix("unhex: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 0 || *dump && *lenient || (*dump || *lenient) && (*format != "" || *strict) {
+		usage()
+	}
+	if *format != "" && !slices.Contains(dumpFormats, *format) {
+		log.Printf("unknown format %q", *format)
+		usage()
+	}
+
+	if *dump {
+		if err := hexdump(os.Stdout, os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	hex, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		log.Fatal(err)
 	}
-	data, err := parseHexdump(string(hex))
+	var data []byte
+	if *lenient {
+		data, err = parseHex(string(hex))
+	} else {
+		data, err = parseHexdump(string(hex), *format, *strict)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,199 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// A fileFix is the set of rewrites to apply to a single file.
+type fileFix struct {
+	p    *packages.Package
+	file *ast.File
+	repl map[ast.Node]ast.Expr // replacement for each rewritten node
+}
+
+// fixes maps file names to the rewrites for that file.
+var fixes = make(map[string]*fileFix)
+
+// addFix records that n should be replaced by repl,
+// if the rewrite is allowed by the file's go.mod.
+func addFix(p *packages.Package, n ast.Node, repl ast.Expr) {
+	if !*fix && !*diffFlag {
+		return
+	}
+	if _, version, ok := fixableNow(p); !ok {
+		pos := p.Fset.Position(n.Pos())
+		log.Printf("%s:%d: not fixing: go.mod is go %s, needs go %s", pos.Filename, pos.Line, version, minGo)
+		return
+	}
+	tf := p.Fset.File(n.Pos())
+	var file *ast.File
+	for _, f := range p.Syntax {
+		if p.Fset.File(f.Pos()) == tf {
+			file = f
+		}
+	}
+	if file == nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	name := tf.Name()
+	ff := fixes[name]
+	if ff == nil {
+		ff = &fileFix{p: p, file: file, repl: make(map[ast.Node]ast.Expr)}
+		fixes[name] = ff
+	}
+	ff.repl[n] = repl
+}
+
+// sliceFix returns the unsafe.Slice call equivalent to slice,
+// which is (*[N]T)(unsafe.Pointer(ptr))[:hi:hi] or [:],
+// given the unsafe.Pointer conversion's function (unsafe.Pointer,
+// possibly with a renamed package qualifier), ptr, and N.
+// It returns nil if the slice expression has a non-zero low bound,
+// or if the slice capacity differs from its length, as in [:hi],
+// whose capacity is N, not hi.
+func sliceFix(slice *ast.SliceExpr, unsafePointer, ptr, n ast.Expr) ast.Expr {
+	if slice.Low != nil && !isZero(slice.Low) {
+		return nil
+	}
+	if slice.High != nil && (slice.Max == nil || !sameExpr(slice.High, slice.Max)) {
+		return nil
+	}
+	length := slice.High
+	if length == nil {
+		length = n
+	}
+	sel, ok := unsafePointer.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	return &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: sel.X, Sel: ast.NewIdent("Slice")},
+		Args: []ast.Expr{ptr, length},
+	}
+}
+
+// arrayFix returns the slice to array pointer conversion equivalent to call,
+// which is (*[N]T)(unsafe.Pointer(&x[i])): (*[N]T)(x[i:]), or (*[N]T)(x) when i is 0.
+func arrayFix(call *ast.CallExpr, index *ast.IndexExpr) ast.Expr {
+	var arg ast.Expr = index.X
+	if !isZero(index.Index) {
+		arg = &ast.SliceExpr{X: index.X, Low: index.Index}
+	}
+	return &ast.CallExpr{Fun: call.Fun, Args: []ast.Expr{arg}}
+}
+
+// isZero reports whether x is the literal 0.
+func isZero(x ast.Expr) bool {
+	lit, ok := x.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT && lit.Value == "0"
+}
+
+// sameExpr reports whether x and y are the same expression, textually.
+func sameExpr(x, y ast.Expr) bool {
+	var bx, by bytes.Buffer
+	fset := token.NewFileSet()
+	return format.Node(&bx, fset, x) == nil && format.Node(&by, fset, y) == nil && bx.String() == by.String()
+}
+
+// applyFixes rewrites the files with fixes,
+// writing them back or, in -diff mode, printing a diff.
+func applyFixes() {
+	var names []string
+	for name := range fixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ff := fixes[name]
+		astutil.Apply(ff.file, nil, func(c *astutil.Cursor) bool {
+			if repl, ok := ff.repl[c.Node()]; ok {
+				c.Replace(repl)
+			}
+			return true
+		})
+		if !astutil.UsesImport(ff.file, "unsafe") {
+			astutil.DeleteImport(ff.p.Fset, ff.file, "unsafe")
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, ff.p.Fset, ff.file); err != nil {
+			log.Printf("%s: formatting: %v", name, err)
+			continue
+		}
+		if *diffFlag {
+			old, err := ioutil.ReadFile(name)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			d, err := diff(name, old, buf.Bytes())
+			if err != nil {
+				log.Printf("%s: diff: %v", name, err)
+				continue
+			}
+			os.Stdout.Write(d)
+			continue
+		}
+		if err := ioutil.WriteFile(name, buf.Bytes(), 0666); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// diff returns a unified diff of the old and new content of the named file,
+// using the system diff command, as gofmt -d does.
+func diff(name string, oldData, newData []byte) ([]byte, error) {
+	f1, err := writeTemp(oldData)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTemp(newData)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command("diff", "-u", "--label", name+".orig", "--label", name, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with status 1 when the files differ.
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeTemp(data []byte) (string, error) {
+	f, err := ioutil.TempFile("", "unsafeconv")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
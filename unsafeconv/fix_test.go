@@ -0,0 +1,121 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const fixInput = `package p
+
+import "unsafe"
+
+func f(p *byte, n int) []byte {
+	return (*[1 << 30]byte)(unsafe.Pointer(p))[:n:n]
+}
+
+func g(p *byte) []byte {
+	return (*[16]byte)(unsafe.Pointer(p))[:]
+}
+
+func h(p *byte, n int) []byte {
+	return (*[1 << 30]byte)(unsafe.Pointer(p))[1:n]
+}
+
+func l(p *byte, n int) []byte {
+	return (*[1 << 30]byte)(unsafe.Pointer(p))[:n]
+}
+
+func k(x []uint32, i int) (*[4]uint32, *[4]uint32) {
+	return (*[4]uint32)(unsafe.Pointer(&x[0])), (*[4]uint32)(unsafe.Pointer(&x[i]))
+}
+`
+
+const fixOutput = `package p
+
+import "unsafe"
+
+func f(p *byte, n int) []byte {
+	return unsafe.Slice(p, n)
+}
+
+func g(p *byte) []byte {
+	return unsafe.Slice(p, 16)
+}
+
+func h(p *byte, n int) []byte {
+	return (*[1 << 30]byte)(unsafe.Pointer(p))[1:n]
+}
+
+func l(p *byte, n int) []byte {
+	return (*[1 << 30]byte)(unsafe.Pointer(p))[:n]
+}
+
+func k(x []uint32, i int) (*[4]uint32, *[4]uint32) {
+	return (*[4]uint32)(x), (*[4]uint32)(x[i:])
+}
+`
+
+const fixArrayInput = `package p
+
+import "unsafe"
+
+func k(x []uint32) *[4]uint32 {
+	return (*[4]uint32)(unsafe.Pointer(&x[2]))
+}
+`
+
+const fixArrayOutput = `package p
+
+func k(x []uint32) *[4]uint32 {
+	return (*[4]uint32)(x[2:])
+}
+`
+
+func TestFix(t *testing.T) {
+	defer func(old bool) { *fix = old }(*fix)
+	*fix = true
+
+	for _, tt := range []struct{ in, out string }{
+		{fixInput, fixOutput},
+		{fixArrayInput, fixArrayOutput},
+	} {
+		fixes = make(map[string]*fileFix)
+		file := filepath.Join(t.TempDir(), "p.go")
+		if err := ioutil.WriteFile(file, []byte(tt.in), 0666); err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		conf := types.Config{Importer: importer.Default(), Sizes: types.SizesFor("gc", "amd64")}
+		pkg, err := conf.Check("p", fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inspect(&packages.Package{Fset: fset, Syntax: []*ast.File{f}, Types: pkg, TypesInfo: info})
+		applyFixes()
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.out {
+			t.Errorf("after -fix:\n%s\nwant:\n%s", data, tt.out)
+		}
+	}
+}
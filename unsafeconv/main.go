@@ -7,12 +7,38 @@
 // It also finds those that won't fit the mold.
 //
 // Usage:
-//	unsafeconv pkgs...
+//	unsafeconv [-gomod] [-fix | -diff] pkgs...
+//
+// The -gomod flag groups the valid rewrite candidates by whether
+// the rewrite is possible now. The rewrites (unsafe.Slice and slice to
+// array pointer conversions) require Go 1.17, so a candidate can be
+// fixed now only if the go directive in its module's go.mod
+// (or the toolchain, for packages not in a module) allows Go 1.17.
+// The remaining candidates are listed by module as needing a
+// go.mod bump. Invalid candidates are printed as usual.
+//
+// The -fix flag rewrites the valid candidates that can be fixed now,
+// writing the changed files back in place. A conversion
+// (*[N]T)(unsafe.Pointer(p))[:n:n] becomes unsafe.Slice(p, n),
+// and a conversion (*[N]T)(unsafe.Pointer(&x[i])) becomes (*[N]T)(x[i:]).
+// Slice conversions with a non-zero low bound or a capacity different
+// from the length, such as [:n], whose capacity is N, are left alone.
+// Note that the rewritten array conversion panics if x[i:] has fewer
+// than N elements, while the original does not check.
+// The -diff flag is like -fix but prints a unified diff of the changes
+// instead of writing the files.
+//
+// Unsafeconv inspects packages in parallel, using up to GOMAXPROCS
+// goroutines, and prints its findings sorted by file and position,
+// so that the output does not depend on the order in which packages
+// finish. When standard error is a terminal, it shows the progress
+// of the inspection there.
 //
 package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/printer"
@@ -20,68 +46,276 @@ import (
 	"go/types"
 	"log"
 	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"golang.org/x/tools/go/packages"
 )
 
+var (
+	gomod    = flag.Bool("gomod", false, "group valid candidates by whether go.mod allows the rewrite")
+	fix      = flag.Bool("fix", false, "rewrite valid candidates in place")
+	diffFlag = flag.Bool("diff", false, "print diff of rewrites instead of writing files")
+)
+
+// mu protects findings, valid, and fixes,
+// which are added to by concurrent calls to inspect.
+var mu sync.Mutex
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: unsafeconv [-gomod] [-fix | -diff] pkgs...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
 func main() {
+	log.SetFlags(0)
+	log.SetPrefix("unsafeconv: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || *fix && *diffFlag {
+		usage()
+	}
+
 	cfg := packages.Config{
-		Mode: packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedModule,
 		Fset: token.NewFileSet(),
 	}
 
-	pkgs, err := packages.Load(&cfg, os.Args[1:]...)
+	pkgs, err := packages.Load(&cfg, flag.Args()...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	for _, p := range pkgs {
-		for _, f := range p.Syntax {
-			ast.Inspect(f, func(n ast.Node) bool {
-				/*
-					if ptr, siz, ok := toUnsafeSlice(n, p); ok {
-						fmt.Printf("%s:%d: unsafe.Slice(%s, %s)\n\t%s\n",
-							file.Name, fset.Position(n.Pos()).Line,
-							show(ptr),
-							show(siz),
-							show(n))
+	inspectAll(pkgs)
+	printFindings()
+	if *gomod {
+		printValid()
+	}
+	applyFixes()
+}
+
+// inspectAll inspects pkgs in parallel, showing progress on standard error
+// if it is a terminal.
+func inspectAll(pkgs []*packages.Package) {
+	progress := false
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		progress = true
+	}
+
+	work := make(chan *packages.Package)
+	done := make(chan bool)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				inspect(p)
+				done <- true
+			}
+		}()
+	}
+	go func() {
+		for _, p := range pkgs {
+			work <- p
+		}
+		close(work)
+		wg.Wait()
+		close(done)
+	}()
+
+	n := 0
+	for range done {
+		n++
+		if progress {
+			fmt.Fprintf(os.Stderr, "\rinspected %d/%d packages", n, len(pkgs))
+		}
+	}
+	if progress {
+		fmt.Fprintf(os.Stderr, "\r\033[K")
+	}
+}
+
+// inspect checks the unsafe conversions in p.
+// It is safe to call inspect concurrently for different packages.
+func inspect(p *packages.Package) {
+	for _, f := range p.Syntax {
+		ast.Inspect(f, func(n ast.Node) bool {
+			/*
+				if ptr, siz, ok := toUnsafeSlice(n, p); ok {
+					fmt.Printf("%s:%d: unsafe.Slice(%s, %s)\n\t%s\n",
+						file.Name, fset.Position(n.Pos()).Line,
+						show(ptr),
+						show(siz),
+						show(n))
+					return false // do not process conversion inside
+				}
+
+				if slice, ok := n.(*ast.SliceExpr); ok {
+					if _, typ, ok := toUnsafeArray(slice.X, p); ok {
+						fmt.Printf("%s:%d otherslice %s\n\t%s\n", file.Name, fset.Position(n.Pos()).Line, show(typ), show(n))
 						return false // do not process conversion inside
 					}
+				}
+			*/
 
-					if slice, ok := n.(*ast.SliceExpr); ok {
-						if _, typ, ok := toUnsafeArray(slice.X, p); ok {
-							fmt.Printf("%s:%d otherslice %s\n\t%s\n", file.Name, fset.Position(n.Pos()).Line, show(typ), show(n))
-							return false // do not process conversion inside
-						}
-					}
-				*/
+			if checkUnsafeSlice(n, p) {
+				return false
+			}
 
-				if checkUnsafeSlice(n, p) {
-					return false
-				}
+			checkUnsafeArray(n, p)
+
+			return true
+		})
+	}
+}
+
+// minGo is the Go version required for unsafe.Slice
+// and slice to array pointer conversions.
+const minGo = "1.17"
+
+// A candidate is a valid rewrite candidate, saved for printing by printValid.
+type candidate struct {
+	pos     token.Position
+	text    string // output from show
+	module  string // module path, or "" if not in a module
+	version string // language version for module
+}
+
+var valid []candidate
+
+// showValid is like show but for a valid rewrite candidate.
+// In -gomod mode it saves the candidate for printValid instead of printing it.
+func showValid(p *packages.Package, n ast.Node, format string, args ...interface{}) {
+	if !*gomod {
+		show(p, n, format, args...)
+		return
+	}
+	var c candidate
+	c.module, c.version, _ = fixableNow(p)
+	c.pos = p.Fset.Position(n.Pos())
+	c.text = fmt.Sprintf("%s:%d: %s\n\t%s\n", c.pos.Filename, c.pos.Line, fmt.Sprintf(format, args...), gofmt(p, n))
+	mu.Lock()
+	valid = append(valid, c)
+	mu.Unlock()
+}
+
+// printValid prints the saved candidates, grouped by whether they can be fixed now.
+func printValid() {
+	sort.SliceStable(valid, func(i, j int) bool { return posLess(valid[i].pos, valid[j].pos) })
+	var now []candidate
+	later := make(map[string][]candidate)
+	for _, c := range valid {
+		if canFix(c.module, c.version) {
+			now = append(now, c)
+		} else {
+			later[c.module] = append(later[c.module], c)
+		}
+	}
+
+	fmt.Printf("\n# can fix now (%d)\n\n", len(now))
+	for _, c := range now {
+		fmt.Print(c.text)
+	}
 
-				checkUnsafeArray(n, p)
+	var mods []string
+	for m := range later {
+		mods = append(mods, m)
+	}
+	sort.Strings(mods)
+	for _, m := range mods {
+		list := later[m]
+		fmt.Printf("\n# needs go.mod bump: %s is go %s, needs go %s (%d)\n\n", m, list[0].version, minGo, len(list))
+		for _, c := range list {
+			fmt.Print(c.text)
+		}
+	}
+}
 
-				return true
-			})
+// fixableNow returns the module path and Go language version for p,
+// and reports whether the version allows the rewrites now.
+// If p is not in a module, the module path is empty and fixableNow
+// reports that the rewrites are allowed.
+func fixableNow(p *packages.Package) (module, version string, ok bool) {
+	if m := p.Module; m != nil {
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		module = m.Path
+		version = m.GoVersion
+		if version == "" {
+			version = "1.16" // go command default for go.mod without go line
 		}
 	}
+	return module, version, canFix(module, version)
+}
+
+// canFix reports whether a candidate in module with the given
+// Go version can be fixed now.
+func canFix(module, version string) bool {
+	return module == "" || !versionLess(version, minGo)
 }
 
-var gofmtBuf bytes.Buffer
+// versionLess reports whether the Go version x is less than y.
+// The versions have the form "1.N" or "1.N.P".
+func versionLess(x, y string) bool {
+	xs, ys := strings.Split(x, "."), strings.Split(y, ".")
+	for i := 0; i < len(xs) && i < len(ys); i++ {
+		xn, _ := strconv.Atoi(xs[i])
+		yn, _ := strconv.Atoi(ys[i])
+		if xn != yn {
+			return xn < yn
+		}
+	}
+	return len(xs) < len(ys)
+}
 
 func gofmt(p *packages.Package, n interface{}) string {
-	gofmtBuf.Reset()
-	err := printer.Fprint(&gofmtBuf, p.Fset, n)
+	var buf bytes.Buffer
+	err := printer.Fprint(&buf, p.Fset, n)
 	if err != nil {
 		return "<" + err.Error() + ">"
 	}
-	return gofmtBuf.String()
+	return buf.String()
 }
 
+// A finding is a message about a single conversion, saved for printFindings.
+type finding struct {
+	pos  token.Position
+	text string
+}
+
+var findings []finding
+
 func show(p *packages.Package, n ast.Node, format string, args ...interface{}) {
 	pos := p.Fset.Position(n.Pos())
-	fmt.Printf("%s:%d: %s\n\t%s\n", pos.Filename, pos.Line, fmt.Sprintf(format, args...), gofmt(p, n))
+	text := fmt.Sprintf("%s:%d: %s\n\t%s\n", pos.Filename, pos.Line, fmt.Sprintf(format, args...), gofmt(p, n))
+	mu.Lock()
+	findings = append(findings, finding{pos, text})
+	mu.Unlock()
+}
+
+// printFindings prints the saved findings, sorted by position.
+func printFindings() {
+	sort.SliceStable(findings, func(i, j int) bool { return posLess(findings[i].pos, findings[j].pos) })
+	for _, f := range findings {
+		fmt.Print(f.text)
+	}
+}
+
+// posLess reports whether position x sorts before y.
+func posLess(x, y token.Position) bool {
+	if x.Filename != y.Filename {
+		return x.Filename < y.Filename
+	}
+	if x.Line != y.Line {
+		return x.Line < y.Line
+	}
+	return x.Column < y.Column
 }
 
 func checkUnsafeSlice(n ast.Node, p *packages.Package) bool {
@@ -130,10 +364,12 @@ func checkUnsafeSlice(n ast.Node, p *packages.Package) bool {
 
 	// Unwrap inner unsafe.Pointer conversion.
 	arg := call.Args[0]
+	var conv ast.Expr
 	if call, ok := arg.(*ast.CallExpr); ok && len(call.Args) == 1 {
 		ptv := p.TypesInfo.Types[call.Fun]
 		if ptv.Type != nil && ptv.IsType() && ptv.Type.String() == "unsafe.Pointer" {
 			arg = call.Args[0]
+			conv = call.Fun
 		}
 	}
 
@@ -152,7 +388,12 @@ func checkUnsafeSlice(n ast.Node, p *packages.Package) bool {
 		return true
 	}
 
-	show(p, n, "slice-convert %v to %v: valid", tptr, tslice)
+	showValid(p, n, "slice-convert %v to %v: valid", tptr, tslice)
+	if arr, ok := paren.X.(*ast.StarExpr).X.(*ast.ArrayType); ok && conv != nil {
+		if repl := sliceFix(slice, conv, arg, arr.Len); repl != nil {
+			addFix(p, n, repl)
+		}
+	}
 	return true
 }
 
@@ -230,7 +471,8 @@ func checkUnsafeArray(n ast.Node, p *packages.Package) {
 		return
 	}
 
-	show(p, n, "array-convert %v to %v: valid", argtyp, tptr)
+	showValid(p, n, "array-convert %v to %v: valid", argtyp, tptr)
+	addFix(p, n, arrayFix(call, index))
 }
 
 /*
@@ -2,17 +2,48 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Yaml2json converts YAML input to JSON output.
+// Yaml2json converts YAML input to JSON output, or JSON back to YAML.
 //
 // Usage:
 //
-//	yaml2json [-o output] [file...]
+//	yaml2json [-r] [-stream] [-path path] [-o output] [-merge [-append]] [file...]
 //
 // Yaml2json reads the named files, or else standard input, as YAML input
 // and prints that data in JSON form to standard output.
 //
+// A YAML file may hold a stream of documents separated by --- lines.
+// Yaml2json prints a file containing a single document as that document's
+// JSON value, and it prints a file containing multiple documents as a JSON
+// array with one element per document. The -stream flag instead prints
+// each document as a single line of JSON (the JSON Lines format),
+// whether or not the file holds multiple documents.
+//
+// The -r flag reverses the conversion: yaml2json reads each input as
+// a stream of JSON values and prints them as YAML documents,
+// separated by --- lines. The -stream flag cannot be used with -r.
+//
+// The -path flag prints only the part of each document selected by path,
+// such as $.spec.containers[*].image. A path is a sequence of field names,
+// each preceded by a dot, and array indexes in brackets; the leading $
+// and the dot before the first field can be omitted. A field name that
+// is not a plain word can be written in quotes in brackets, as in ["a.b"],
+// a negative index counts back from the end of an array, and * matches
+// every field or array element. If the path contains a *, yaml2json prints
+// the list of values it matches in each document. Otherwise it prints the
+// single value, and a document with no value at the path is an error.
+// In -merge mode, the path applies to the merged document.
+//
 // The -o flag specifies the name of a file to write instead of using standard output.
 //
+// The -merge flag causes yaml2json to merge all the input files into
+// a single document, which it prints as JSON. Each file is merged into
+// the result of the files before it: maps are merged recursively,
+// with the later file's values taking precedence, and any other value
+// in the later file replaces the earlier value. The documents in a
+// multi-document file are merged in the same way, in order.
+// The -append flag changes the rule for arrays: an array in the later file
+// is appended to the earlier array instead of replacing it.
+//
 // Example
 //
 // To print a YAML file as JSON:
@@ -23,13 +54,32 @@
 //
 //	yaml2json -o data.json data.yaml
 //
+// To apply an overlay to a base configuration:
+//
+//	yaml2json -merge base.yaml overlay.yaml
+//
+// Yaml2json preserves the order of keys in mappings and objects.
+// It converts mapping keys that are not strings, such as numbers
+// or booleans, to strings, since JSON allows only string keys.
+//
+// To print the container images in a Kubernetes configuration,
+// one JSON array per line:
+//
+//	yaml2json -stream -path '$.spec.containers[*].image' pod.yaml
+//
+// To convert a JSON file back to YAML:
+//
+//	yaml2json -r data.json
+//
 package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -38,15 +88,23 @@ import (
 )
 
 var (
-	oflag = flag.String("o", "", "write output to `file` (default standard output)")
+	oflag      = flag.String("o", "", "write output to `file` (default standard output)")
+	mergeFlag  = flag.Bool("merge", false, "merge input files into a single document")
+	appendFlag = flag.Bool("append", false, "in -merge mode, append arrays instead of replacing them")
+	rflag      = flag.Bool("r", false, "convert JSON input to YAML")
+	streamFlag = flag.Bool("stream", false, "print one line of JSON per YAML document")
+	pathFlag   = flag.String("path", "", "print only the values selected by `path` (such as $.a.b[0])")
+
+	selectPath *path // parsed -path, or nil
 
 	output  *bufio.Writer
+	yamlEnc *yaml.Encoder // encoder for -r output, created at first use
 	comment rune
 	exit    = 0
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: yaml2json [-o output] [file...]\n")
+	fmt.Fprintf(os.Stderr, "usage: yaml2json [-r] [-stream] [-path path] [-o output] [-merge [-append]] [file...]\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -56,6 +114,16 @@ func main() {
 	log.SetFlags(0)
 	flag.Usage = usage
 	flag.Parse()
+	if *appendFlag && !*mergeFlag || *rflag && *streamFlag {
+		usage()
+	}
+	if *pathFlag != "" {
+		p, err := parsePath(*pathFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		selectPath = p
+	}
 
 	outfile := os.Stdout
 	if *oflag != "" {
@@ -67,18 +135,39 @@ func main() {
 	}
 	output = bufio.NewWriter(outfile)
 
+	var merged interface{}
 	if flag.NArg() == 0 {
-		convert(os.Stdin)
-	} else {
-		for _, file := range flag.Args() {
-			f, err := os.Open(file)
-			if err != nil {
-				log.Print(err)
-				exit = 1
-				continue
+		if *mergeFlag {
+			if docs, ok := read(os.Stdin); ok {
+				merged = mergeDocs(merged, docs)
+			}
+		} else {
+			convert(os.Stdin)
+		}
+	}
+	for _, file := range flag.Args() {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Print(err)
+			exit = 1
+			continue
+		}
+		if *mergeFlag {
+			if docs, ok := read(f); ok {
+				merged = mergeDocs(merged, docs)
 			}
+		} else {
 			convert(f)
-			f.Close()
+		}
+		f.Close()
+	}
+	if *mergeFlag && exit == 0 {
+		write("merged input", filter("merged input", []interface{}{merged}))
+	}
+	if yamlEnc != nil {
+		if err := yamlEnc.Close(); err != nil {
+			log.Printf("encoding: %v", err)
+			exit = 1
 		}
 	}
 	output.Flush()
@@ -86,24 +175,156 @@ func main() {
 }
 
 func convert(f *os.File) {
+	if docs, ok := read(f); ok {
+		write(f.Name(), filter(f.Name(), docs))
+	}
+}
+
+// read reads and decodes the documents in f:
+// YAML documents, or JSON values in -r mode.
+func read(f *os.File) ([]interface{}, bool) {
 	data, err := ioutil.ReadAll(f)
 	if err != nil {
-		log.Print("%s: reading: %v", f.Name(), err)
+		log.Printf("%s: reading: %v", f.Name(), err)
 		exit = 1
-		return
+		return nil, false
+	}
+	var docs []interface{}
+	if *rflag {
+		docs, err = decodeJSON(data)
+	} else {
+		docs, err = decodeYAML(data)
 	}
-	var d interface{}
-	if err := yaml.Unmarshal(data, &d); err != nil {
-		log.Print("%s: decoding: %v", f.Name(), err)
+	if err != nil {
+		log.Printf("%s: decoding: %v", f.Name(), err)
 		exit = 1
+		return nil, false
+	}
+	return docs, true
+}
+
+// decodeYAML decodes the stream of YAML documents in data.
+// An empty stream decodes as a single null document.
+func decodeYAML(data []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var n yaml.Node
+		if err := dec.Decode(&n); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		d, err := fromNode(&n)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	if len(docs) == 0 {
+		docs = append(docs, nil)
+	}
+	return docs, nil
+}
+
+// decodeJSON decodes the stream of JSON values in data.
+func decodeJSON(data []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	for dec.More() {
+		d, err := decodeJSONValue(dec)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// write writes docs to the output: in JSON form, or in YAML form in -r mode.
+// The name is used in error messages.
+func write(name string, docs []interface{}) {
+	if *rflag {
+		for _, d := range docs {
+			if yamlEnc == nil {
+				yamlEnc = yaml.NewEncoder(output)
+				yamlEnc.SetIndent(2)
+			}
+			if err := yamlEnc.Encode(d); err != nil {
+				log.Printf("%s: encoding: %v", name, err)
+				exit = 1
+				return
+			}
+		}
+		return
+	}
+
+	if *streamFlag {
+		for _, d := range docs {
+			data, err := json.Marshal(d)
+			if err != nil {
+				log.Printf("%s: encoding: %v", name, err)
+				exit = 1
+				return
+			}
+			output.Write(data)
+			output.WriteByte('\n')
+		}
 		return
 	}
-	data, err = json.MarshalIndent(&d, "", "\t")
+
+	var d interface{} = docs
+	if len(docs) == 1 {
+		d = docs[0]
+	}
+	data, err := json.MarshalIndent(d, "", "\t")
 	if err != nil {
-		log.Print("%s: encoding: %v", f.Name(), err)
+		log.Printf("%s: encoding: %v", name, err)
 		exit = 1
 		return
 	}
 	output.Write(data)
 	output.WriteByte('\n')
 }
+
+// mergeDocs merges each of docs in turn over base
+// and returns the result.
+func mergeDocs(base interface{}, docs []interface{}) interface{} {
+	for _, d := range docs {
+		base = merge(base, d)
+	}
+	return base
+}
+
+// merge returns the result of merging the overlay value over the base value.
+// If both are maps, merge merges them recursively.
+// If both are arrays and -append is set, merge appends them.
+// Otherwise the overlay replaces the base.
+func merge(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case *object:
+		b, ok := base.(*object)
+		if !ok {
+			return overlay
+		}
+		for _, k := range o.keys {
+			v := o.m[k]
+			if old, ok := b.get(k); ok {
+				v = merge(old, v)
+			}
+			b.set(k, v)
+		}
+		return b
+
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok && *appendFlag {
+			return append(b, o...)
+		}
+	}
+	return overlay
+}
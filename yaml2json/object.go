@@ -0,0 +1,267 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// An object is a map that remembers the order of its keys,
+// so that converted output lists keys in input order.
+type object struct {
+	keys []string
+	m    map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{m: make(map[string]interface{})}
+}
+
+// get returns the value for key k and whether it is present.
+func (o *object) get(k string) (interface{}, bool) {
+	v, ok := o.m[k]
+	return v, ok
+}
+
+// set sets the value for key k, adding k at the end
+// of the key order if it is not already present.
+func (o *object) set(k string, v interface{}) {
+	if _, ok := o.m[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.m[k] = v
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kjs, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vjs, err := json.Marshal(o.m[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kjs)
+		buf.WriteByte(':')
+		buf.Write(vjs)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o *object) MarshalYAML() (interface{}, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range o.keys {
+		var kn, vn yaml.Node
+		if err := kn.Encode(k); err != nil {
+			return nil, err
+		}
+		if err := vn.Encode(o.m[k]); err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, &kn, &vn)
+	}
+	return n, nil
+}
+
+// maxAliasNodes is the maximum number of nodes that expanding aliases
+// may add to a document, to stop "billion laughs" documents that
+// use a few nested aliases to describe an enormous value.
+const maxAliasNodes = 1 << 20
+
+// A nodeDecoder converts the YAML nodes of a single document.
+type nodeDecoder struct {
+	expanding  map[*yaml.Node]bool // anchored nodes whose aliases are being expanded
+	aliasNodes int                 // nodes decoded while expanding aliases
+}
+
+// fromNode returns the value represented by the YAML document node n.
+func fromNode(n *yaml.Node) (interface{}, error) {
+	d := &nodeDecoder{expanding: make(map[*yaml.Node]bool)}
+	return d.value(n)
+}
+
+// value returns the value represented by the YAML node n,
+// using *object for mappings. Mapping keys that are not strings
+// are converted to strings: a scalar key becomes its text,
+// and any other key becomes its JSON encoding.
+func (d *nodeDecoder) value(n *yaml.Node) (interface{}, error) {
+	if len(d.expanding) > 0 {
+		d.aliasNodes++
+		if d.aliasNodes > maxAliasNodes {
+			return nil, fmt.Errorf("line %d: document contains excessive aliasing", n.Line)
+		}
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return d.value(n.Content[0])
+
+	case yaml.AliasNode:
+		if d.expanding[n.Alias] {
+			return nil, fmt.Errorf("line %d: anchor '%s' value contains itself", n.Line, n.Value)
+		}
+		d.expanding[n.Alias] = true
+		v, err := d.value(n.Alias)
+		delete(d.expanding, n.Alias)
+		return v, err
+
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := d.value(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+
+	case yaml.MappingNode:
+		o := newObject()
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			kn, vn := n.Content[i], n.Content[i+1]
+			if kn.Kind == yaml.ScalarNode && kn.ShortTag() == "!!merge" {
+				if err := d.mergeKey(o, vn); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			k, err := d.keyString(kn)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.value(vn)
+			if err != nil {
+				return nil, err
+			}
+			o.set(k, v)
+		}
+		return o, nil
+	}
+	return nil, fmt.Errorf("line %d: unexpected YAML node kind %d", n.Line, n.Kind)
+}
+
+// mergeKey handles a << merge key in a mapping, adding to o the keys
+// of the mapping or sequence of mappings vn that o does not already have.
+// Keys set explicitly later in the mapping still override merged ones.
+func (d *nodeDecoder) mergeKey(o *object, vn *yaml.Node) error {
+	v, err := d.value(vn)
+	if err != nil {
+		return err
+	}
+	maps := []interface{}{v}
+	if list, ok := v.([]interface{}); ok {
+		maps = list
+	}
+	for _, mv := range maps {
+		m, ok := mv.(*object)
+		if !ok {
+			return fmt.Errorf("line %d: map merge requires map or sequence of maps as the value", vn.Line)
+		}
+		for _, k := range m.keys {
+			if _, ok := o.get(k); !ok {
+				o.set(k, m.m[k])
+			}
+		}
+	}
+	return nil
+}
+
+// keyString returns the string form of the mapping key kn.
+func (d *nodeDecoder) keyString(kn *yaml.Node) (string, error) {
+	sn := kn
+	for sn.Kind == yaml.AliasNode {
+		sn = sn.Alias
+	}
+	if sn.Kind == yaml.ScalarNode {
+		if sn.ShortTag() == "!!null" {
+			return "null", nil
+		}
+		return sn.Value, nil
+	}
+	v, err := d.value(kn)
+	if err != nil {
+		return "", err
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(js), nil
+}
+
+// decodeJSONValue decodes the next JSON value from dec,
+// using *object for objects, to preserve key order, and
+// int64 or float64 for numbers, so that the YAML encoder
+// prints numbers as numbers. The decoder must have UseNumber set.
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			o := newObject()
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				o.set(kt.(string), v)
+			}
+			if _, err := dec.Token(); err != nil { // }
+				return nil, err
+			}
+			return o, nil
+		case '[':
+			list := []interface{}{}
+			for dec.More() {
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			if _, err := dec.Token(); err != nil { // ]
+				return nil, err
+			}
+			return list, nil
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		f, _ := t.Float64()
+		return f, nil
+	}
+	return t, nil
+}
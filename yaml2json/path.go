@@ -0,0 +1,163 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// A path selects values from a document, as parsed from the -path flag.
+type path struct {
+	elems []pathElem
+	wild  bool // path contains a wildcard, so it may select many values
+}
+
+// A pathElem is a single step in a path.
+// It selects the field key, the array element index,
+// or, if wild is set, every field or element.
+type pathElem struct {
+	key   string
+	index int
+	isKey bool
+	wild  bool
+}
+
+// parsePath parses a path like $.spec.containers[*].image.
+// The leading $ is optional, and so is the dot before the first field.
+// A field name can also be written as a quoted string in brackets,
+// as in ["a.b"], and * matches every field or array element.
+// A negative index counts back from the end of an array.
+func parsePath(s string) (*path, error) {
+	p := new(path)
+	rest := strings.TrimPrefix(s, "$")
+	first := true
+	for rest != "" {
+		var e pathElem
+		switch {
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if rest[1:] != "" && (rest[1] == '"' || rest[1] == '\'') {
+				end = strings.IndexByte(rest[2:], rest[1])
+				if end < 0 || !strings.HasPrefix(rest[2+end+1:], "]") {
+					return nil, fmt.Errorf("invalid path %q: unterminated quoted field", s)
+				}
+				end += 3
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", s)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				e.wild = true
+			case inner != "" && (inner[0] == '"' || inner[0] == '\''):
+				e.key, e.isKey = inner[1:len(inner)-1], true
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: invalid index [%s]", s, inner)
+				}
+				e.index = i
+			}
+
+		case rest[0] == '.' || first:
+			if rest[0] == '.' {
+				rest = rest[1:]
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path %q: empty field name", s)
+			}
+			if name == "*" {
+				e.wild = true
+			} else {
+				e.key, e.isKey = name, true
+			}
+
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", s, rest)
+		}
+		first = false
+		p.elems = append(p.elems, e)
+		if e.wild {
+			p.wild = true
+		}
+	}
+	return p, nil
+}
+
+// eval returns the values in d selected by p.
+func (p *path) eval(d interface{}) []interface{} {
+	vals := []interface{}{d}
+	for _, e := range p.elems {
+		var next []interface{}
+		for _, v := range vals {
+			switch v := v.(type) {
+			case *object:
+				switch {
+				case e.wild:
+					for _, k := range v.keys {
+						next = append(next, v.m[k])
+					}
+				case e.isKey:
+					if x, ok := v.get(e.key); ok {
+						next = append(next, x)
+					}
+				}
+			case []interface{}:
+				switch {
+				case e.wild:
+					next = append(next, v...)
+				case !e.isKey:
+					i := e.index
+					if i < 0 {
+						i += len(v)
+					}
+					if 0 <= i && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+// filter applies the -path flag to docs, returning the selected values.
+// For a path with a wildcard, each document's matches form a list.
+// For a path without one, a document with no match is an error.
+// The name is used in error messages.
+func filter(name string, docs []interface{}) []interface{} {
+	if selectPath == nil {
+		return docs
+	}
+	var out []interface{}
+	for _, d := range docs {
+		vals := selectPath.eval(d)
+		switch {
+		case selectPath.wild:
+			if vals == nil {
+				vals = []interface{}{}
+			}
+			out = append(out, vals)
+		case len(vals) == 0:
+			log.Printf("%s: no value at path %s", name, *pathFlag)
+			exit = 1
+		default:
+			out = append(out, vals[0])
+		}
+	}
+	return out
+}